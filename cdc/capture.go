@@ -16,16 +16,24 @@ package cdc
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/coreos/etcd/clientv3"
 	"github.com/google/uuid"
 	"github.com/pingcap/errors"
 	"github.com/pingcap/log"
+	pd "github.com/pingcap/pd/client"
+	"github.com/pingcap/ticdc/cdc/entry"
 	"github.com/pingcap/ticdc/cdc/kv"
 	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/ticdc/cdc/puller"
 	"github.com/pingcap/ticdc/cdc/roles"
+	"github.com/pingcap/ticdc/cdc/schema"
 	"github.com/pingcap/ticdc/pkg/flags"
+	"github.com/pingcap/ticdc/pkg/util"
 	tidbkv "github.com/pingcap/tidb/kv"
 	"github.com/pingcap/tidb/store"
 	"github.com/pingcap/tidb/store/tikv"
@@ -39,6 +47,10 @@ const (
 	CaptureOwnerKey = kv.EtcdKeyBase + "/capture/owner"
 )
 
+// schemaGCInterval is how often the versions of the schema storage which no
+// changefeed needs are removed
+var schemaGCInterval = time.Minute
+
 // Capture represents a Capture server, it monitors the changefeed information in etcd and schedules SubChangeFeed on it.
 type Capture struct {
 	pdEndpoints  []string
@@ -46,13 +58,20 @@ type Capture struct {
 	ownerManager roles.Manager
 	ownerWorker  *ownerImpl
 
-	processors map[string]*processor
+	// schemaStorage is shared by the owner and the processors of the capture,
+	// the DDL jobs finished after schemaStartTs are pulled into it
+	schemaStorage *schema.MultiVersionStorage
+	schemaStartTs uint64
+
+	processorsMu sync.Mutex
+	processors   map[string]*processor
 
 	info *model.CaptureInfo
 }
 
-// NewCapture returns a new Capture instance
-func NewCapture(pdEndpoints []string) (c *Capture, err error) {
+// NewCapture returns a new Capture instance, advertiseAddr is the address of
+// the status server which other captures forward the API requests to.
+func NewCapture(pdEndpoints []string, advertiseAddr string) (c *Capture, err error) {
 	cli, err := clientv3.New(clientv3.Config{
 		Endpoints:   pdEndpoints,
 		DialTimeout: 5 * time.Second,
@@ -66,25 +85,33 @@ func NewCapture(pdEndpoints []string) (c *Capture, err error) {
 
 	id := uuid.New().String()
 	info := &model.CaptureInfo{
-		ID: id,
+		ID:            id,
+		AdvertiseAddr: advertiseAddr,
 	}
 
-	log.Info("creating capture", zap.String("capture-id", id))
+	log.Info("creating capture", zap.String("capture-id", id), zap.String("advertise-addr", advertiseAddr))
 
 	manager := roles.NewOwnerManager(cli, id, CaptureOwnerKey)
 
-	worker, err := NewOwner(pdEndpoints, cli, manager)
+	schemaStorage, startTs, err := NewSchemaStorage(pdEndpoints)
+	if err != nil {
+		return nil, errors.Annotate(err, "create schema storage")
+	}
+
+	worker, err := NewOwner(pdEndpoints, cli, manager, schemaStorage)
 	if err != nil {
 		return nil, errors.Annotate(err, "new owner failed")
 	}
 
 	c = &Capture{
-		processors:   make(map[string]*processor),
-		pdEndpoints:  pdEndpoints,
-		etcdClient:   cli,
-		ownerManager: manager,
-		ownerWorker:  worker,
-		info:         info,
+		processors:    make(map[string]*processor),
+		pdEndpoints:   pdEndpoints,
+		etcdClient:    cli,
+		ownerManager:  manager,
+		ownerWorker:   worker,
+		schemaStorage: schemaStorage,
+		schemaStartTs: startTs,
+		info:          info,
 	}
 
 	return
@@ -94,14 +121,30 @@ var _ processorCallback = &Capture{}
 
 // OnRunProcessor implements processorCallback.
 func (c *Capture) OnRunProcessor(p *processor) {
+	c.processorsMu.Lock()
+	defer c.processorsMu.Unlock()
 	c.processors[p.changefeedID] = p
 }
 
 // OnStopProcessor implements processorCallback.
 func (c *Capture) OnStopProcessor(p *processor) {
+	c.processorsMu.Lock()
+	defer c.processorsMu.Unlock()
 	delete(c.processors, p.changefeedID)
 }
 
+// processorsDebugInfo returns the views of the processors running on the capture
+func (c *Capture) processorsDebugInfo() []*processorInfo {
+	c.processorsMu.Lock()
+	defer c.processorsMu.Unlock()
+	infos := make([]*processorInfo, 0, len(c.processors))
+	for _, p := range c.processors {
+		infos = append(infos, p.debugInfo())
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ChangefeedID < infos[j].ChangefeedID })
+	return infos
+}
+
 // Start starts the Capture mainloop
 func (c *Capture) Start(ctx context.Context) (err error) {
 	// TODO: better channgefeed model with etcd storage
@@ -115,13 +158,27 @@ func (c *Capture) Start(ctx context.Context) (err error) {
 		return errors.Annotate(err, "CampaignOwner")
 	}
 
+	pdCli, err := pd.NewClient(c.pdEndpoints, pd.SecurityOption{})
+	if err != nil {
+		return errors.Annotatef(err, "create pd client failed, addr: %v", c.pdEndpoints)
+	}
+	defer pdCli.Close()
+
 	errg, cctx := errgroup.WithContext(ctx)
 
 	errg.Go(func() error {
 		return c.ownerWorker.Run(cctx, time.Second*1)
 	})
 
-	watcher := NewChangeFeedWatcher(c.info.ID, c.pdEndpoints, c.etcdClient)
+	errg.Go(func() error {
+		return PullSchemaStorage(cctx, pdCli, c.schemaStorage, c.schemaStartTs)
+	})
+
+	errg.Go(func() error {
+		return c.runSchemaGC(cctx, c.schemaStorage)
+	})
+
+	watcher := NewChangeFeedWatcher(c.info.ID, c.pdEndpoints, c.etcdClient, c.schemaStorage)
 	errg.Go(func() error {
 		return watcher.Watch(cctx, c)
 	})
@@ -129,6 +186,71 @@ func (c *Capture) Start(ctx context.Context) (err error) {
 	return errg.Wait()
 }
 
+// runSchemaGC removes the versions of the schema storage before the minimum
+// checkpoint ts of the changefeeds periodically, the processors of a changefeed
+// are started or restarted from its checkpoint ts.
+func (c *Capture) runSchemaGC(ctx context.Context, schemaStorage *schema.MultiVersionStorage) error {
+	ticker := time.NewTicker(schemaGCInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		gcTs, err := minCheckpointTs(ctx, c.etcdClient)
+		if err != nil {
+			log.Warn("get the schema gc ts failed", zap.Error(err))
+			continue
+		}
+		// keep all the versions when there is no changefeed, a changefeed may
+		// be created with a start ts in the past
+		if gcTs == 0 {
+			continue
+		}
+		// the changefeeds starting before the gc ts are rejected since then
+		if err := kv.AdvanceSchemaGCTs(ctx, c.etcdClient, gcTs); err != nil {
+			log.Warn("advance the schema gc ts failed", zap.Error(err))
+			continue
+		}
+		schemaStorage.DoGC(gcTs)
+	}
+}
+
+// minCheckpointTs returns the minimum checkpoint ts of the changefeeds,
+// including the stopped ones, it returns 0 if there is no changefeed. The ts
+// the owner restores the schema of a changefeed at counts if it's smaller.
+func minCheckpointTs(ctx context.Context, cli *clientv3.Client) (uint64, error) {
+	_, details, err := kv.GetChangeFeeds(ctx, cli)
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+	var minTs uint64
+	for id, rawDetail := range details {
+		detail := &model.ChangeFeedDetail{}
+		if err := detail.Unmarshal(rawDetail.Value); err != nil {
+			return 0, errors.Annotatef(err, "unmarshal changefeed %s", id)
+		}
+		ts := detail.GetStartTs()
+		info, err := kv.GetChangeFeedInfo(ctx, cli, id)
+		if err == nil {
+			ts = info.CheckpointTs
+		} else if errors.Cause(err) != model.ErrChangeFeedNotExists {
+			return 0, errors.Trace(err)
+		}
+		state, err := kv.GetChangeFeedDDLState(ctx, cli, id)
+		if err == nil {
+			ts = ddlSchemaTs(ts, state)
+		} else if errors.Cause(err) != model.ErrChangeFeedNotExists {
+			return 0, errors.Trace(err)
+		}
+		if minTs == 0 || ts < minTs {
+			minTs = ts
+		}
+	}
+	return minTs, nil
+}
+
 // Close closes the capture by unregistering it from etcd
 func (c *Capture) Close(ctx context.Context) error {
 	return errors.Trace(DeleteCaptureInfo(ctx, c.info.ID, c.etcdClient))
@@ -156,3 +278,62 @@ func createTiStore(urls string) (tidbkv.Storage, error) {
 
 	return tiStore, nil
 }
+
+// NewSchemaStorage loads the history DDL jobs into a schema
+// storage shared by the owner and the processors of the capture, and returns the ts to pull
+// the following DDL jobs from.
+func NewSchemaStorage(pdEndpoints []string) (*schema.MultiVersionStorage, uint64, error) {
+	kvStore, err := createTiStore(strings.Join(pdEndpoints, ","))
+	if err != nil {
+		return nil, 0, errors.Trace(err)
+	}
+	// the jobs finished after the version are loaded and then pulled again,
+	// which are ignored by the schema storage
+	version, err := kvStore.CurrentVersion()
+	if err != nil {
+		return nil, 0, errors.Trace(err)
+	}
+	jobs, err := kv.LoadHistoryDDLJobs(kvStore)
+	if err != nil {
+		return nil, 0, errors.Trace(err)
+	}
+	schemaStorage, err := schema.NewMultiVersionStorage(jobs, false)
+	if err != nil {
+		return nil, 0, errors.Trace(err)
+	}
+	schemaStorage.AdvanceResolvedTs(version.Ver)
+	return schemaStorage, version.Ver, nil
+}
+
+// PullSchemaStorage pulls the DDL jobs finished after startTs into the schema storage
+func PullSchemaStorage(ctx context.Context, pdCli pd.Client, schemaStorage *schema.MultiVersionStorage, startTs uint64) error {
+	// The key in DDL kv pair returned from TiKV is already memcompariable encoded,
+	// so we set `needEncode` to false.
+	plr := puller.NewPuller(pdCli, startTs, []util.Span{util.GetDDLSpan()}, false)
+	mounter := entry.NewTxnMounter(nil)
+
+	errg, ctx := errgroup.WithContext(ctx)
+	errg.Go(func() error {
+		return plr.Run(ctx)
+	})
+	errg.Go(func() error {
+		err := plr.CollectRawTxns(ctx, func(ctx context.Context, rawTxn model.RawTxn) error {
+			// the txns without entries are fake txns to advance the resolved ts
+			if len(rawTxn.Entries) > 0 {
+				t, err := mounter.Mount(ctx, rawTxn)
+				if err != nil {
+					return errors.Trace(err)
+				}
+				if t.IsDDL() {
+					if err := schemaStorage.HandleDDLJob(t.DDL.Job); err != nil {
+						return errors.Trace(err)
+					}
+				}
+			}
+			schemaStorage.AdvanceResolvedTs(rawTxn.Ts)
+			return nil
+		})
+		return errors.Annotate(err, "span: ddl")
+	})
+	return errg.Wait()
+}
@@ -8,6 +8,8 @@ import (
 	"github.com/coreos/etcd/clientv3"
 	"github.com/coreos/etcd/embed"
 	"github.com/pingcap/check"
+	pmodel "github.com/pingcap/parser/model"
+	"github.com/pingcap/ticdc/cdc/kv"
 	"github.com/pingcap/ticdc/cdc/model"
 	"github.com/pingcap/ticdc/pkg/etcd"
 	"github.com/pingcap/ticdc/pkg/util"
@@ -154,3 +156,39 @@ func (ci *captureInfoSuite) TestWatch(c *check.C) {
 	watchCancel()
 	mustClosed()
 }
+
+func (ci *captureInfoSuite) TestMinCheckpointTs(c *check.C) {
+	ctx := context.Background()
+
+	ts, err := minCheckpointTs(ctx, ci.client)
+	c.Assert(err, check.IsNil)
+	c.Assert(ts, check.Equals, uint64(0))
+
+	// the changefeed which isn't started yet counts with its start ts
+	err = kv.SaveChangeFeedDetail(ctx, ci.client, &model.ChangeFeedDetail{StartTs: 100}, "cf-1")
+	c.Assert(err, check.IsNil)
+	err = kv.SaveChangeFeedDetail(ctx, ci.client, &model.ChangeFeedDetail{StartTs: 50}, "cf-2")
+	c.Assert(err, check.IsNil)
+	err = kv.PutChangeFeedStatus(ctx, ci.client, "cf-2", &model.ChangeFeedInfo{CheckpointTs: 200})
+	c.Assert(err, check.IsNil)
+	ts, err = minCheckpointTs(ctx, ci.client)
+	c.Assert(err, check.IsNil)
+	c.Assert(ts, check.Equals, uint64(100))
+
+	err = kv.PutChangeFeedStatus(ctx, ci.client, "cf-1", &model.ChangeFeedInfo{CheckpointTs: 300})
+	c.Assert(err, check.IsNil)
+	ts, err = minCheckpointTs(ctx, ci.client)
+	c.Assert(err, check.IsNil)
+	c.Assert(ts, check.Equals, uint64(200))
+
+	// the owner restores the schema before the pending DDL job
+	job := &pmodel.Job{BinlogInfo: &pmodel.HistoryInfo{FinishedTS: 200}}
+	err = kv.PutChangeFeedDDLState(ctx, ci.client, "cf-2", &model.ChangeFeedDDLState{
+		ResolvedTs: 250,
+		Jobs:       []*model.DDL{{Job: job}},
+	})
+	c.Assert(err, check.IsNil)
+	ts, err = minCheckpointTs(ctx, ci.client)
+	c.Assert(err, check.IsNil)
+	c.Assert(ts, check.Equals, uint64(199))
+}
@@ -57,7 +57,11 @@ func NewCDCSuite() *CDCSuite {
 
 	cdcSuite.sink = sink.NewMySQLSinkUsingSchema(db, schemaStorage)
 
-	mounter := entry.NewTxnMounter(schemaStorage)
+	multiVersionStorage, err := schema.NewMultiVersionStorage(jobs, false)
+	if err != nil {
+		panic(err.Error())
+	}
+	mounter := entry.NewTxnMounter(multiVersionStorage)
 	cdcSuite.mounter = mounter
 	return cdcSuite
 }
@@ -78,7 +82,7 @@ func (s *CDCSuite) RunAndCheckSync(c *C, execute func(func(string, ...interface{
 		rawKVs = append(rawKVs, kvs...)
 	}
 	execute(executeSQL)
-	txn, err := s.mounter.Mount(model.RawTxn{Ts: rawKVs[len(rawKVs)-1].Ts, Entries: rawKVs})
+	txn, err := s.mounter.Mount(context.Background(), model.RawTxn{Ts: rawKVs[len(rawKVs)-1].Ts, Entries: rawKVs})
 	c.Assert(err, IsNil)
 	err = s.sink.Emit(context.Background(), *txn)
 	c.Assert(err, IsNil)
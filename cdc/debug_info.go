@@ -0,0 +1,144 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cdc
+
+import (
+	"sort"
+
+	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/ticdc/cdc/schema"
+)
+
+// ownerChangefeedInfo is the owner's view of a changefeed
+type ownerChangefeedInfo struct {
+	ID     string                  `json:"id"`
+	Detail *model.ChangeFeedDetail `json:"detail"`
+	Info   *model.ChangeFeedInfo   `json:"info"`
+	// Status is the string of the model.ChangeFeedStatus
+	Status         string                      `json:"status"`
+	TargetTs       uint64                      `json:"target-ts"`
+	ProcessorInfos model.ProcessorsInfos       `json:"processor-infos"`
+	Tables         map[uint64]schema.TableName `json:"tables"`
+	OrphanTables   []model.ProcessTableInfo    `json:"orphan-tables"`
+	ToCleanTables  []uint64                    `json:"to-clean-tables"`
+	DDLResolvedTs  uint64                      `json:"ddl-resolved-ts"`
+	// DDLQueue is the DDL jobs which haven't been executed
+	DDLQueue []*ddlJobInfo `json:"ddl-queue"`
+}
+
+type ddlJobInfo struct {
+	ID         int64  `json:"id"`
+	Schema     string `json:"schema"`
+	Table      string `json:"table"`
+	Type       string `json:"type"`
+	Query      string `json:"query"`
+	FinishedTs uint64 `json:"finished-ts"`
+}
+
+// processorInfo is the processor's view of a changefeed on a capture
+type processorInfo struct {
+	ChangefeedID  string                   `json:"changefeed-id"`
+	CaptureID     string                   `json:"capture-id"`
+	SubInfo       *model.SubChangeFeedInfo `json:"sub-info"`
+	DDLResolvedTs uint64                   `json:"ddl-resolved-ts"`
+	Tables        []*tableResolvedTs       `json:"tables"`
+}
+
+type tableResolvedTs struct {
+	ID         int64  `json:"id"`
+	ResolvedTs uint64 `json:"resolved-ts"`
+}
+
+// debugInfo returns the owner's view of the changefeed, the owner lock must be held
+func (c *changeFeedInfo) debugInfo() *ownerChangefeedInfo {
+	info := &ownerChangefeedInfo{
+		ID:             c.ID,
+		Detail:         c.detail,
+		Status:         c.Status.String(),
+		TargetTs:       c.TargetTs,
+		ProcessorInfos: make(model.ProcessorsInfos, len(c.ProcessorInfos)),
+		Tables:         make(map[uint64]schema.TableName, len(c.tables)),
+		OrphanTables:   make([]model.ProcessTableInfo, 0, len(c.orphanTables)),
+		ToCleanTables:  make([]uint64, 0, len(c.toCleanTables)),
+		DDLResolvedTs:  c.ddlResolvedTs,
+		DDLQueue:       make([]*ddlJobInfo, 0),
+	}
+	if c.ChangeFeedInfo != nil {
+		cfInfo := *c.ChangeFeedInfo
+		info.Info = &cfInfo
+	}
+	for captureID, pinfo := range c.ProcessorInfos {
+		info.ProcessorInfos[captureID] = pinfo.Clone()
+	}
+	for id, table := range c.tables {
+		info.Tables[id] = table
+	}
+	for _, table := range c.orphanTables {
+		info.OrphanTables = append(info.OrphanTables, table)
+	}
+	sort.Slice(info.OrphanTables, func(i, j int) bool { return info.OrphanTables[i].ID < info.OrphanTables[j].ID })
+	for id := range c.toCleanTables {
+		info.ToCleanTables = append(info.ToCleanTables, id)
+	}
+	sort.Slice(info.ToCleanTables, func(i, j int) bool { return info.ToCleanTables[i] < info.ToCleanTables[j] })
+	for i := c.DDLCurrentIndex; i < len(c.ddlJobHistory); i++ {
+		ddl := c.ddlJobHistory[i]
+		job := &ddlJobInfo{
+			ID:     ddl.Job.ID,
+			Schema: ddl.Database,
+			Table:  ddl.Table,
+			Type:   ddl.Job.Type.String(),
+			Query:  ddl.Job.Query,
+		}
+		if ddl.Job.BinlogInfo != nil {
+			job.FinishedTs = ddl.Job.BinlogInfo.FinishedTS
+		}
+		info.DDLQueue = append(info.DDLQueue, job)
+	}
+	return info
+}
+
+// debugInfo returns the owner's view of all changefeeds
+func (o *ownerImpl) debugInfo() []*ownerChangefeedInfo {
+	o.l.RLock()
+	defer o.l.RUnlock()
+	infos := make([]*ownerChangefeedInfo, 0, len(o.changeFeedInfos))
+	for _, cfInfo := range o.changeFeedInfos {
+		infos = append(infos, cfInfo.debugInfo())
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ID < infos[j].ID })
+	return infos
+}
+
+// debugInfo returns the processor's view of the changefeed
+func (p *processor) debugInfo() *processorInfo {
+	info := &processorInfo{
+		ChangefeedID:  p.changefeedID,
+		CaptureID:     p.captureID,
+		DDLResolvedTs: p.schemaStorage.ResolvedTs(),
+	}
+	if p.subInfo != nil {
+		info.SubInfo = p.subInfo.Clone()
+	}
+
+	p.tablesMu.Lock()
+	info.Tables = make([]*tableResolvedTs, 0, len(p.tables))
+	for _, table := range p.tables {
+		info.Tables = append(info.Tables, &tableResolvedTs{ID: table.id, ResolvedTs: table.loadResolvedTS()})
+	}
+	p.tablesMu.Unlock()
+
+	sort.Slice(info.Tables, func(i, j int) bool { return info.Tables[i].ID < info.Tables[j].ID })
+	return info
+}
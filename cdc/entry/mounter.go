@@ -1,32 +1,61 @@
 package entry
 
 import (
+	"context"
+
 	"github.com/pingcap/errors"
 	"github.com/pingcap/log"
 	timodel "github.com/pingcap/parser/model"
 	"github.com/pingcap/parser/mysql"
 	"github.com/pingcap/ticdc/cdc/model"
 	"github.com/pingcap/ticdc/cdc/schema"
+	"github.com/pingcap/tidb/table"
 	"github.com/pingcap/tidb/types"
 	"go.uber.org/zap"
 )
 
-// Mounter is used to parse SQL events from KV events
+// SchemaGetter gets the tables of the rows to mount as of the commit ts of
+// the rows, such as schema.MultiVersionStorage. The DDL jobs finished at or
+// before the ts must have been handled.
+type SchemaGetter interface {
+	TableByID(id int64, ts uint64) (*timodel.TableInfo, bool)
+	SchemaAndTableName(id int64, ts uint64) (string, string, bool)
+}
+
+// Mounter is used to parse SQL events from KV events. The rows are decoded
+// with the tables as of their commit ts, so that the txns can be mounted out
+// of order and concurrently.
 type Mounter struct {
-	schemaStorage *schema.Storage
+	schemaStorage SchemaGetter
+	// fetcher is nil if old values are not needed
+	fetcher OldValueFetcher
 }
 
 // NewTxnMounter creates a mounter
-func NewTxnMounter(schema *schema.Storage) *Mounter {
+func NewTxnMounter(schema SchemaGetter) *Mounter {
 	return &Mounter{schemaStorage: schema}
 }
 
+// NewTxnMounterWithOldValue creates a mounter which mounts a changed row as an update
+// with all the old values fetched by fetcher, instead of a delete and a replace.
+func NewTxnMounterWithOldValue(schema SchemaGetter, fetcher OldValueFetcher) *Mounter {
+	return &Mounter{schemaStorage: schema, fetcher: fetcher}
+}
+
+// kvEntryWithKey is a row or index entry of a txn with its raw key
+type kvEntryWithKey struct {
+	key []byte
+	row *rowKVEntry
+	// dml is the delete mounted from a unique index entry
+	dml *model.DML
+}
+
 // Mount parses a raw transaction and returns a transaction
-func (m *Mounter) Mount(rawTxn model.RawTxn) (*model.Txn, error) {
+func (m *Mounter) Mount(ctx context.Context, rawTxn model.RawTxn) (*model.Txn, error) {
 	t := &model.Txn{
 		Ts: rawTxn.Ts,
 	}
-	var replaceDMLs, deleteDMLs []*model.DML
+	entries := make([]*kvEntryWithKey, 0, len(rawTxn.Entries))
 	for _, raw := range rawTxn.Entries {
 		kvEntry, err := unmarshal(raw)
 		if err != nil {
@@ -35,24 +64,14 @@ func (m *Mounter) Mount(rawTxn model.RawTxn) (*model.Txn, error) {
 
 		switch e := kvEntry.(type) {
 		case *rowKVEntry:
-			dml, err := m.mountRowKVEntry(e)
-			if err != nil {
-				return nil, errors.Trace(err)
-			}
-			if dml != nil {
-				if dml.Tp == model.InsertDMLType {
-					replaceDMLs = append(replaceDMLs, dml)
-				} else {
-					deleteDMLs = append(deleteDMLs, dml)
-				}
-			}
+			entries = append(entries, &kvEntryWithKey{key: raw.Key, row: e})
 		case *indexKVEntry:
 			dml, err := m.mountIndexKVEntry(e)
 			if err != nil {
 				return nil, errors.Trace(err)
 			}
 			if dml != nil {
-				deleteDMLs = append(deleteDMLs, dml)
+				entries = append(entries, &kvEntryWithKey{key: raw.Key, dml: dml})
 			}
 		case *ddlJobKVEntry:
 			t.DDL, err = m.mountDDL(e)
@@ -64,12 +83,66 @@ func (m *Mounter) Mount(rawTxn model.RawTxn) (*model.Txn, error) {
 			log.Warn("Found unknown kv entry", zap.Reflect("unknownKVEntry", e))
 		}
 	}
-	t.DMLs = append(deleteDMLs, replaceDMLs...)
+
+	var replaceDMLs, updateDMLs, deleteDMLs []*model.DML
+	appendDML := func(dml *model.DML) {
+		if dml == nil {
+			return
+		}
+		switch dml.Tp {
+		case model.InsertDMLType:
+			replaceDMLs = append(replaceDMLs, dml)
+		case model.UpdateDMLType:
+			updateDMLs = append(updateDMLs, dml)
+		default:
+			deleteDMLs = append(deleteDMLs, dml)
+		}
+	}
+	// only the rows with old values fetched are mounted as updates, all of them
+	// are fetched in a batch. Without the old values, an update is mounted as
+	// the deletes of its old keys and a replace of the new row.
+	var fetchRows []*kvEntryWithKey
+	for _, e := range entries {
+		switch {
+		case e.row != nil && m.fetcher != nil:
+			fetchRows = append(fetchRows, e)
+		case e.row != nil:
+			dml, err := m.mountRowKVEntry(e.row)
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+			appendDML(dml)
+		case m.fetcher != nil:
+			// the deleted index entries are described by the rows with
+			// old values
+		default:
+			appendDML(e.dml)
+		}
+	}
+	if len(fetchRows) > 0 {
+		keys := make([][]byte, 0, len(fetchRows))
+		for _, e := range fetchRows {
+			keys = append(keys, e.key)
+		}
+		oldValues, err := m.fetcher.FetchOldValues(ctx, keys, rawTxn.Ts)
+		if err != nil {
+			return nil, errors.Annotatef(err, "fetch old values of txn %d", rawTxn.Ts)
+		}
+		for _, e := range fetchRows {
+			dml, err := m.mountRowKVEntryWithOldValue(e.row, oldValues[string(e.key)])
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+			appendDML(dml)
+		}
+	}
+	t.DMLs = append(deleteDMLs, updateDMLs...)
+	t.DMLs = append(t.DMLs, replaceDMLs...)
 	return t, nil
 }
 
 func (m *Mounter) mountRowKVEntry(row *rowKVEntry) (*model.DML, error) {
-	tableInfo, tableName, err := m.fetchTableInfo(row.TableID)
+	tableInfo, tableName, err := m.fetchTableInfo(row.TableID, row.Ts)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
@@ -95,7 +168,77 @@ func (m *Mounter) mountRowKVEntry(row *rowKVEntry) (*model.DML, error) {
 		return nil, nil
 	}
 
-	values := make(map[string]types.Datum, len(row.Row)+1)
+	values, err := rowValues(tableInfo, row)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return &model.DML{
+		Database: tableName.Schema,
+		Table:    tableName.Table,
+		Tp:       model.InsertDMLType,
+		Values:   values,
+	}, nil
+}
+
+// mountRowKVEntryWithOldValue mounts a row put as an update if the row exists before
+// the txn, and a row delete as a delete with all the column values of the row.
+// oldValue is the value of the row before the txn, or nil if it didn't exist.
+func (m *Mounter) mountRowKVEntryWithOldValue(row *rowKVEntry, oldValue []byte) (*model.DML, error) {
+	if oldValue == nil {
+		return m.mountRowKVEntry(row)
+	}
+	tableInfo, tableName, err := m.fetchTableInfo(row.TableID, row.Ts)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	oldRow, err := decodeRow(oldValue)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	old := &rowKVEntry{
+		Ts:       row.Ts,
+		TableID:  row.TableID,
+		RecordID: row.RecordID,
+		Row:      oldRow,
+	}
+	if err := old.unflatten(tableInfo); err != nil {
+		return nil, errors.Trace(err)
+	}
+	oldValues, err := rowValues(tableInfo, old)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	if row.Delete {
+		return &model.DML{
+			Database: tableName.Schema,
+			Table:    tableName.Table,
+			Tp:       model.DeleteDMLType,
+			Values:   oldValues,
+		}, nil
+	}
+
+	if err := row.unflatten(tableInfo); err != nil {
+		return nil, errors.Trace(err)
+	}
+	values, err := rowValues(tableInfo, row)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return &model.DML{
+		Database:  tableName.Schema,
+		Table:     tableName.Table,
+		Tp:        model.UpdateDMLType,
+		Values:    values,
+		OldValues: oldValues,
+	}, nil
+}
+
+// rowValues returns the column values of an unflattened row, the columns not
+// stored in the row, like the ones added after the row is written, have their
+// default values.
+func rowValues(tableInfo *timodel.TableInfo, row *rowKVEntry) (map[string]types.Datum, error) {
+	values := make(map[string]types.Datum, len(tableInfo.Columns))
 	for index, colValue := range row.Row {
 		colName := tableInfo.Columns[index-1].Name.O
 		values[colName] = colValue
@@ -107,16 +250,40 @@ func (m *Mounter) mountRowKVEntry(row *rowKVEntry) (*model.DML, error) {
 		}
 		values[pkColName] = *pkValue
 	}
-	return &model.DML{
-		Database: tableName.Schema,
-		Table:    tableName.Table,
-		Tp:       model.InsertDMLType,
-		Values:   values,
-	}, nil
+	for _, col := range tableInfo.Columns {
+		if col.State != timodel.StatePublic || col.IsGenerated() {
+			continue
+		}
+		if _, ok := values[col.Name.O]; !ok {
+			values[col.Name.O] = getDefaultOrZeroValue(col)
+		}
+	}
+	return values, nil
+}
+
+func getDefaultOrZeroValue(col *timodel.ColumnInfo) types.Datum {
+	// see https://github.com/pingcap/tidb/issues/9304
+	// must use null if TiDB not write the column value when default value is null
+	// and the value is null
+	if !mysql.HasNotNullFlag(col.Flag) {
+		return types.NewDatum(nil)
+	}
+
+	if col.GetDefaultValue() != nil {
+		return types.NewDatum(col.GetDefaultValue())
+	}
+
+	if col.Tp == mysql.TypeEnum {
+		// For enum type, if no default value and not null is set,
+		// the default value is the first element of the enum list
+		return types.NewDatum(col.FieldType.Elems[0])
+	}
+
+	return table.GetZeroValue(col)
 }
 
 func (m *Mounter) mountIndexKVEntry(idx *indexKVEntry) (*model.DML, error) {
-	tableInfo, tableName, err := m.fetchTableInfo(idx.TableID)
+	tableInfo, tableName, err := m.fetchTableInfo(idx.TableID, idx.Ts)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
@@ -143,15 +310,16 @@ func (m *Mounter) mountIndexKVEntry(idx *indexKVEntry) (*model.DML, error) {
 	}, nil
 }
 
-func (m *Mounter) fetchTableInfo(tableID int64) (tableInfo *timodel.TableInfo, tableName *schema.TableName, err error) {
-	tableInfo, exist := m.schemaStorage.TableByID(tableID)
+// fetchTableInfo returns the table as of ts
+func (m *Mounter) fetchTableInfo(tableID int64, ts uint64) (tableInfo *timodel.TableInfo, tableName *schema.TableName, err error) {
+	tableInfo, exist := m.schemaStorage.TableByID(tableID, ts)
 	if !exist {
-		return nil, nil, errors.Errorf("can not find table, id: %d", tableID)
+		return nil, nil, errors.Errorf("can not find table, id: %d, ts: %d", tableID, ts)
 	}
 
-	database, table, exist := m.schemaStorage.SchemaAndTableName(tableID)
+	database, table, exist := m.schemaStorage.SchemaAndTableName(tableID, ts)
 	if !exist {
-		return nil, nil, errors.Errorf("can not find table, id: %d", tableID)
+		return nil, nil, errors.Errorf("can not find table, id: %d, ts: %d", tableID, ts)
 	}
 	tableName = &schema.TableName{Schema: database, Table: table}
 	return
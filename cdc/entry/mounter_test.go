@@ -19,17 +19,14 @@ type mountTxnsSuite struct{}
 
 var _ = check.Suite(&mountTxnsSuite{})
 
-func setUpPullerAndSchema(ctx context.Context, c *check.C, sqls ...string) (*puller.MockPullerManager, *schema.Storage) {
+func setUpPullerAndSchema(ctx context.Context, c *check.C, sqls ...string) (*puller.MockPullerManager, *schema.MultiVersionStorage) {
 	pm := puller.NewMockPullerManager(c)
 	go pm.Run(ctx)
 	for _, sql := range sqls {
 		pm.MustExec(sql)
 	}
 
-	jobs := pm.GetDDLJobs()
-	schemaStorage, err := schema.NewStorage(jobs, false)
-	c.Assert(err, check.IsNil)
-	err = schemaStorage.HandlePreviousDDLJobIfNeed(jobs[len(jobs)-1].BinlogInfo.FinishedTS)
+	schemaStorage, err := schema.NewMultiVersionStorage(pm.GetDDLJobs(), false)
 	c.Assert(err, check.IsNil)
 	return pm, schemaStorage
 }
@@ -65,7 +62,7 @@ func (cs *mountTxnsSuite) TestInsertPkNotHandle(c *check.C) {
 
 	pm.MustExec("insert into testDB.test1 values('ttt',6)")
 	rawTxn := getFirstRealTxn(ctx, c, plr)
-	t, err := mounter.Mount(rawTxn)
+	t, err := mounter.Mount(ctx, rawTxn)
 	c.Assert(err, check.IsNil)
 	cs.assertTableTxnEquals(c, t, &model.Txn{
 		Ts: rawTxn.Entries[0].Ts,
@@ -90,9 +87,10 @@ func (cs *mountTxnsSuite) TestInsertPkNotHandle(c *check.C) {
 		},
 	})
 
+	// without the old values, an update is mounted as deletes and a replace
 	pm.MustExec("update testDB.test1 set id = 'vvv' where a = 6")
 	rawTxn = getFirstRealTxn(ctx, c, plr)
-	t, err = mounter.Mount(rawTxn)
+	t, err = mounter.Mount(ctx, rawTxn)
 	c.Assert(err, check.IsNil)
 	cs.assertTableTxnEquals(c, t, &model.Txn{
 		Ts: rawTxn.Entries[0].Ts,
@@ -127,7 +125,7 @@ func (cs *mountTxnsSuite) TestInsertPkNotHandle(c *check.C) {
 
 	pm.MustExec("delete from testDB.test1 where a = 6")
 	rawTxn = getFirstRealTxn(ctx, c, plr)
-	t, err = mounter.Mount(rawTxn)
+	t, err = mounter.Mount(ctx, rawTxn)
 	c.Assert(err, check.IsNil)
 	cs.assertTableTxnEquals(c, t, &model.Txn{
 		Ts: rawTxn.Entries[0].Ts,
@@ -158,7 +156,7 @@ func (cs *mountTxnsSuite) TestInsertPkIsHandle(c *check.C) {
 
 	pm.MustExec("insert into testDB.test1 values(777,888)")
 	rawTxn := getFirstRealTxn(ctx, c, plr)
-	t, err := mounter.Mount(rawTxn)
+	t, err := mounter.Mount(ctx, rawTxn)
 	c.Assert(err, check.IsNil)
 	cs.assertTableTxnEquals(c, t, &model.Txn{
 		Ts: rawTxn.Entries[0].Ts,
@@ -185,7 +183,7 @@ func (cs *mountTxnsSuite) TestInsertPkIsHandle(c *check.C) {
 
 	pm.MustExec("update testDB.test1 set id = 999 where a = 888")
 	rawTxn = getFirstRealTxn(ctx, c, plr)
-	t, err = mounter.Mount(rawTxn)
+	t, err = mounter.Mount(ctx, rawTxn)
 	c.Assert(err, check.IsNil)
 	cs.assertTableTxnEquals(c, t, &model.Txn{
 		Ts: rawTxn.Entries[0].Ts,
@@ -220,7 +218,7 @@ func (cs *mountTxnsSuite) TestInsertPkIsHandle(c *check.C) {
 
 	pm.MustExec("delete from testDB.test1 where id = 999")
 	rawTxn = getFirstRealTxn(ctx, c, plr)
-	t, err = mounter.Mount(rawTxn)
+	t, err = mounter.Mount(ctx, rawTxn)
 	c.Assert(err, check.IsNil)
 	cs.assertTableTxnEquals(c, t, &model.Txn{
 		Ts: rawTxn.Entries[0].Ts,
@@ -259,7 +257,7 @@ func (cs *mountTxnsSuite) TestLargeInteger(c *check.C) {
 
 	pm.MustExec("insert into testDB.large_int values(?, ?)", uint64(math.MaxUint64), 123)
 	rawTxn := getFirstRealTxn(ctx, c, plr)
-	t, err := mounter.Mount(rawTxn)
+	t, err := mounter.Mount(ctx, rawTxn)
 	c.Assert(err, check.IsNil)
 	cs.assertTableTxnEquals(c, t, &model.Txn{
 		Ts: rawTxn.Entries[0].Ts,
@@ -289,7 +287,7 @@ func (cs *mountTxnsSuite) TestLargeInteger(c *check.C) {
 
 	pm.MustExec("insert into testDB.large_int values(?, ?)", int64(math.MinInt64), 123)
 	rawTxn = getFirstRealTxn(ctx, c, plr)
-	t, err = mounter.Mount(rawTxn)
+	t, err = mounter.Mount(ctx, rawTxn)
 	c.Assert(err, check.IsNil)
 	cs.assertTableTxnEquals(c, t, &model.Txn{
 		Ts: rawTxn.Entries[0].Ts,
@@ -308,6 +306,161 @@ func (cs *mountTxnsSuite) TestLargeInteger(c *check.C) {
 
 }
 
+func (cs *mountTxnsSuite) TestMountWithOldValue(c *check.C) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pm, schema := setUpPullerAndSchema(ctx, c,
+		"create database testDB",
+		"create table testDB.test1(id int primary key, a int unique key, b int)",
+	)
+	tableInfo := pm.GetTableInfo("testDB", "test1")
+	tableID := tableInfo.ID
+	mounter := NewTxnMounterWithOldValue(schema, NewSnapshotOldValueFetcher(pm.GetStorage()))
+	plr := pm.CreatePuller(0, []util.Span{util.GetTableSpan(tableID, false)})
+
+	pm.MustExec("insert into testDB.test1 values(1,2,3)")
+	rawTxn := getFirstRealTxn(ctx, c, plr)
+	t, err := mounter.Mount(ctx, rawTxn)
+	c.Assert(err, check.IsNil)
+	cs.assertTableTxnEquals(c, t, &model.Txn{
+		Ts: rawTxn.Entries[0].Ts,
+		DMLs: []*model.DML{
+			{
+				Database: "testDB",
+				Table:    "test1",
+				Tp:       model.InsertDMLType,
+				Values: map[string]types.Datum{
+					"id": types.NewIntDatum(1),
+					"a":  types.NewIntDatum(2),
+					"b":  types.NewIntDatum(3),
+				},
+			},
+		},
+	})
+
+	pm.MustExec("update testDB.test1 set a = 4 where id = 1")
+	rawTxn = getFirstRealTxn(ctx, c, plr)
+	t, err = mounter.Mount(ctx, rawTxn)
+	c.Assert(err, check.IsNil)
+	cs.assertTableTxnEquals(c, t, &model.Txn{
+		Ts: rawTxn.Entries[0].Ts,
+		DMLs: []*model.DML{
+			{
+				Database: "testDB",
+				Table:    "test1",
+				Tp:       model.UpdateDMLType,
+				Values: map[string]types.Datum{
+					"id": types.NewIntDatum(1),
+					"a":  types.NewIntDatum(4),
+					"b":  types.NewIntDatum(3),
+				},
+				OldValues: map[string]types.Datum{
+					"id": types.NewIntDatum(1),
+					"a":  types.NewIntDatum(2),
+					"b":  types.NewIntDatum(3),
+				},
+			},
+		},
+	})
+
+	pm.MustExec("delete from testDB.test1 where id = 1")
+	rawTxn = getFirstRealTxn(ctx, c, plr)
+	t, err = mounter.Mount(ctx, rawTxn)
+	c.Assert(err, check.IsNil)
+	cs.assertTableTxnEquals(c, t, &model.Txn{
+		Ts: rawTxn.Entries[0].Ts,
+		DMLs: []*model.DML{
+			{
+				Database: "testDB",
+				Table:    "test1",
+				Tp:       model.DeleteDMLType,
+				Values: map[string]types.Datum{
+					"id": types.NewIntDatum(1),
+					"a":  types.NewIntDatum(4),
+					"b":  types.NewIntDatum(3),
+				},
+			},
+		},
+	})
+}
+
+func (cs *mountTxnsSuite) TestMountWithSchemaVersions(c *check.C) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pm, schemaStorage := setUpPullerAndSchema(ctx, c,
+		"create database testDB",
+		"create table testDB.test1(id int primary key, a int)",
+	)
+	tableInfo := pm.GetTableInfo("testDB", "test1")
+	mounter := NewTxnMounter(schemaStorage)
+	plr := pm.CreatePuller(0, []util.Span{util.GetTableSpan(tableInfo.ID, false)})
+
+	pm.MustExec("insert into testDB.test1 values(1, 2)")
+	rawTxn1 := getFirstRealTxn(ctx, c, plr)
+	pm.MustExec("alter table testDB.test1 add column b int not null default 3")
+	for _, job := range pm.GetDDLJobs() {
+		c.Assert(schemaStorage.HandleDDLJob(job), check.IsNil)
+	}
+	pm.MustExec("insert into testDB.test1 values(4, 5, 6)")
+	rawTxn2 := getFirstRealTxn(ctx, c, plr)
+
+	// the column added after the first txn isn't filled with its default value
+	expected1 := func() *model.Txn {
+		return &model.Txn{
+			Ts: rawTxn1.Ts,
+			DMLs: []*model.DML{{
+				Database: "testDB",
+				Table:    "test1",
+				Tp:       model.InsertDMLType,
+				Values: map[string]types.Datum{
+					"id": types.NewIntDatum(1),
+					"a":  types.NewIntDatum(2),
+				},
+			}},
+		}
+	}
+	expected2 := func() *model.Txn {
+		return &model.Txn{
+			Ts: rawTxn2.Ts,
+			DMLs: []*model.DML{{
+				Database: "testDB",
+				Table:    "test1",
+				Tp:       model.InsertDMLType,
+				Values: map[string]types.Datum{
+					"id": types.NewIntDatum(4),
+					"a":  types.NewIntDatum(5),
+					"b":  types.NewIntDatum(6),
+				},
+			}},
+		}
+	}
+
+	// the txns are mounted out of order and concurrently with the tables as of their commit ts
+	var wg sync.WaitGroup
+	results := make([]*model.Txn, 4)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			rawTxn := rawTxn2
+			if i%2 == 1 {
+				rawTxn = rawTxn1
+			}
+			t, err := mounter.Mount(ctx, rawTxn)
+			c.Check(err, check.IsNil)
+			results[i] = t
+		}(i)
+	}
+	wg.Wait()
+	for i, t := range results {
+		if i%2 == 1 {
+			cs.assertTableTxnEquals(c, t, expected1())
+		} else {
+			cs.assertTableTxnEquals(c, t, expected2())
+		}
+	}
+}
+
 func (cs *mountTxnsSuite) assertTableTxnEquals(c *check.C,
 	obtained, expected *model.Txn) {
 	obtainedDMLs := obtained.DMLs
@@ -0,0 +1,51 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package entry
+
+import (
+	"context"
+
+	"github.com/pingcap/errors"
+	tidbkv "github.com/pingcap/tidb/kv"
+)
+
+// OldValueFetcher fetches the values of keys before they're changed by a txn
+type OldValueFetcher interface {
+	// FetchOldValues returns the values of the keys before the txn committed at
+	// commitTs, the keys which don't exist then are not in the result.
+	FetchOldValues(ctx context.Context, keys [][]byte, commitTs uint64) (map[string][]byte, error)
+}
+
+type snapshotOldValueFetcher struct {
+	store tidbkv.Storage
+}
+
+// NewSnapshotOldValueFetcher creates a OldValueFetcher reading the snapshot of
+// commitTs-1 in TiKV, the snapshot must not be garbage collected.
+func NewSnapshotOldValueFetcher(store tidbkv.Storage) OldValueFetcher {
+	return &snapshotOldValueFetcher{store: store}
+}
+
+func (f *snapshotOldValueFetcher) FetchOldValues(ctx context.Context, keys [][]byte, commitTs uint64) (map[string][]byte, error) {
+	snapshot, err := f.store.GetSnapshot(tidbkv.NewVersion(commitTs - 1))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	kvKeys := make([]tidbkv.Key, 0, len(keys))
+	for _, key := range keys {
+		kvKeys = append(kvKeys, key)
+	}
+	values, err := snapshot.BatchGet(ctx, kvKeys)
+	return values, errors.Trace(err)
+}
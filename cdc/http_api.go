@@ -0,0 +1,340 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cdc
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"github.com/pingcap/ticdc/cdc/kv"
+	"github.com/pingcap/ticdc/cdc/model"
+	"go.uber.org/zap"
+)
+
+const (
+	apiPrefix = "/api/v1"
+	// forwardFromHeader is set to the ID of the capture forwarding the request to the owner
+	forwardFromHeader = "X-CDC-Forward-From"
+)
+
+// changefeedItem is the item of the changefeed list
+type changefeedItem struct {
+	ID string `json:"id"`
+}
+
+// changefeedStatus is the detail, status and processors of a changefeed
+type changefeedStatus struct {
+	Detail     *model.ChangeFeedDetail `json:"detail"`
+	Status     *model.ChangeFeedInfo   `json:"status"`
+	Processors model.ProcessorsInfos   `json:"processors"`
+}
+
+// createChangefeedRequest is the body of the request creating a changefeed,
+// a random UUID is used if the ID is empty.
+type createChangefeedRequest struct {
+	ID string `json:"changefeed-id"`
+	model.ChangeFeedDetail
+}
+
+type captureItem struct {
+	ID            string `json:"id"`
+	AdvertiseAddr string `json:"address"`
+	IsOwner       bool   `json:"is-owner"`
+}
+
+type processorItem struct {
+	ChangefeedID string `json:"changefeed-id"`
+	CaptureID    string `json:"capture-id"`
+}
+
+func (s *Server) registerAPI(serverMux *http.ServeMux) {
+	serverMux.HandleFunc(apiPrefix+"/changefeeds", s.forwardToOwner(s.handleChangefeeds))
+	serverMux.HandleFunc(apiPrefix+"/changefeeds/", s.forwardToOwner(s.handleChangefeed))
+	serverMux.HandleFunc(apiPrefix+"/captures", s.forwardToOwner(s.handleCaptures))
+	serverMux.HandleFunc(apiPrefix+"/processors", s.forwardToOwner(s.handleProcessors))
+	serverMux.HandleFunc(apiPrefix+"/processors/", s.forwardToOwner(s.handleProcessor))
+}
+
+// forwardToOwner serves the requests by the handler on the owner, and forwards
+// them to the owner on the other captures.
+func (s *Server) forwardToOwner(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if s.capture.ownerManager.IsOwner() {
+			handler(w, req)
+			return
+		}
+		// the owner may be changed after forwarding, don't forward the request again
+		if from := req.Header.Get(forwardFromHeader); from != "" {
+			writeError(w, http.StatusServiceUnavailable, errors.Errorf("capture %s isn't the owner, the request is forwarded from %s", s.capture.info.ID, from))
+			return
+		}
+		ownerID, err := s.capture.ownerManager.GetOwnerID(req.Context())
+		if err != nil {
+			writeError(w, http.StatusServiceUnavailable, errors.Annotate(err, "get owner"))
+			return
+		}
+		info, err := GetCaptureInfo(req.Context(), ownerID, s.capture.etcdClient)
+		if err != nil {
+			writeError(w, http.StatusServiceUnavailable, errors.Annotatef(err, "get owner %s", ownerID))
+			return
+		}
+		log.Debug("forward request to owner", zap.String("owner", ownerID), zap.String("address", info.AdvertiseAddr), zap.String("url", req.URL.String()))
+		req.Header.Set(forwardFromHeader, s.capture.info.ID)
+		proxy := httputil.NewSingleHostReverseProxy(&url.URL{Scheme: "http", Host: info.AdvertiseAddr})
+		proxy.ServeHTTP(w, req)
+	}
+}
+
+// handleChangefeeds handles `GET /changefeeds` listing the changefeeds and
+// `POST /changefeeds` creating a changefeed
+func (s *Server) handleChangefeeds(w http.ResponseWriter, req *http.Request) {
+	cli := s.capture.etcdClient
+	switch req.Method {
+	case http.MethodGet:
+		_, details, err := kv.GetChangeFeeds(req.Context(), cli)
+		if err != nil {
+			writeInternalServerError(w, err)
+			return
+		}
+		changefeeds := make([]*changefeedItem, 0, len(details))
+		for id := range details {
+			changefeeds = append(changefeeds, &changefeedItem{ID: id})
+		}
+		sort.Slice(changefeeds, func(i, j int) bool { return changefeeds[i].ID < changefeeds[j].ID })
+		writeData(w, changefeeds)
+	case http.MethodPost:
+		r := &createChangefeedRequest{}
+		if err := json.NewDecoder(req.Body).Decode(r); err != nil {
+			writeError(w, http.StatusBadRequest, errors.Annotate(err, "invalid request body"))
+			return
+		}
+		detail := &r.ChangeFeedDetail
+		detail.CreateTime = time.Now()
+		if detail.Opts == nil {
+			detail.Opts = make(map[string]string)
+		}
+		if err := detail.Validate(); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if r.ID == "" {
+			r.ID = uuid.New().String()
+		} else if _, err := kv.GetChangeFeedDetail(req.Context(), cli, r.ID); err == nil {
+			writeError(w, http.StatusConflict, errors.Errorf("changefeed %s already exists", r.ID))
+			return
+		} else if errors.Cause(err) != model.ErrChangeFeedNotExists {
+			writeInternalServerError(w, err)
+			return
+		}
+		if err := kv.CheckStartTs(req.Context(), cli, detail.GetStartTs()); err != nil {
+			if errors.Cause(err) == model.ErrStartTsBeforeSchemaGC {
+				writeError(w, http.StatusBadRequest, err)
+			} else {
+				writeInternalServerError(w, err)
+			}
+			return
+		}
+		if err := kv.SaveChangeFeedDetail(req.Context(), cli, detail, r.ID); err != nil {
+			writeInternalServerError(w, err)
+			return
+		}
+		log.Info("create changefeed by API", zap.String("changefeed-id", r.ID), zap.String("sink-uri", detail.SinkURI))
+		writeData(w, &changefeedItem{ID: r.ID})
+	default:
+		writeError(w, http.StatusMethodNotAllowed, errors.Errorf("method %s isn't allowed", req.Method))
+	}
+}
+
+// ddlOperationRequest is the body of the request skipping or replacing the
+// pending or failed DDL job of a changefeed
+type ddlOperationRequest struct {
+	DDLJobID int64  `json:"ddl-job-id"`
+	Query    string `json:"query"`
+}
+
+// handleChangefeed handles `GET /changefeeds/{id}` querying a changefeed,
+// `DELETE /changefeeds/{id}` removing it, `POST /changefeeds/{id}/pause`
+// and `POST /changefeeds/{id}/resume` pausing and resuming it,
+// `POST /changefeeds/{id}/skip-ddl` and `POST /changefeeds/{id}/replace-ddl`
+// skipping and replacing its DDL job, and `GET /changefeeds/{id}/ddl-audit`
+// listing the operations on its DDL jobs
+func (s *Server) handleChangefeed(w http.ResponseWriter, req *http.Request) {
+	cli := s.capture.etcdClient
+	parts := strings.Split(strings.TrimPrefix(req.URL.Path, apiPrefix+"/changefeeds/"), "/")
+	id := parts[0]
+	if id == "" || len(parts) > 2 {
+		writeError(w, http.StatusNotFound, errors.Errorf("invalid path %s", req.URL.Path))
+		return
+	}
+	detail, err := kv.GetChangeFeedDetail(req.Context(), cli, id)
+	if err != nil {
+		writeKVError(w, err)
+		return
+	}
+
+	job := &model.AdminJob{CfID: id}
+	switch {
+	case len(parts) == 1 && req.Method == http.MethodGet:
+		// the status is missing until the owner starts the changefeed
+		info, err := kv.GetChangeFeedInfo(req.Context(), cli, id)
+		if err != nil && errors.Cause(err) != model.ErrChangeFeedNotExists {
+			writeInternalServerError(w, err)
+			return
+		}
+		pinfos, err := kv.GetSubChangeFeedInfos(req.Context(), cli, id)
+		if err != nil {
+			writeInternalServerError(w, err)
+			return
+		}
+		writeData(w, &changefeedStatus{Detail: detail, Status: info, Processors: pinfos})
+		return
+	case len(parts) == 1 && req.Method == http.MethodDelete:
+		job.Type = model.AdminRemove
+	case len(parts) == 2 && parts[1] == "pause" && req.Method == http.MethodPost:
+		job.Type = model.AdminStop
+	case len(parts) == 2 && parts[1] == "resume" && req.Method == http.MethodPost:
+		job.Type = model.AdminResume
+	case len(parts) == 2 && (parts[1] == "skip-ddl" || parts[1] == "replace-ddl") && req.Method == http.MethodPost:
+		r := &ddlOperationRequest{}
+		if err := json.NewDecoder(req.Body).Decode(r); err != nil && err != io.EOF {
+			writeError(w, http.StatusBadRequest, errors.Annotate(err, "invalid request body"))
+			return
+		}
+		job.Type = model.AdminSkipDDL
+		if parts[1] == "replace-ddl" {
+			if r.Query == "" {
+				writeError(w, http.StatusBadRequest, errors.New("query is required"))
+				return
+			}
+			job.Type = model.AdminReplaceDDL
+			job.Query = r.Query
+		}
+		job.DDLJobID = r.DDLJobID
+	case len(parts) == 2 && parts[1] == "ddl-audit" && req.Method == http.MethodGet:
+		records, err := kv.GetDDLAuditRecords(req.Context(), cli, id)
+		if err != nil {
+			writeInternalServerError(w, err)
+			return
+		}
+		writeData(w, records)
+		return
+	case len(parts) == 2 && parts[1] != "pause" && parts[1] != "resume" &&
+		parts[1] != "skip-ddl" && parts[1] != "replace-ddl" && parts[1] != "ddl-audit":
+		writeError(w, http.StatusNotFound, errors.Errorf("invalid path %s", req.URL.Path))
+		return
+	default:
+		writeError(w, http.StatusMethodNotAllowed, errors.Errorf("method %s isn't allowed", req.Method))
+		return
+	}
+	// the admin job is applied by the owner asynchronously
+	if err := kv.PutAdminJob(req.Context(), cli, job); err != nil {
+		writeInternalServerError(w, err)
+		return
+	}
+	log.Info("put admin job by API", zap.String("changefeed-id", id), zap.Stringer("type", job.Type))
+	writeData(w, job)
+}
+
+// handleCaptures handles `GET /captures` listing the captures
+func (s *Server) handleCaptures(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, errors.Errorf("method %s isn't allowed", req.Method))
+		return
+	}
+	_, infos, err := kv.GetCaptures(req.Context(), s.capture.etcdClient)
+	if err != nil {
+		writeInternalServerError(w, err)
+		return
+	}
+	captures := make([]*captureItem, 0, len(infos))
+	for _, info := range infos {
+		captures = append(captures, &captureItem{
+			ID:            info.ID,
+			AdvertiseAddr: info.AdvertiseAddr,
+			IsOwner:       info.ID == s.capture.info.ID,
+		})
+	}
+	writeData(w, captures)
+}
+
+// handleProcessors handles `GET /processors` listing the processors
+func (s *Server) handleProcessors(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, errors.Errorf("method %s isn't allowed", req.Method))
+		return
+	}
+	cli := s.capture.etcdClient
+	_, details, err := kv.GetChangeFeeds(req.Context(), cli)
+	if err != nil {
+		writeInternalServerError(w, err)
+		return
+	}
+	processors := make([]*processorItem, 0, len(details))
+	for id := range details {
+		pinfos, err := kv.GetSubChangeFeedInfos(req.Context(), cli, id)
+		if err != nil {
+			writeInternalServerError(w, err)
+			return
+		}
+		for captureID := range pinfos {
+			processors = append(processors, &processorItem{ChangefeedID: id, CaptureID: captureID})
+		}
+	}
+	sort.Slice(processors, func(i, j int) bool {
+		if processors[i].ChangefeedID != processors[j].ChangefeedID {
+			return processors[i].ChangefeedID < processors[j].ChangefeedID
+		}
+		return processors[i].CaptureID < processors[j].CaptureID
+	})
+	writeData(w, processors)
+}
+
+// handleProcessor handles `GET /processors/{changefeed-id}/{capture-id}`
+// querying the tables and progress of a processor
+func (s *Server) handleProcessor(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, errors.Errorf("method %s isn't allowed", req.Method))
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(req.URL.Path, apiPrefix+"/processors/"), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		writeError(w, http.StatusNotFound, errors.Errorf("invalid path %s", req.URL.Path))
+		return
+	}
+	_, info, err := kv.GetSubChangeFeedInfo(req.Context(), s.capture.etcdClient, parts[0], parts[1])
+	if err != nil {
+		writeKVError(w, err)
+		return
+	}
+	writeData(w, info)
+}
+
+// writeKVError writes StatusNotFound for the errors of missing keys
+func writeKVError(w http.ResponseWriter, err error) {
+	switch errors.Cause(err) {
+	case model.ErrChangeFeedNotExists, model.ErrSubChangeFeedInfoNotExists:
+		writeError(w, http.StatusNotFound, err)
+	default:
+		writeInternalServerError(w, err)
+	}
+}
@@ -0,0 +1,301 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cdc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/coreos/etcd/clientv3"
+	"github.com/coreos/etcd/embed"
+	"github.com/phayes/freeport"
+	"github.com/pingcap/check"
+	timodel "github.com/pingcap/parser/model"
+	"github.com/pingcap/ticdc/cdc/kv"
+	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/ticdc/cdc/roles"
+	"github.com/pingcap/ticdc/cdc/schema"
+	"github.com/pingcap/ticdc/pkg/etcd"
+	"github.com/pingcap/ticdc/pkg/util"
+	"golang.org/x/sync/errgroup"
+)
+
+type httpAPISuite struct {
+	e         *embed.Etcd
+	clientURL *url.URL
+	client    *clientv3.Client
+	ctx       context.Context
+	cancel    context.CancelFunc
+	errg      *errgroup.Group
+
+	owner    *Server
+	follower *Server
+}
+
+var _ = check.Suite(&httpAPISuite{})
+
+// fixedOwnerManager is a Manager which knows the ID of the owner
+type fixedOwnerManager struct {
+	roles.Manager
+	ownerID string
+}
+
+func (m *fixedOwnerManager) GetOwnerID(ctx context.Context) (string, error) {
+	return m.ownerID, nil
+}
+
+func (s *httpAPISuite) newServer(c *check.C, id string, isOwner bool) *Server {
+	port, err := freeport.GetFreePort()
+	c.Assert(err, check.IsNil)
+	manager := &fixedOwnerManager{Manager: roles.NewMockManager(id, func() {}), ownerID: "owner"}
+	if isOwner {
+		c.Assert(manager.CampaignOwner(s.ctx), check.IsNil)
+	}
+	server := &Server{
+		opts: options{statusHost: "127.0.0.1", statusPort: port},
+		capture: &Capture{
+			etcdClient:   s.client,
+			ownerManager: manager,
+			info:         &model.CaptureInfo{ID: id, AdvertiseAddr: fmt.Sprintf("127.0.0.1:%d", port)},
+		},
+	}
+	c.Assert(PutCaptureInfo(s.ctx, server.capture.info, s.client), check.IsNil)
+	server.startStatusHTTP()
+	for i := 0; i < retryTime; i++ {
+		resp, err := http.Get(fmt.Sprintf("http://%s/status", server.capture.info.AdvertiseAddr))
+		if err == nil {
+			resp.Body.Close()
+			return server
+		}
+		time.Sleep(time.Millisecond * 50)
+	}
+	c.Fatalf("failed to connect http status for %d retries in every 50ms", retryTime)
+	return nil
+}
+
+func (s *httpAPISuite) SetUpTest(c *check.C) {
+	dir := c.MkDir()
+	var err error
+	s.clientURL, s.e, err = etcd.SetupEmbedEtcd(dir)
+	c.Assert(err, check.IsNil)
+	s.client, err = clientv3.New(clientv3.Config{
+		Endpoints:   []string{s.clientURL.String()},
+		DialTimeout: 3 * time.Second,
+	})
+	c.Assert(err, check.IsNil)
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	s.errg = util.HandleErrWithErrGroup(s.ctx, s.e.Err(), func(e error) { c.Log(e) })
+
+	s.owner = s.newServer(c, "owner", true)
+	s.follower = s.newServer(c, "follower", false)
+}
+
+func (s *httpAPISuite) TearDownTest(c *check.C) {
+	c.Assert(s.owner.statusServer.Close(), check.IsNil)
+	c.Assert(s.follower.statusServer.Close(), check.IsNil)
+	s.client.Close()
+	s.e.Close()
+	s.cancel()
+	err := s.errg.Wait()
+	if err != nil {
+		c.Errorf("Error group error: %s", err)
+	}
+}
+
+// request sends the request to the follower, which forwards it to the owner
+func (s *httpAPISuite) request(c *check.C, method, path, body string) (int, []byte) {
+	url := fmt.Sprintf("http://%s%s%s", s.follower.capture.info.AdvertiseAddr, apiPrefix, path)
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	c.Assert(err, check.IsNil)
+	resp, err := http.DefaultClient.Do(req)
+	c.Assert(err, check.IsNil)
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	c.Assert(err, check.IsNil)
+	return resp.StatusCode, data
+}
+
+func (s *httpAPISuite) TestChangefeedAPI(c *check.C) {
+	code, data := s.request(c, http.MethodPost, "/changefeeds", `{"changefeed-id": "test", "sink-uri": "blackhole://", "start-ts": 100}`)
+	c.Assert(code, check.Equals, http.StatusOK, check.Commentf("%s", data))
+	code, _ = s.request(c, http.MethodPost, "/changefeeds", `{"changefeed-id": "test", "sink-uri": "blackhole://"}`)
+	c.Assert(code, check.Equals, http.StatusConflict)
+	code, _ = s.request(c, http.MethodPost, "/changefeeds", `{"changefeed-id": "test2"}`)
+	c.Assert(code, check.Equals, http.StatusBadRequest)
+	code, _ = s.request(c, http.MethodPost, "/changefeeds", `{"changefeed-id": "test2", "sink-uri": "blackhole://", "start-ts": 100, "target-ts": 10}`)
+	c.Assert(code, check.Equals, http.StatusBadRequest)
+	// the schema versions before the gc ts may be removed by the captures
+	c.Assert(kv.AdvanceSchemaGCTs(context.Background(), s.client, 50), check.IsNil)
+	code, _ = s.request(c, http.MethodPost, "/changefeeds", `{"changefeed-id": "test2", "sink-uri": "blackhole://", "start-ts": 40}`)
+	c.Assert(code, check.Equals, http.StatusBadRequest)
+
+	code, data = s.request(c, http.MethodGet, "/changefeeds", "")
+	c.Assert(code, check.Equals, http.StatusOK)
+	var changefeeds []*changefeedItem
+	c.Assert(json.Unmarshal(data, &changefeeds), check.IsNil)
+	c.Assert(changefeeds, check.DeepEquals, []*changefeedItem{{ID: "test"}})
+
+	code, data = s.request(c, http.MethodGet, "/changefeeds/test", "")
+	c.Assert(code, check.Equals, http.StatusOK)
+	status := &changefeedStatus{}
+	c.Assert(json.Unmarshal(data, status), check.IsNil)
+	c.Assert(status.Detail.SinkURI, check.Equals, "blackhole://")
+	c.Assert(status.Detail.StartTs, check.Equals, uint64(100))
+	c.Assert(status.Status, check.IsNil)
+	code, _ = s.request(c, http.MethodGet, "/changefeeds/not-exist", "")
+	c.Assert(code, check.Equals, http.StatusNotFound)
+
+	code, _ = s.request(c, http.MethodPost, "/changefeeds/test/pause", "")
+	c.Assert(code, check.Equals, http.StatusOK)
+	jobs, err := kv.GetAdminJobs(s.ctx, s.client)
+	c.Assert(err, check.IsNil)
+	c.Assert(jobs, check.HasLen, 1)
+	c.Assert(jobs[0].Type, check.Equals, model.AdminStop)
+	code, _ = s.request(c, http.MethodDelete, "/changefeeds/test", "")
+	c.Assert(code, check.Equals, http.StatusOK)
+	jobs, err = kv.GetAdminJobs(s.ctx, s.client)
+	c.Assert(err, check.IsNil)
+	c.Assert(jobs[0].Type, check.Equals, model.AdminRemove)
+
+	code, _ = s.request(c, http.MethodPost, "/changefeeds/test/replace-ddl", `{"ddl-job-id": 10}`)
+	c.Assert(code, check.Equals, http.StatusBadRequest)
+	code, _ = s.request(c, http.MethodPost, "/changefeeds/test/replace-ddl", `{"ddl-job-id": 10, "query": "select 1"}`)
+	c.Assert(code, check.Equals, http.StatusOK)
+	jobs, err = kv.GetAdminJobs(s.ctx, s.client)
+	c.Assert(err, check.IsNil)
+	c.Assert(jobs[0], check.DeepEquals, &model.AdminJob{CfID: "test", Type: model.AdminReplaceDDL, DDLJobID: 10, Query: "select 1", ModRevision: jobs[0].ModRevision})
+	code, _ = s.request(c, http.MethodPost, "/changefeeds/test/skip-ddl", "")
+	c.Assert(code, check.Equals, http.StatusOK)
+	jobs, err = kv.GetAdminJobs(s.ctx, s.client)
+	c.Assert(err, check.IsNil)
+	c.Assert(jobs[0].Type, check.Equals, model.AdminSkipDDL)
+	c.Assert(jobs[0].DDLJobID, check.Equals, int64(0))
+	code, data = s.request(c, http.MethodGet, "/changefeeds/test/ddl-audit", "")
+	c.Assert(code, check.Equals, http.StatusOK)
+	c.Assert(strings.TrimSpace(string(data)), check.Equals, "[]")
+
+	code, _ = s.request(c, http.MethodGet, "/changefeeds/test/resume", "")
+	c.Assert(code, check.Equals, http.StatusMethodNotAllowed)
+	code, _ = s.request(c, http.MethodPost, "/changefeeds/test/stop", "")
+	c.Assert(code, check.Equals, http.StatusNotFound)
+}
+
+func (s *httpAPISuite) TestCaptureAndProcessorAPI(c *check.C) {
+	code, data := s.request(c, http.MethodGet, "/captures", "")
+	c.Assert(code, check.Equals, http.StatusOK)
+	var captures []*captureItem
+	c.Assert(json.Unmarshal(data, &captures), check.IsNil)
+	c.Assert(captures, check.HasLen, 2)
+	for _, capture := range captures {
+		c.Assert(capture.IsOwner, check.Equals, capture.ID == "owner")
+	}
+
+	detail := &model.ChangeFeedDetail{SinkURI: "blackhole://"}
+	c.Assert(kv.SaveChangeFeedDetail(s.ctx, s.client, detail, "test"), check.IsNil)
+	info := &model.SubChangeFeedInfo{CheckPointTs: 10, ResolvedTs: 20}
+	c.Assert(kv.PutSubChangeFeedInfo(s.ctx, s.client, "test", "follower", info), check.IsNil)
+
+	code, data = s.request(c, http.MethodGet, "/processors", "")
+	c.Assert(code, check.Equals, http.StatusOK)
+	var processors []*processorItem
+	c.Assert(json.Unmarshal(data, &processors), check.IsNil)
+	c.Assert(processors, check.DeepEquals, []*processorItem{{ChangefeedID: "test", CaptureID: "follower"}})
+
+	code, data = s.request(c, http.MethodGet, "/processors/test/follower", "")
+	c.Assert(code, check.Equals, http.StatusOK)
+	queried := &model.SubChangeFeedInfo{}
+	c.Assert(json.Unmarshal(data, queried), check.IsNil)
+	c.Assert(queried.ResolvedTs, check.Equals, uint64(20))
+	code, _ = s.request(c, http.MethodGet, "/processors/test/owner", "")
+	c.Assert(code, check.Equals, http.StatusNotFound)
+}
+
+func (s *httpAPISuite) TestForwardOnce(c *check.C) {
+	// the request forwarded to a capture which isn't the owner is rejected
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("http://%s%s/captures", s.follower.capture.info.AdvertiseAddr, apiPrefix), nil)
+	c.Assert(err, check.IsNil)
+	req.Header.Set(forwardFromHeader, "owner")
+	resp, err := http.DefaultClient.Do(req)
+	c.Assert(err, check.IsNil)
+	resp.Body.Close()
+	c.Assert(resp.StatusCode, check.Equals, http.StatusServiceUnavailable)
+}
+
+func (s *httpAPISuite) TestDebugChangefeeds(c *check.C) {
+	s.owner.capture.ownerWorker = &ownerImpl{changeFeedInfos: map[model.ChangeFeedID]*changeFeedInfo{
+		"test": {
+			ID:             "test",
+			detail:         &model.ChangeFeedDetail{SinkURI: "blackhole://"},
+			ChangeFeedInfo: &model.ChangeFeedInfo{CheckpointTs: 5, ResolvedTs: 10},
+			Status:         model.ChangeFeedWaitToExecDDL,
+			tables:         map[uint64]schema.TableName{1: {Schema: "test", Table: "t1"}},
+			orphanTables:   map[uint64]model.ProcessTableInfo{2: {ID: 2, StartTs: 5}},
+			toCleanTables:  map[uint64]struct{}{3: {}},
+			ddlJobHistory: []*model.DDL{
+				{Database: "test", Table: "t1", Job: &timodel.Job{ID: 1, Type: timodel.ActionCreateTable, Query: "create table t1(id int)"}},
+				{Database: "test", Table: "t2", Job: &timodel.Job{ID: 2, Type: timodel.ActionCreateTable, Query: "create table t2(id int)", BinlogInfo: &timodel.HistoryInfo{FinishedTS: 8}}},
+			},
+			DDLCurrentIndex: 1,
+		},
+	}}
+	s.follower.capture.processors = map[string]*processor{
+		"test": {
+			changefeedID:  "test",
+			captureID:     "follower",
+			schemaStorage: &schema.MultiVersionStorage{},
+			subInfo:       &model.SubChangeFeedInfo{CheckPointTs: 5, ResolvedTs: 7},
+			tables:        map[int64]*tableInfo{1: {id: 1, resolvedTS: 7}},
+		},
+	}
+
+	resp, err := http.Get(fmt.Sprintf("http://%s/debug/changefeeds", s.follower.capture.info.AdvertiseAddr))
+	c.Assert(err, check.IsNil)
+	defer resp.Body.Close()
+	c.Assert(resp.StatusCode, check.Equals, http.StatusOK)
+	result := &changefeedsDebugInfo{}
+	c.Assert(json.NewDecoder(resp.Body).Decode(result), check.IsNil)
+	c.Assert(result.CaptureErrors, check.HasLen, 0)
+	c.Assert(result.Changefeeds, check.HasLen, 1)
+
+	cf := result.Changefeeds[0]
+	c.Assert(cf.ID, check.Equals, "test")
+	c.Assert(cf.Owner.Status, check.Equals, "WaitToExecDDL")
+	c.Assert(cf.Owner.Info.ResolvedTs, check.Equals, uint64(10))
+	c.Assert(cf.Owner.Tables, check.DeepEquals, map[uint64]schema.TableName{1: {Schema: "test", Table: "t1"}})
+	c.Assert(cf.Owner.OrphanTables, check.DeepEquals, []model.ProcessTableInfo{{ID: 2, StartTs: 5}})
+	c.Assert(cf.Owner.ToCleanTables, check.DeepEquals, []uint64{3})
+	c.Assert(cf.Owner.DDLQueue, check.DeepEquals, []*ddlJobInfo{
+		{ID: 2, Schema: "test", Table: "t2", Type: "create table", Query: "create table t2(id int)", FinishedTs: 8},
+	})
+	c.Assert(cf.Processors, check.HasLen, 1)
+	c.Assert(cf.Processors["follower"].SubInfo.ResolvedTs, check.Equals, uint64(7))
+	c.Assert(cf.Processors["follower"].Tables, check.DeepEquals, []*tableResolvedTs{{ID: 1, ResolvedTs: 7}})
+
+	resp, err = http.Get(fmt.Sprintf("http://%s/debug/info", s.follower.capture.info.AdvertiseAddr))
+	c.Assert(err, check.IsNil)
+	defer resp.Body.Close()
+	info := &captureDebugInfo{}
+	c.Assert(json.NewDecoder(resp.Body).Decode(info), check.IsNil)
+	c.Assert(info.ID, check.Equals, "follower")
+	c.Assert(info.IsOwner, check.IsFalse)
+	c.Assert(info.Changefeeds, check.HasLen, 0)
+	c.Assert(info.Processors, check.HasLen, 1)
+	c.Assert(info.Etcd, check.HasKey, infoKey("owner"))
+}
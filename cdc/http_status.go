@@ -14,14 +14,15 @@
 package cdc
 
 import (
-	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"net/http/pprof"
+	"sort"
+	"time"
 
 	"github.com/coreos/etcd/clientv3"
+	"github.com/pingcap/errors"
 	"github.com/pingcap/log"
 	"github.com/pingcap/ticdc/cdc/kv"
 	"github.com/prometheus/client_golang/prometheus"
@@ -42,6 +43,9 @@ func (s *Server) startStatusHTTP() {
 
 	serverMux.HandleFunc("/status", s.handleStatus)
 	serverMux.HandleFunc("/debug/info", s.handleDebugInfo)
+	serverMux.HandleFunc("/debug/processors", s.handleDebugProcessors)
+	serverMux.HandleFunc("/debug/changefeeds", s.forwardToOwner(s.handleDebugChangefeeds))
+	s.registerAPI(serverMux)
 
 	prometheus.DefaultGatherer = registry
 	serverMux.Handle("/metrics", promhttp.Handler())
@@ -63,30 +67,114 @@ type status struct {
 	GitHash string `json:"git_hash"`
 }
 
-func (s *Server) writeEtcdInfo(ctx context.Context, cli *clientv3.Client, w io.Writer) {
-	resp, err := cli.Get(ctx, kv.EtcdKeyBase, clientv3.WithPrefix())
+// captureDebugInfo is the debug info of a capture
+type captureDebugInfo struct {
+	ID      string `json:"id"`
+	IsOwner bool   `json:"is-owner"`
+	// Changefeeds is the owner's view, it's empty if the capture isn't the owner
+	Changefeeds []*ownerChangefeedInfo `json:"changefeeds"`
+	Processors  []*processorInfo       `json:"processors"`
+	// Etcd maps all CDC keys in etcd to their values
+	Etcd map[string]string `json:"etcd"`
+}
+
+// changefeedDebugInfo is the owner's view and the processors' views of a changefeed
+type changefeedDebugInfo struct {
+	ID    string               `json:"id"`
+	Owner *ownerChangefeedInfo `json:"owner"`
+	// Processors maps the capture IDs to the processors' views
+	Processors map[string]*processorInfo `json:"processors"`
+}
+
+type changefeedsDebugInfo struct {
+	Changefeeds []*changefeedDebugInfo `json:"changefeeds"`
+	// CaptureErrors maps the capture IDs to the errors of getting the processors' views
+	CaptureErrors map[string]string `json:"capture-errors"`
+}
+
+// debugRequestTimeout is the timeout of getting the processors' views from a capture
+const debugRequestTimeout = 5 * time.Second
+
+func (s *Server) handleDebugInfo(w http.ResponseWriter, req *http.Request) {
+	info := &captureDebugInfo{
+		ID:          s.capture.info.ID,
+		IsOwner:     s.capture.ownerManager.IsOwner(),
+		Changefeeds: make([]*ownerChangefeedInfo, 0),
+		Processors:  s.capture.processorsDebugInfo(),
+		Etcd:        make(map[string]string),
+	}
+	if info.IsOwner {
+		info.Changefeeds = s.capture.ownerWorker.debugInfo()
+	}
+	resp, err := s.capture.etcdClient.Get(req.Context(), kv.EtcdKeyBase, clientv3.WithPrefix())
 	if err != nil {
-		fmt.Fprintf(w, "failed to get info: %s\n\n", err.Error())
+		writeInternalServerError(w, err)
 		return
 	}
-
 	for _, kv := range resp.Kvs {
-		fmt.Fprintf(w, "%s\n\t%s\n\n", string(kv.Key), string(kv.Value))
+		info.Etcd[string(kv.Key)] = string(kv.Value)
 	}
+	writeData(w, info)
 }
 
-func (s *Server) handleDebugInfo(w http.ResponseWriter, req *http.Request) {
-	fmt.Fprintf(w, "\n\n*** owner info ***:\n\n")
-	s.capture.ownerWorker.writeDebugInfo(w)
+// handleDebugProcessors writes the views of the processors running on the capture
+func (s *Server) handleDebugProcessors(w http.ResponseWriter, req *http.Request) {
+	writeData(w, s.capture.processorsDebugInfo())
+}
 
-	fmt.Fprintf(w, "\n\n*** processors info ***:\n\n")
-	for _, p := range s.capture.processors {
-		p.writeDebugInfo(w)
-		fmt.Fprintf(w, "\n")
+// handleDebugChangefeeds writes the owner's view and the processors' views of
+// all changefeeds, the processors' views are collected from all captures.
+func (s *Server) handleDebugChangefeeds(w http.ResponseWriter, req *http.Request) {
+	result := &changefeedsDebugInfo{
+		Changefeeds:   make([]*changefeedDebugInfo, 0),
+		CaptureErrors: make(map[string]string),
+	}
+	changefeeds := make(map[string]*changefeedDebugInfo)
+	for _, info := range s.capture.ownerWorker.debugInfo() {
+		cf := &changefeedDebugInfo{ID: info.ID, Owner: info, Processors: make(map[string]*processorInfo)}
+		changefeeds[info.ID] = cf
+		result.Changefeeds = append(result.Changefeeds, cf)
 	}
 
-	fmt.Fprintf(w, "\n\n*** etcd info ***:\n\n")
-	s.writeEtcdInfo(req.Context(), s.capture.etcdClient, w)
+	_, captures, err := kv.GetCaptures(req.Context(), s.capture.etcdClient)
+	if err != nil {
+		writeInternalServerError(w, err)
+		return
+	}
+	client := &http.Client{Timeout: debugRequestTimeout}
+	for _, capture := range captures {
+		processors, err := getProcessorsDebugInfo(client, capture.AdvertiseAddr)
+		if err != nil {
+			result.CaptureErrors[capture.ID] = err.Error()
+			continue
+		}
+		for _, p := range processors {
+			cf, ok := changefeeds[p.ChangefeedID]
+			if !ok {
+				// the changefeed isn't loaded by the owner yet, or it's stopped but the processor isn't
+				cf = &changefeedDebugInfo{ID: p.ChangefeedID, Processors: make(map[string]*processorInfo)}
+				changefeeds[p.ChangefeedID] = cf
+				result.Changefeeds = append(result.Changefeeds, cf)
+			}
+			cf.Processors[capture.ID] = p
+		}
+	}
+	sort.Slice(result.Changefeeds, func(i, j int) bool { return result.Changefeeds[i].ID < result.Changefeeds[j].ID })
+	writeData(w, result)
+}
+
+func getProcessorsDebugInfo(client *http.Client, addr string) ([]*processorInfo, error) {
+	resp, err := client.Get(fmt.Sprintf("http://%s/debug/processors", addr))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("get processors from %s: %s", addr, resp.Status)
+	}
+	var processors []*processorInfo
+	err = json.NewDecoder(resp.Body).Decode(&processors)
+	return processors, errors.Annotatef(err, "decode processors from %s", addr)
 }
 
 func (s *Server) handleStatus(w http.ResponseWriter, req *http.Request) {
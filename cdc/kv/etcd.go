@@ -16,6 +16,8 @@ package kv
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"time"
 
 	"github.com/coreos/etcd/clientv3"
 	"github.com/coreos/etcd/mvcc/mvccpb"
@@ -44,6 +46,11 @@ func GetEtcdKeyChangeFeedStatus(changefeedID string) string {
 	return fmt.Sprintf("%s/changefeed/status/%s", EtcdKeyBase, changefeedID)
 }
 
+// GetEtcdKeyChangeFeedDDLState returns the key of the DDL state of a changefeed
+func GetEtcdKeyChangeFeedDDLState(changefeedID string) string {
+	return fmt.Sprintf("%s/changefeed/ddl/%s", EtcdKeyBase, changefeedID)
+}
+
 // GetEtcdKeySubChangeFeedList returns the key of a subchangefeed info without captureID part
 func GetEtcdKeySubChangeFeedList(changefeedID string) string {
 	return fmt.Sprintf("%s/changefeed/subchangfeed/%s", EtcdKeyBase, changefeedID)
@@ -59,6 +66,33 @@ func GetEtcdKeyCaptureList() string {
 	return EtcdKeyBase + "/capture/info"
 }
 
+// GetEtcdKeyAdminJobList returns the prefix key of all admin jobs
+func GetEtcdKeyAdminJobList() string {
+	return EtcdKeyBase + "/admin/job"
+}
+
+// GetEtcdKeyAdminJob returns the key of the admin job of a changefeed
+func GetEtcdKeyAdminJob(changefeedID string) string {
+	return fmt.Sprintf("%s/%s", GetEtcdKeyAdminJobList(), changefeedID)
+}
+
+// GetEtcdKeyDDLAuditList returns the prefix key of the DDL audit records of a changefeed
+func GetEtcdKeyDDLAuditList(changefeedID string) string {
+	return fmt.Sprintf("%s/admin/ddl-audit/%s/", EtcdKeyBase, changefeedID)
+}
+
+// GetEtcdKeyDDLAudit returns the key of a DDL audit record, the records of a
+// changefeed are sorted by their time.
+func GetEtcdKeyDDLAudit(changefeedID string, t time.Time) string {
+	return fmt.Sprintf("%s%020d", GetEtcdKeyDDLAuditList(changefeedID), t.UnixNano())
+}
+
+// GetEtcdKeySchemaGCTs returns the key of the ts before which the captures
+// have removed the versions of their schema storages
+func GetEtcdKeySchemaGCTs() string {
+	return fmt.Sprintf("%s/schema/gc-ts", EtcdKeyBase)
+}
+
 // GetChangeFeeds returns kv revision and a map mapping from changefeedID to changefeed detail mvccpb.KeyValue
 func GetChangeFeeds(ctx context.Context, cli *clientv3.Client, opts ...clientv3.OpOption) (int64, map[string]*mvccpb.KeyValue, error) {
 	key := GetEtcdKeyChangeFeedList()
@@ -231,3 +265,135 @@ func PutChangeFeedStatus(
 	_, err = client.Put(ctx, key, value, opts...)
 	return errors.Trace(err)
 }
+
+// PutAdminJob puts an admin job into etcd, it replaces the pending job of the
+// same changefeed which hasn't been handled by the owner.
+func PutAdminJob(ctx context.Context, client *clientv3.Client, job *model.AdminJob) error {
+	value, err := job.Marshal()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	_, err = client.Put(ctx, GetEtcdKeyAdminJob(job.CfID), value)
+	return errors.Trace(err)
+}
+
+// GetAdminJobs returns the admin jobs which haven't been handled by the owner
+func GetAdminJobs(ctx context.Context, client *clientv3.Client) ([]*model.AdminJob, error) {
+	resp, err := client.Get(ctx, GetEtcdKeyAdminJobList(), clientv3.WithPrefix())
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	jobs := make([]*model.AdminJob, 0, resp.Count)
+	for _, rawKv := range resp.Kvs {
+		job := &model.AdminJob{}
+		if err := job.Unmarshal(rawKv.Value); err != nil {
+			return nil, errors.Trace(err)
+		}
+		job.ModRevision = rawKv.ModRevision
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+// GetDDLAuditRecords returns the DDL audit records of a changefeed sorted by their time
+func GetDDLAuditRecords(ctx context.Context, client *clientv3.Client, changefeedID string) ([]*model.DDLAuditRecord, error) {
+	resp, err := client.Get(ctx, GetEtcdKeyDDLAuditList(changefeedID), clientv3.WithPrefix(),
+		clientv3.WithSort(clientv3.SortByKey, clientv3.SortAscend))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	records := make([]*model.DDLAuditRecord, 0, resp.Count)
+	for _, rawKv := range resp.Kvs {
+		record := &model.DDLAuditRecord{}
+		if err := record.Unmarshal(rawKv.Value); err != nil {
+			return nil, errors.Trace(err)
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// GetChangeFeedDDLState queries the DDL state of a given changefeed
+func GetChangeFeedDDLState(ctx context.Context, cli *clientv3.Client, id string) (*model.ChangeFeedDDLState, error) {
+	resp, err := cli.Get(ctx, GetEtcdKeyChangeFeedDDLState(id))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if resp.Count == 0 {
+		return nil, errors.Annotatef(model.ErrChangeFeedNotExists, "query ddl state id %s", id)
+	}
+	state := &model.ChangeFeedDDLState{}
+	err = state.Unmarshal(resp.Kvs[0].Value)
+	return state, errors.Trace(err)
+}
+
+// PutChangeFeedDDLState puts the DDL state of a changefeed into etcd
+func PutChangeFeedDDLState(ctx context.Context, cli *clientv3.Client, id string, state *model.ChangeFeedDDLState) error {
+	value, err := state.Marshal()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	_, err = cli.Put(ctx, GetEtcdKeyChangeFeedDDLState(id), value)
+	return errors.Trace(err)
+}
+
+// GetSchemaGCTs returns the schema gc ts, or 0 if no schema version is removed
+func GetSchemaGCTs(ctx context.Context, cli *clientv3.Client) (uint64, error) {
+	resp, err := cli.Get(ctx, GetEtcdKeySchemaGCTs())
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+	if resp.Count == 0 {
+		return 0, nil
+	}
+	ts, err := strconv.ParseUint(string(resp.Kvs[0].Value), 10, 64)
+	return ts, errors.Trace(err)
+}
+
+// AdvanceSchemaGCTs advances the schema gc ts to ts if it's greater, a capture
+// advances it before removing the schema versions before ts, so that the
+// changefeeds starting before it are rejected.
+func AdvanceSchemaGCTs(ctx context.Context, cli *clientv3.Client, ts uint64) error {
+	key := GetEtcdKeySchemaGCTs()
+	for {
+		resp, err := cli.Get(ctx, key)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		var modRevision int64
+		if resp.Count > 0 {
+			current, err := strconv.ParseUint(string(resp.Kvs[0].Value), 10, 64)
+			if err != nil {
+				return errors.Trace(err)
+			}
+			if current >= ts {
+				return nil
+			}
+			modRevision = resp.Kvs[0].ModRevision
+		}
+		txnResp, err := cli.Txn(ctx).If(
+			clientv3.Compare(clientv3.ModRevision(key), "=", modRevision),
+		).Then(
+			clientv3.OpPut(key, strconv.FormatUint(ts, 10)),
+		).Commit()
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if txnResp.Succeeded {
+			return nil
+		}
+	}
+}
+
+// CheckStartTs returns an error if the changefeed starting at startTs can't be
+// created as the schema versions before it may be removed.
+func CheckStartTs(ctx context.Context, cli *clientv3.Client, startTs uint64) error {
+	gcTs, err := GetSchemaGCTs(ctx, cli)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if startTs < gcTs {
+		return errors.Annotatef(model.ErrStartTsBeforeSchemaGC, "start ts %d, gc ts %d", startTs, gcTs)
+	}
+	return nil
+}
@@ -21,6 +21,7 @@ import (
 	"github.com/coreos/etcd/clientv3"
 	"github.com/coreos/etcd/embed"
 	"github.com/pingcap/check"
+	"github.com/pingcap/errors"
 	"github.com/pingcap/ticdc/cdc/model"
 	"github.com/pingcap/ticdc/pkg/etcd"
 	"github.com/pingcap/ticdc/pkg/util"
@@ -106,3 +107,22 @@ func (s *etcdSuite) TestGetPutSubchangeFeed(c *check.C) {
 	c.Assert(err, check.IsNil)
 	c.Assert(getInfo, check.DeepEquals, info)
 }
+
+func (s *etcdSuite) TestSchemaGCTs(c *check.C) {
+	ctx := context.Background()
+	ts, err := GetSchemaGCTs(ctx, s.client)
+	c.Assert(err, check.IsNil)
+	c.Assert(ts, check.Equals, uint64(0))
+	c.Assert(CheckStartTs(ctx, s.client, 1), check.IsNil)
+
+	c.Assert(AdvanceSchemaGCTs(ctx, s.client, 100), check.IsNil)
+	// the gc ts doesn't go back
+	c.Assert(AdvanceSchemaGCTs(ctx, s.client, 50), check.IsNil)
+	ts, err = GetSchemaGCTs(ctx, s.client)
+	c.Assert(err, check.IsNil)
+	c.Assert(ts, check.Equals, uint64(100))
+
+	c.Assert(CheckStartTs(ctx, s.client, 100), check.IsNil)
+	err = CheckStartTs(ctx, s.client, 99)
+	c.Assert(errors.Cause(err), check.Equals, model.ErrStartTsBeforeSchemaGC)
+}
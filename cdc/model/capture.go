@@ -22,6 +22,8 @@ import (
 // CaptureInfo store in etcd.
 type CaptureInfo struct {
 	ID string `json:"id"`
+	// AdvertiseAddr is the address of the status server of the capture
+	AdvertiseAddr string `json:"address"`
 }
 
 // Marshal using json.Marshal.
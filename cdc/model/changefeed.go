@@ -18,6 +18,9 @@ import (
 	"time"
 
 	"github.com/pingcap/errors"
+	"github.com/pingcap/ticdc/pkg/filter"
+	"github.com/pingcap/ticdc/pkg/projection"
+	"github.com/pingcap/ticdc/pkg/router"
 	"github.com/pingcap/tidb/store/tikv/oracle"
 )
 
@@ -28,9 +31,19 @@ type ChangeFeedDetail struct {
 	CreateTime time.Time         `json:"create-time"`
 	// Start sync at this commit ts if `StartTs` is specify or using the CreateTime of changefeed.
 	StartTs uint64 `json:"start-ts"`
-	// The ChangeFeed will exits until sync to timestamp TargetTs
-	TargetTs uint64          `json:"target-ts"`
-	Info     *ChangeFeedInfo `json:"-"`
+	// The ChangeFeed is stopped and marked finished after all changes at or before
+	// TargetTs are synced, it never stops if TargetTs is 0
+	TargetTs uint64 `json:"target-ts"`
+	// FilterRules decides the schemas and tables to sync, all tables except the
+	// system ones are synced if it's nil.
+	FilterRules *filter.Rules `json:"filter-rules"`
+	// EventFilters ignores the DMLs and DDLs of some types
+	EventFilters []*filter.EventRule `json:"event-filters"`
+	// RouteRules maps the upstream schemas and tables to the downstream ones
+	RouteRules []*router.Rule `json:"route-rules"`
+	// ColumnRules drops and transforms the columns of some tables
+	ColumnRules []*projection.Rule `json:"column-rules"`
+	Info        *ChangeFeedInfo    `json:"-"`
 }
 
 // GetStartTs return StartTs if it's  specified or using the CreateTime of changefeed.
@@ -51,6 +64,42 @@ func (detail *ChangeFeedDetail) GetCheckpointTs() uint64 {
 	return detail.GetStartTs()
 }
 
+// Filter returns the filter created by the FilterRules
+func (detail *ChangeFeedDetail) Filter() (*filter.Filter, error) {
+	f, err := filter.New(detail.FilterRules, detail.EventFilters)
+	return f, errors.Annotate(err, "invalid filter rules")
+}
+
+// Router returns the router created by the RouteRules
+func (detail *ChangeFeedDetail) Router() (*router.Router, error) {
+	r, err := router.New(detail.RouteRules)
+	return r, errors.Trace(err)
+}
+
+// Projector returns the projector created by the ColumnRules
+func (detail *ChangeFeedDetail) Projector() (*projection.Projector, error) {
+	p, err := projection.New(detail.ColumnRules)
+	return p, errors.Trace(err)
+}
+
+// Validate checks the sink uri, the target ts and the rules of the detail
+func (detail *ChangeFeedDetail) Validate() error {
+	if detail.SinkURI == "" {
+		return errors.New("sink uri is required")
+	}
+	if detail.TargetTs > 0 && detail.TargetTs <= detail.GetStartTs() {
+		return errors.Errorf("target ts %d must be larger than start ts %d", detail.TargetTs, detail.GetStartTs())
+	}
+	if _, err := detail.Filter(); err != nil {
+		return err
+	}
+	if _, err := detail.Router(); err != nil {
+		return err
+	}
+	_, err := detail.Projector()
+	return err
+}
+
 // Marshal returns the json marshal format of a ChangeFeedDetail
 func (detail *ChangeFeedDetail) Marshal() (string, error) {
 	data, err := json.Marshal(detail)
@@ -25,4 +25,5 @@ var (
 	ErrSubChangeFeedInfoNotExists    = errors.New("subchangefeedinfo not exists")
 	ErrWriteSubChangeFeedInfoConlict = errors.New("write subchangefeedinfo conflict")
 	ErrFindPLockNotCommit            = errors.New("subchangefeedinfo has p-lock not commited")
+	ErrStartTsBeforeSchemaGC         = errors.New("start ts is before the schema gc ts")
 )
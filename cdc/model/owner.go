@@ -16,6 +16,7 @@ package model
 import (
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/pingcap/errors"
 )
@@ -58,10 +59,12 @@ type SubChangeFeedInfo struct {
 	ResolvedTs uint64 `json:"resolved-ts"`
 	// Table information list, containing tables that processor should process, updated by ownrer, processor is read only.
 	// TODO change to be a map for easy update.
-	TableInfos  []*ProcessTableInfo `json:"table-infos"`
-	TablePLock  *TableLock          `json:"table-p-lock"`
-	TableCLock  *TableLock          `json:"table-c-lock"`
-	ModRevision int64               `json:"-"`
+	TableInfos []*ProcessTableInfo `json:"table-infos"`
+	TablePLock *TableLock          `json:"table-p-lock"`
+	TableCLock *TableLock          `json:"table-c-lock"`
+	// Error is set by the processor when it fails, the owner stops the changefeed on it.
+	Error       *RunningError `json:"error"`
+	ModRevision int64         `json:"-"`
 }
 
 // String implements fmt.Stringer interface.
@@ -171,11 +174,122 @@ func (s ChangeFeedStatus) String() string {
 	return "Unknown"
 }
 
+// RunningError records an error which stops a changefeed
+type RunningError struct {
+	// CaptureID is the capture where the error occurred
+	CaptureID string    `json:"capture-id"`
+	Message   string    `json:"message"`
+	Time      time.Time `json:"time"`
+}
+
+// NewRunningError creates a RunningError of err occurred on the capture
+func NewRunningError(captureID string, err error) *RunningError {
+	return &RunningError{
+		CaptureID: captureID,
+		Message:   err.Error(),
+		Time:      time.Now(),
+	}
+}
+
+// AdminJobType is the type of the admin jobs on a changefeed
+type AdminJobType int
+
+// The admin job types
+const (
+	// AdminNone means no admin job has been applied to the changefeed
+	AdminNone AdminJobType = iota
+	// AdminStop pauses the changefeed, its processors are stopped
+	AdminStop
+	// AdminResume resumes a stopped changefeed from its checkpoint
+	AdminResume
+	// AdminRemove stops the changefeed and removes all of its data
+	AdminRemove
+	// AdminSkipDDL skips the pending DDL of the changefeed, and resumes the
+	// changefeed if it's stopped by the DDL
+	AdminSkipDDL
+	// AdminReplaceDDL replaces the query of the pending DDL of the changefeed,
+	// and resumes the changefeed if it's stopped by the DDL
+	AdminReplaceDDL
+)
+
+// String implements fmt.Stringer interface.
+func (t AdminJobType) String() string {
+	switch t {
+	case AdminNone:
+		return "none"
+	case AdminStop:
+		return "stop"
+	case AdminResume:
+		return "resume"
+	case AdminRemove:
+		return "remove"
+	case AdminSkipDDL:
+		return "skip-ddl"
+	case AdminReplaceDDL:
+		return "replace-ddl"
+	}
+	return "unknown"
+}
+
+// AdminJob is an operation on a changefeed stored in etcd and consumed by the owner.
+// A changefeed is updated by saving its detail while it's stopped, the new
+// detail takes effect when the changefeed is resumed.
+type AdminJob struct {
+	CfID string       `json:"changefeed-id"`
+	Type AdminJobType `json:"type"`
+	// Error is the error stopping the changefeed, it's only set by the
+	// owner for the AdminStop jobs.
+	Error *RunningError `json:"error,omitempty"`
+	// DDLJobID is the ID of the DDL job to skip or replace, it's checked
+	// against the pending DDL job of the changefeed if it isn't 0.
+	DDLJobID int64 `json:"ddl-job-id,omitempty"`
+	// Query is the new query of the DDL job for AdminReplaceDDL
+	Query string `json:"query,omitempty"`
+	// ModRevision is the revision of the job in etcd, it's used to remove
+	// the job only if it isn't replaced by a newer one.
+	ModRevision int64 `json:"-"`
+}
+
+// Marshal returns the json marshal format of an AdminJob
+func (job *AdminJob) Marshal() (string, error) {
+	data, err := json.Marshal(job)
+	return string(data), errors.Trace(err)
+}
+
+// Unmarshal unmarshals into *AdminJob from json marshal byte slice
+func (job *AdminJob) Unmarshal(data []byte) error {
+	err := json.Unmarshal(data, job)
+	return errors.Annotatef(err, "Unmarshal data: %v", data)
+}
+
 // ChangeFeedInfo stores information about a ChangeFeed
 type ChangeFeedInfo struct {
 	SinkURI      string `json:"sink-uri"`
 	ResolvedTs   uint64 `json:"resolved-ts"`
 	CheckpointTs uint64 `json:"checkpoint-ts"`
+	// AdminJobType is the last admin job applied to the changefeed, the
+	// changefeed is stopped if it's AdminStop.
+	AdminJobType AdminJobType `json:"admin-job-type"`
+	// Finished is true if the changefeed is stopped by the owner after
+	// syncing to its target ts, it's reset when the changefeed is resumed.
+	Finished bool `json:"finished"`
+	// Error is the last error stopping the changefeed, the changefeed is
+	// resumed automatically with an exponential backoff unless it's stopped
+	// by the user or ErrorCount runs out of the retry budget.
+	Error *RunningError `json:"error"`
+	// ErrorCount is the number of errors since the checkpoint last advanced
+	ErrorCount int `json:"error-count"`
+	// FailedDDL is the DDL job which fails to be executed, it's cleared after
+	// the DDL job is executed or skipped.
+	FailedDDL *DDLJobRef `json:"failed-ddl"`
+	// DDLOperations are the DDL jobs to skip or replace, they are removed
+	// after the DDL jobs are handled.
+	DDLOperations []*DDLOperation `json:"ddl-operations"`
+}
+
+// IsStopped returns true if the changefeed is stopped by an admin job
+func (info *ChangeFeedInfo) IsStopped() bool {
+	return info.AdminJobType == AdminStop || info.AdminJobType == AdminRemove
 }
 
 // Marshal returns json encoded string of ChangeFeedInfo, only contains necessary fields stored in storage
@@ -189,3 +303,75 @@ func (info *ChangeFeedInfo) Unmarshal(data []byte) error {
 	err := json.Unmarshal(data, info)
 	return errors.Annotatef(err, "Unmarshal data: %v", data)
 }
+
+// DDLJobRef identifies a DDL job of a changefeed
+type DDLJobRef struct {
+	JobID      int64  `json:"job-id"`
+	FinishedTs uint64 `json:"finished-ts"`
+	Query      string `json:"query"`
+}
+
+// DDLOperation skips a DDL job or replaces its query when it's executed in
+// the downstream. The DDL job is still applied to the schema storages of the
+// owner and processors, as the rows after it are encoded with the new schema.
+type DDLOperation struct {
+	JobID      int64  `json:"job-id"`
+	FinishedTs uint64 `json:"finished-ts"`
+	// Query is the query executed instead of the query of the DDL job, the
+	// DDL job is skipped if it's empty.
+	Query string `json:"query"`
+}
+
+// DDLAuditRecord records an operation on a DDL job of a changefeed
+type DDLAuditRecord struct {
+	ChangefeedID string `json:"changefeed-id"`
+	Action       string `json:"action"`
+	JobID        int64  `json:"job-id"`
+	FinishedTs   uint64 `json:"finished-ts"`
+	OriginQuery  string `json:"origin-query"`
+	NewQuery     string `json:"new-query"`
+	// CaptureID is the owner handling the operation
+	CaptureID string    `json:"capture-id"`
+	Time      time.Time `json:"time"`
+	// Rejected is the reason why the operation is rejected, it's empty if
+	// the operation is applied.
+	Rejected string `json:"rejected,omitempty"`
+}
+
+// Marshal returns the json marshal format of a DDLAuditRecord
+func (r *DDLAuditRecord) Marshal() (string, error) {
+	data, err := json.Marshal(r)
+	return string(data), errors.Trace(err)
+}
+
+// Unmarshal unmarshals into *DDLAuditRecord from json marshal byte slice
+func (r *DDLAuditRecord) Unmarshal(data []byte) error {
+	err := json.Unmarshal(data, r)
+	return errors.Annotatef(err, "Unmarshal data: %v", data)
+}
+
+// ChangeFeedDDLState is the DDL execution progress of a changefeed, it's
+// persisted by the owner so that a new owner resumes from where the old one
+// left off.
+type ChangeFeedDDLState struct {
+	// ResolvedTs is the resolved ts of the DDL puller, the DDL jobs finished
+	// at or before it are either in Jobs or handled.
+	ResolvedTs uint64 `json:"resolved-ts"`
+	// Jobs are the pending DDL jobs in the order of their finished ts, the
+	// first one is handled next.
+	Jobs []*DDL `json:"jobs"`
+	// LastHandled is the last DDL job executed, skipped or ignored by the filter
+	LastHandled *DDLJobRef `json:"last-handled"`
+}
+
+// Marshal returns the json marshal format of a ChangeFeedDDLState
+func (s *ChangeFeedDDLState) Marshal() (string, error) {
+	data, err := json.Marshal(s)
+	return string(data), errors.Trace(err)
+}
+
+// Unmarshal unmarshals into *ChangeFeedDDLState from json marshal byte slice
+func (s *ChangeFeedDDLState) Unmarshal(data []byte) error {
+	err := json.Unmarshal(data, s)
+	return errors.Annotatef(err, "Unmarshal data: %v", data)
+}
@@ -34,7 +34,10 @@ type DML struct {
 	Table    string
 	Tp       DMLType
 	Values   map[string]types.Datum
-	// only set when Tp = UpdateDMLType
+	// OldValues are all the column values of the row before the update, it's
+	// only set when Tp = UpdateDMLType, which is mounted with the old values
+	// fetched from TiKV only. There are no partial before-images, an update
+	// without the old values is mounted as deletes by keys and a replace.
 	OldValues map[string]types.Datum
 }
 
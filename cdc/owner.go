@@ -16,7 +16,6 @@ package cdc
 import (
 	"context"
 	"fmt"
-	"io"
 	"math"
 	"sync"
 	"time"
@@ -30,6 +29,8 @@ import (
 	"github.com/pingcap/ticdc/cdc/roles"
 	"github.com/pingcap/ticdc/cdc/roles/storage"
 	"github.com/pingcap/ticdc/cdc/schema"
+	"github.com/pingcap/ticdc/pkg/filter"
+	"github.com/pingcap/ticdc/pkg/projection"
 	"github.com/pingcap/tidb/store/tikv/oracle"
 	"go.uber.org/zap"
 )
@@ -42,6 +43,9 @@ type OwnerDDLHandler interface {
 
 	// ExecDDL executes the ddl job
 	ExecDDL(ctx context.Context, sinkURI string, ddl *model.DDL) error
+
+	// Close stops pulling the ddl jobs
+	Close() error
 }
 
 // ChangeFeedInfoRWriter defines the Reader and Writer for changeFeedInfo
@@ -52,9 +56,66 @@ type ChangeFeedInfoRWriter interface {
 	Write(ctx context.Context, infos map[model.ChangeFeedID]*model.ChangeFeedInfo) error
 }
 
+// AdminJobRWriter defines the Reader and Writer for the admin jobs
+type AdminJobRWriter interface {
+	// ReadAdminJobs reads the admin jobs which haven't been handled from storage such as etcd.
+	ReadAdminJobs(ctx context.Context) ([]*model.AdminJob, error)
+	// ApplyAdminJob writes the result of the admin job to storage and removes the job,
+	// info is the latest info of the changefeed or nil if it's not running.
+	ApplyAdminJob(ctx context.Context, job *model.AdminJob, info *model.ChangeFeedInfo) error
+	// ApplyDDLOperation writes the info with the DDL operation and the audit record to
+	// storage and removes the job, info is nil if the job is rejected.
+	ApplyDDLOperation(ctx context.Context, job *model.AdminJob, info *model.ChangeFeedInfo, record *model.DDLAuditRecord) error
+	// ReadChangeFeedInfo reads the info of a changefeed which isn't running, it
+	// returns nil if the changefeed doesn't exist.
+	ReadChangeFeedInfo(ctx context.Context, changefeedID model.ChangeFeedID) (*model.ChangeFeedInfo, error)
+}
+
+var (
+	// errorRetryBudget is the number of times a changefeed stopped by errors
+	// is resumed automatically before its checkpoint advances
+	errorRetryBudget = 10
+	// errorResumeBackoff is the backoff to resume a changefeed after its first
+	// error, it's doubled after each error up to errorResumeMaxBackoff
+	errorResumeBackoff    = 10 * time.Second
+	errorResumeMaxBackoff = 10 * time.Minute
+)
+
+// errorBackoff returns the backoff to resume a changefeed after errorCount errors
+func errorBackoff(errorCount int) time.Duration {
+	backoff := errorResumeBackoff
+	for i := 1; i < errorCount && backoff < errorResumeMaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > errorResumeMaxBackoff {
+		backoff = errorResumeMaxBackoff
+	}
+	return backoff
+}
+
+// shouldAutoResume returns true if the changefeed is stopped by an error, the
+// retry budget isn't exhausted and the backoff has elapsed at now.
+func shouldAutoResume(info *model.ChangeFeedInfo, now time.Time) bool {
+	if info.AdminJobType != model.AdminStop || info.Error == nil || info.ErrorCount > errorRetryBudget {
+		return false
+	}
+	return now.Sub(info.Error.Time) >= errorBackoff(info.ErrorCount)
+}
+
+// DDLStateRWriter defines the Reader and Writer for the DDL states of the changefeeds
+type DDLStateRWriter interface {
+	// ReadDDLState reads the DDL state of a changefeed from storage such as etcd,
+	// it returns nil if the state hasn't been written.
+	ReadDDLState(ctx context.Context, changefeedID model.ChangeFeedID) (*model.ChangeFeedDDLState, error)
+	// WriteDDLState writes the DDL state of a changefeed to storage such as etcd.
+	WriteDDLState(ctx context.Context, changefeedID model.ChangeFeedID, state *model.ChangeFeedDDLState) error
+}
+
 type changeFeedInfo struct {
-	ID     string
-	detail *model.ChangeFeedDetail
+	ID        string
+	detail    *model.ChangeFeedDetail
+	filter    *filter.Filter
+	projector *projection.Projector
 	*model.ChangeFeedInfo
 
 	schema         *schema.Storage
@@ -67,6 +128,8 @@ type changeFeedInfo struct {
 	ddlHandler      OwnerDDLHandler
 	ddlResolvedTs   uint64
 	ddlJobHistory   []*model.DDL
+	// lastHandledDDL is the last DDL job executed, skipped or ignored
+	lastHandledDDL *model.DDLJobRef
 
 	tables        map[uint64]schema.TableName
 	orphanTables  map[uint64]model.ProcessTableInfo
@@ -89,24 +152,9 @@ func (c *changeFeedInfo) String() string {
 	return s
 }
 
-// filter return true if we should not sync the table to downstream.
-// we can add configuration support at the detail.
-func filter(_ *model.ChangeFeedDetail, table schema.TableName) bool {
-	ignoreSchema := []string{"INFORMATION_SCHEMA", "PERFORMANCE_SCHEMA", "mysql"}
-
-	log.Debug("filter table", zap.Stringer("table", table))
-
-	for _, schema := range ignoreSchema {
-		if schema == table.Schema {
-			return true
-		}
-	}
-
-	return false
-}
-
 func (c *changeFeedInfo) addTable(id, startTs uint64, table schema.TableName) {
-	if filter(c.detail, table) {
+	if c.filter.ShouldIgnoreTable(table.Schema, table.Table) {
+		log.Debug("table ignored by the filter", zap.Stringer("table", table))
 		return
 	}
 
@@ -127,6 +175,16 @@ func (c *changeFeedInfo) removeTable(id uint64) {
 	}
 }
 
+// isFinished returns true if all the DMLs and DDLs at or before the target ts
+// are synced to the downstream.
+func (c *changeFeedInfo) isFinished() bool {
+	if c.TargetTs == math.MaxUint64 || c.CheckpointTs < c.TargetTs || c.Status != model.ChangeFeedSyncDML {
+		return false
+	}
+	return c.DDLCurrentIndex >= len(c.ddlJobHistory) ||
+		c.ddlJobHistory[c.DDLCurrentIndex].Job.BinlogInfo.FinishedTS > c.TargetTs
+}
+
 func (c *changeFeedInfo) selectCapture(captures map[string]*model.CaptureInfo) string {
 	return c.minimumTablesCapture(captures)
 }
@@ -265,6 +323,67 @@ func (c *changeFeedInfo) banlanceOrphanTables(ctx context.Context, captures map[
 	}
 }
 
+// close stops the changefeed in the owner
+func (c *changeFeedInfo) close() {
+	if err := c.ddlHandler.Close(); err != nil && errors.Cause(err) != context.Canceled {
+		log.Warn("failed to close the ddl handler", zap.String("changefeed", c.ID), zap.Error(err))
+	}
+}
+
+// ddlOperation returns the operation on the DDL job, or nil if there isn't one
+func (c *changeFeedInfo) ddlOperation(jobID int64) *model.DDLOperation {
+	for _, op := range c.DDLOperations {
+		if op.JobID == jobID {
+			return op
+		}
+	}
+	return nil
+}
+
+// removeDDLOperations removes the operations on the DDL jobs finished at or before ts
+func (c *changeFeedInfo) removeDDLOperations(ts uint64) {
+	ops := c.DDLOperations[:0]
+	for _, op := range c.DDLOperations {
+		if op.FinishedTs > ts {
+			ops = append(ops, op)
+		}
+	}
+	if len(ops) == 0 {
+		ops = nil
+	}
+	c.DDLOperations = ops
+}
+
+// pendingDDL returns the DDL job which is going to be executed, or nil if there isn't one
+func (c *changeFeedInfo) pendingDDL() *model.DDLJobRef {
+	if c.DDLCurrentIndex >= len(c.ddlJobHistory) {
+		return nil
+	}
+	job := c.ddlJobHistory[c.DDLCurrentIndex].Job
+	return &model.DDLJobRef{
+		JobID:      job.ID,
+		FinishedTs: job.BinlogInfo.FinishedTS,
+		Query:      job.Query,
+	}
+}
+
+// replaceDDLQuery returns a copy of the DDL with the query, the DDL in
+// ddlJobHistory is kept as it is.
+func replaceDDLQuery(ddl *model.DDL, query string) (*model.DDL, error) {
+	data, err := ddl.Job.Encode(false)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	job := &pmodel.Job{}
+	if err := job.Decode(data); err != nil {
+		return nil, errors.Trace(err)
+	}
+	job.Query = query
+	newDDL := *ddl
+	newDDL.Job = job
+	return &newDDL, nil
+}
+
 func (c *changeFeedInfo) applyJob(job *pmodel.Job) error {
 	log.Info("apply job", zap.String("sql", job.Query), zap.Int64("job id", job.ID))
 
@@ -282,8 +401,22 @@ func (c *changeFeedInfo) applyJob(job *pmodel.Job) error {
 		dropID := uint64(job.TableID)
 		c.removeTable(dropID)
 	case pmodel.ActionRenameTable:
-		// no id change just update name
-		c.tables[uint64(job.TableID)] = schema.TableName{Schema: schamaName, Table: tableName}
+		// the table id doesn't change, but the table may be moved in or out
+		// of the filter by the new name
+		id := uint64(job.TableID)
+		newName := schema.TableName{Schema: schamaName, Table: tableName}
+		_, replicated := c.tables[id]
+		ignored := c.filter.ShouldIgnoreTable(schamaName, tableName)
+		switch {
+		case replicated && ignored:
+			log.Info("renamed table ignored by the filter", zap.Stringer("table", newName))
+			c.removeTable(id)
+		case replicated:
+			c.tables[id] = newName
+		case !ignored:
+			log.Info("renamed table added by the filter", zap.Stringer("table", newName))
+			c.addTable(id, job.BinlogInfo.FinishedTS, newName)
+		}
 	case pmodel.ActionTruncateTable:
 		dropID := uint64(job.TableID)
 		c.removeTable(dropID)
@@ -293,13 +426,37 @@ func (c *changeFeedInfo) applyJob(job *pmodel.Job) error {
 	default:
 	}
 
+	// the columns of the table may no longer fit the column rules
+	if table := job.BinlogInfo.TableInfo; table != nil {
+		if name, ok := c.tables[uint64(table.ID)]; ok {
+			if err := c.projector.CheckTable(name.Schema, table); err != nil {
+				return errors.Trace(err)
+			}
+		}
+	}
+	return nil
+}
+
+// checkColumnRules checks the column rules against the replicated tables
+func checkColumnRules(projector *projection.Projector, schemaStorage *schema.Storage, tables map[uint64]schema.TableName) error {
+	for id, name := range tables {
+		table, ok := schemaStorage.TableByID(int64(id))
+		if !ok {
+			continue
+		}
+		if err := projector.CheckTable(name.Schema, table); err != nil {
+			return errors.Trace(err)
+		}
+	}
 	return nil
 }
 
 type ownerImpl struct {
 	changeFeedInfos map[model.ChangeFeedID]*changeFeedInfo
 
-	cfRWriter ChangeFeedInfoRWriter
+	cfRWriter       ChangeFeedInfoRWriter
+	adminJobRWriter AdminJobRWriter
+	ddlStateRWriter DDLStateRWriter
 
 	l sync.RWMutex
 
@@ -308,13 +465,17 @@ type ownerImpl struct {
 	etcdClient  *clientv3.Client
 	manager     roles.Manager
 
+	// schemaStorage is shared with the processors of the capture, the schema
+	// of a changefeed is restored from its snapshot
+	schemaStorage *schema.MultiVersionStorage
+
 	captureWatchC      <-chan *CaptureInfoWatchResp
 	cancelWatchCapture func()
 	captures           map[model.CaptureID]*model.CaptureInfo
 }
 
 // NewOwner creates a new ownerImpl instance
-func NewOwner(pdEndpoints []string, cli *clientv3.Client, manager roles.Manager) (*ownerImpl, error) {
+func NewOwner(pdEndpoints []string, cli *clientv3.Client, manager roles.Manager, schemaStorage *schema.MultiVersionStorage) (*ownerImpl, error) {
 	ctx, cancel := context.WithCancel(context.Background())
 	infos, watchC, err := newCaptureInfoWatch(ctx, cli)
 	if err != nil {
@@ -338,8 +499,11 @@ func NewOwner(pdEndpoints []string, cli *clientv3.Client, manager roles.Manager)
 		pdClient:           pdClient,
 		changeFeedInfos:    make(map[model.ChangeFeedID]*changeFeedInfo),
 		cfRWriter:          storage.NewChangeFeedInfoEtcdRWriter(cli),
+		adminJobRWriter:    storage.NewAdminJobEtcdRWriter(cli),
+		ddlStateRWriter:    storage.NewDDLStateEtcdRWriter(cli),
 		etcdClient:         cli,
 		manager:            manager,
+		schemaStorage:      schemaStorage,
 		captureWatchC:      watchC,
 		captures:           captures,
 		cancelWatchCapture: cancel,
@@ -388,69 +552,137 @@ func (o *ownerImpl) loadChangeFeedInfos(ctx context.Context) error {
 			continue
 		}
 
-		detail := changefeeds[changeFeedID]
+		detail, ok := changefeeds[changeFeedID]
+		if !ok {
+			// the changefeed is being removed
+			log.Warn("changefeed detail not found", zap.String("changefeed", changeFeedID))
+			continue
+		}
+		if detail.Info != nil && detail.Info.IsStopped() {
+			if !shouldAutoResume(detail.Info, time.Now()) {
+				continue
+			}
+			log.Info("resume the changefeed stopped by an error", zap.String("changefeed", changeFeedID),
+				zap.Int("error count", detail.Info.ErrorCount), zap.Reflect("error", detail.Info.Error))
+		}
 		log.Info("find new changefeed", zap.Reflect("detail", detail),
 			zap.Uint64("checkpoint ts", detail.GetCheckpointTs()))
 
 		// we find a new changefeed, init changefeed info here.
 		var targetTs uint64
-		changefeed, ok := changefeeds[changeFeedID]
-		if !ok {
-			return errors.Annotatef(model.ErrChangeFeedNotExists, "id:%s", changeFeedID)
-		}
-
+		changefeed := detail
 		if changefeed.TargetTs == uint64(0) {
 			targetTs = uint64(math.MaxUint64)
 		} else {
 			targetTs = changefeed.TargetTs
 		}
 
-		schemaStorage, err := createSchemaStore(o.pdEndpoints)
+		// the changefeed is restored from the DDL state written by the previous owner,
+		// the errors of the changefeed don't stop the other changefeeds
+		ddlState, err := o.ddlStateRWriter.ReadDDLState(ctx, changeFeedID)
 		if err != nil {
-			return errors.Annotate(err, "create schema store failed")
+			err = o.stopChangeFeedOnError(ctx, changeFeedID, detail.Info, errors.Annotate(err, "read ddl state failed"))
+			if err != nil {
+				return errors.Trace(err)
+			}
+			continue
 		}
 
-		err = schemaStorage.HandlePreviousDDLJobIfNeed(detail.GetCheckpointTs())
+		// the pending DDL jobs in the state are applied to the snapshot when they are handled
+		schemaStorage, err := o.schemaStorage.Snapshot(ddlSchemaTs(detail.GetCheckpointTs(), ddlState))
 		if err != nil {
-			return errors.Annotate(err, "handle ddl job failed")
+			err = o.stopChangeFeedOnError(ctx, changeFeedID, detail.Info, errors.Annotate(err, "create schema snapshot failed"))
+			if err != nil {
+				return errors.Trace(err)
+			}
+			continue
 		}
 
-		ddlHandler := newDDLHandler(o.pdClient, detail.GetCheckpointTs())
+		filter, err := detail.Filter()
+		if err != nil {
+			if err := o.stopChangeFeedOnError(ctx, changeFeedID, detail.Info, err); err != nil {
+				return errors.Trace(err)
+			}
+			continue
+		}
+
+		router, err := detail.Router()
+		if err != nil {
+			if err := o.stopChangeFeedOnError(ctx, changeFeedID, detail.Info, err); err != nil {
+				return errors.Trace(err)
+			}
+			continue
+		}
+
+		projector, err := detail.Projector()
+		if err != nil {
+			if err := o.stopChangeFeedOnError(ctx, changeFeedID, detail.Info, err); err != nil {
+				return errors.Trace(err)
+			}
+			continue
+		}
 
 		tables := make(map[uint64]schema.TableName)
 		orphanTables := make(map[uint64]model.ProcessTableInfo)
 		for id, table := range schemaStorage.CloneTables() {
-			if filter(detail, table) {
+			if filter.ShouldIgnoreTable(table.Schema, table.Table) {
 				continue
 			}
 
 			tables[id] = table
 			orphanTables[id] = model.ProcessTableInfo{
 				ID:      id,
-				StartTs: changefeed.StartTs,
+				StartTs: detail.GetCheckpointTs(),
 			}
 		}
+		if err := checkColumnRules(projector, schemaStorage, tables); err != nil {
+			if err := o.stopChangeFeedOnError(ctx, changeFeedID, detail.Info, err); err != nil {
+				return errors.Trace(err)
+			}
+			continue
+		}
 
-		o.changeFeedInfos[changeFeedID] = &changeFeedInfo{
-			detail:        detail,
-			ID:            changeFeedID,
-			client:        o.etcdClient,
-			ddlHandler:    ddlHandler,
-			schema:        schemaStorage,
-			tables:        tables,
-			orphanTables:  orphanTables,
-			toCleanTables: make(map[uint64]struct{}),
-			ChangeFeedInfo: &model.ChangeFeedInfo{
-				SinkURI:      changefeed.SinkURI,
-				ResolvedTs:   0,
-				CheckpointTs: detail.GetCheckpointTs(),
-			},
+		ddlStartTs := detail.GetCheckpointTs()
+		if ddlState != nil && ddlState.ResolvedTs > ddlStartTs {
+			ddlStartTs = ddlState.ResolvedTs
+		}
+		ddlHandler := newDDLHandler(o.pdClient, ddlStartTs, router)
+
+		info := &model.ChangeFeedInfo{
+			SinkURI:      changefeed.SinkURI,
+			ResolvedTs:   0,
+			CheckpointTs: detail.GetCheckpointTs(),
+		}
+		// the errors and DDL operations are kept after the changefeed is resumed
+		if detail.Info != nil {
+			info.ErrorCount = detail.Info.ErrorCount
+			info.FailedDDL = detail.Info.FailedDDL
+			info.DDLOperations = detail.Info.DDLOperations
+		}
+		cfInfo = &changeFeedInfo{
+			detail:          detail,
+			filter:          filter,
+			projector:       projector,
+			ID:              changeFeedID,
+			client:          o.etcdClient,
+			ddlHandler:      ddlHandler,
+			schema:          schemaStorage,
+			tables:          tables,
+			orphanTables:    orphanTables,
+			toCleanTables:   make(map[uint64]struct{}),
+			ChangeFeedInfo:  info,
 			Status:          model.ChangeFeedSyncDML,
 			TargetTs:        targetTs,
 			ProcessorInfos:  etcdChangeFeedInfo,
 			DDLCurrentIndex: 0,
 			infoWriter:      storage.NewOwnerSubCFInfoEtcdWriter(o.etcdClient),
 		}
+		if ddlState != nil {
+			cfInfo.ddlResolvedTs = ddlState.ResolvedTs
+			cfInfo.ddlJobHistory = ddlState.Jobs
+			cfInfo.lastHandledDDL = ddlState.LastHandled
+		}
+		o.changeFeedInfos[changeFeedID] = cfInfo
 	}
 
 	for _, info := range o.changeFeedInfos {
@@ -460,6 +692,188 @@ func (o *ownerImpl) loadChangeFeedInfos(ctx context.Context) error {
 	return nil
 }
 
+// handleAdminJob applies the admin jobs, the stopped and removed changefeeds
+// are closed in the owner and their processors exit after their subchangefeed
+// infos are removed. The resumed changefeeds are loaded from their checkpoints
+// by loadChangeFeedInfos. The changefeed of a job failed to apply is stopped.
+func (o *ownerImpl) handleAdminJob(ctx context.Context) error {
+	jobs, err := o.adminJobRWriter.ReadAdminJobs(ctx)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	for _, job := range jobs {
+		log.Info("handle admin job", zap.String("changefeed", job.CfID), zap.Stringer("type", job.Type))
+		cfInfo, running := o.changeFeedInfos[job.CfID]
+		var info *model.ChangeFeedInfo
+		if running {
+			info = cfInfo.ChangeFeedInfo
+		}
+		if job.Type == model.AdminSkipDDL || job.Type == model.AdminReplaceDDL {
+			if err := o.handleDDLOperation(ctx, job); err != nil {
+				err = o.stopChangeFeedOnError(ctx, job.CfID, info, errors.Annotate(err, "apply ddl operation failed"))
+				if err != nil {
+					return errors.Trace(err)
+				}
+			}
+			continue
+		}
+		if running {
+			info.AdminJobType = job.Type
+		}
+		if err := o.adminJobRWriter.ApplyAdminJob(ctx, job, info); err != nil {
+			err = o.stopChangeFeedOnError(ctx, job.CfID, info, errors.Annotate(err, "apply admin job failed"))
+			if err != nil {
+				return errors.Trace(err)
+			}
+			continue
+		}
+		if running && info.IsStopped() {
+			cfInfo.close()
+			delete(o.changeFeedInfos, job.CfID)
+		}
+	}
+	return nil
+}
+
+// handleDDLOperation skips or replaces the pending DDL job of a running
+// changefeed, or the failed DDL job of a stopped changefeed which is resumed
+// then. The operation is recorded in the audit log even if it's rejected.
+func (o *ownerImpl) handleDDLOperation(ctx context.Context, job *model.AdminJob) error {
+	record := &model.DDLAuditRecord{
+		ChangefeedID: job.CfID,
+		Action:       job.Type.String(),
+		JobID:        job.DDLJobID,
+		NewQuery:     job.Query,
+		CaptureID:    o.manager.ID(),
+		Time:         time.Now(),
+	}
+	cfInfo, running := o.changeFeedInfos[job.CfID]
+	var info *model.ChangeFeedInfo
+	var target *model.DDLJobRef
+	if running {
+		info = cfInfo.ChangeFeedInfo
+		target = cfInfo.pendingDDL()
+	} else {
+		var err error
+		info, err = o.adminJobRWriter.ReadChangeFeedInfo(ctx, job.CfID)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if info != nil {
+			target = info.FailedDDL
+		}
+	}
+	if target != nil {
+		record.JobID = target.JobID
+		record.FinishedTs = target.FinishedTs
+		record.OriginQuery = target.Query
+	}
+
+	switch {
+	case info == nil:
+		record.Rejected = "the changefeed doesn't exist"
+	case target == nil:
+		record.Rejected = "the changefeed has no pending or failed DDL job"
+	case job.DDLJobID != 0 && job.DDLJobID != target.JobID:
+		record.Rejected = fmt.Sprintf("the DDL job %d isn't the pending or failed DDL job %d", job.DDLJobID, target.JobID)
+	case job.Type == model.AdminReplaceDDL && job.Query == "":
+		record.Rejected = "the new query is empty"
+	}
+	if record.Rejected != "" {
+		log.Warn("DDL operation rejected", zap.Reflect("record", record))
+		return errors.Trace(o.adminJobRWriter.ApplyDDLOperation(ctx, job, nil, record))
+	}
+
+	op := &model.DDLOperation{JobID: target.JobID, FinishedTs: target.FinishedTs}
+	if job.Type == model.AdminReplaceDDL {
+		op.Query = job.Query
+	}
+	ops := []*model.DDLOperation{op}
+	for _, old := range info.DDLOperations {
+		if old.JobID != op.JobID {
+			ops = append(ops, old)
+		}
+	}
+	if running {
+		// the running changefeed executes the operation in handleDDL
+		info.DDLOperations = ops
+	} else {
+		newInfo := *info
+		newInfo.DDLOperations = ops
+		if newInfo.AdminJobType == model.AdminStop {
+			newInfo.AdminJobType = job.Type
+			newInfo.Error = nil
+			newInfo.ErrorCount = 0
+		}
+		info = &newInfo
+	}
+	log.Info("DDL operation applied", zap.Reflect("record", record))
+	return errors.Trace(o.adminJobRWriter.ApplyDDLOperation(ctx, job, info, record))
+}
+
+// handleFinishedChangeFeeds stops the changefeeds which are synced to their
+// target ts, and marks them finished. Like stopping them by admin jobs, their
+// processors exit after their subchangefeed infos are removed.
+func (o *ownerImpl) handleFinishedChangeFeeds(ctx context.Context) error {
+	for id, cfInfo := range o.changeFeedInfos {
+		if !cfInfo.isFinished() {
+			continue
+		}
+		log.Info("changefeed is synced to the target ts", zap.String("changefeed", id),
+			zap.Uint64("target ts", cfInfo.TargetTs))
+		info := cfInfo.ChangeFeedInfo
+		info.AdminJobType = model.AdminStop
+		info.Finished = true
+		if err := o.adminJobRWriter.ApplyAdminJob(ctx, &model.AdminJob{CfID: id, Type: model.AdminStop}, info); err != nil {
+			info.Finished = false
+			if err := o.stopChangeFeedOnError(ctx, id, info, errors.Annotate(err, "finish changefeed failed")); err != nil {
+				return errors.Trace(err)
+			}
+			continue
+		}
+		cfInfo.close()
+		delete(o.changeFeedInfos, id)
+	}
+	return nil
+}
+
+// handleProcessorErrors stops the changefeeds whose processors report errors
+func (o *ownerImpl) handleProcessorErrors(ctx context.Context) error {
+	for id, cfInfo := range o.changeFeedInfos {
+		for _, pinfo := range cfInfo.ProcessorInfos {
+			if pinfo.Error == nil {
+				continue
+			}
+			if err := o.stopChangeFeed(ctx, id, cfInfo.ChangeFeedInfo, pinfo.Error); err != nil {
+				return errors.Trace(err)
+			}
+			break
+		}
+	}
+	return nil
+}
+
+// stopChangeFeedOnError stops the changefeed on the error occurred in the
+// owner, info is the latest info of the changefeed or nil if it's not running.
+func (o *ownerImpl) stopChangeFeedOnError(ctx context.Context, id model.ChangeFeedID, info *model.ChangeFeedInfo, err error) error {
+	return o.stopChangeFeed(ctx, id, info, model.NewRunningError(o.manager.ID(), err))
+}
+
+// stopChangeFeed stops the changefeed and records the error, the changefeed is
+// resumed automatically by loadChangeFeedInfos after a backoff.
+func (o *ownerImpl) stopChangeFeed(ctx context.Context, id model.ChangeFeedID, info *model.ChangeFeedInfo, runningErr *model.RunningError) error {
+	log.Warn("stop the changefeed on error", zap.String("changefeed", id), zap.Reflect("error", runningErr))
+	job := &model.AdminJob{CfID: id, Type: model.AdminStop, Error: runningErr}
+	if err := o.adminJobRWriter.ApplyAdminJob(ctx, job, info); err != nil {
+		return errors.Trace(err)
+	}
+	if cfInfo, ok := o.changeFeedInfos[id]; ok {
+		cfInfo.close()
+		delete(o.changeFeedInfos, id)
+	}
+	return nil
+}
+
 func (o *ownerImpl) flushChangeFeedInfos(ctx context.Context) error {
 	infos := make(map[model.CaptureID]*model.ChangeFeedInfo)
 	for id, info := range o.changeFeedInfos {
@@ -468,18 +882,67 @@ func (o *ownerImpl) flushChangeFeedInfos(ctx context.Context) error {
 	return errors.Trace(o.cfRWriter.Write(ctx, infos))
 }
 
-func (c *changeFeedInfo) pullDDLJob() error {
+// pullDDLJob pulls the DDL jobs, it returns true if there are new DDL jobs.
+// The DDL jobs which have been pulled before the changefeed is restored from
+// its DDL state are dropped.
+func (c *changeFeedInfo) pullDDLJob() (bool, error) {
 	ddlResolvedTs, ddlJobs, err := c.ddlHandler.PullDDL()
 	if err != nil {
-		return errors.Trace(err)
+		return false, errors.Trace(err)
+	}
+	if ddlResolvedTs > c.ddlResolvedTs {
+		c.ddlResolvedTs = ddlResolvedTs
+	}
+	var lastTs uint64
+	if c.lastHandledDDL != nil {
+		lastTs = c.lastHandledDDL.FinishedTs
+	}
+	if len(c.ddlJobHistory) > 0 {
+		lastTs = c.ddlJobHistory[len(c.ddlJobHistory)-1].Job.BinlogInfo.FinishedTS
+	}
+	pulled := false
+	for _, ddl := range ddlJobs {
+		if ddl.Job.BinlogInfo.FinishedTS <= lastTs {
+			log.Info("drop the DDL job pulled before", zap.String("changefeed", c.ID),
+				zap.Int64("job id", ddl.Job.ID), zap.Uint64("finished ts", ddl.Job.BinlogInfo.FinishedTS))
+			continue
+		}
+		c.ddlJobHistory = append(c.ddlJobHistory, ddl)
+		pulled = true
+	}
+	return pulled, nil
+}
+
+// ddlState returns the DDL state of the changefeed to persist
+func (c *changeFeedInfo) ddlState() *model.ChangeFeedDDLState {
+	return &model.ChangeFeedDDLState{
+		ResolvedTs:  c.ddlResolvedTs,
+		Jobs:        c.ddlJobHistory[c.DDLCurrentIndex:],
+		LastHandled: c.lastHandledDDL,
 	}
-	c.ddlResolvedTs = ddlResolvedTs
-	c.ddlJobHistory = append(c.ddlJobHistory, ddlJobs...)
-	return nil
+}
+
+// ddlSchemaTs returns the ts of the schema which the owner restores a
+// changefeed with, the schema contains the DDL jobs handled only, so that the
+// pending DDL jobs are applied to it when they are handled.
+func ddlSchemaTs(checkpointTs uint64, state *model.ChangeFeedDDLState) uint64 {
+	ts := checkpointTs
+	if state == nil {
+		return ts
+	}
+	// the DDL job is handled before the checkpoint is flushed
+	if state.LastHandled != nil && state.LastHandled.FinishedTs > ts {
+		ts = state.LastHandled.FinishedTs
+	}
+	// the processors stop at the pending DDL job until it's handled
+	if len(state.Jobs) > 0 && state.Jobs[0].Job.BinlogInfo.FinishedTS <= ts {
+		ts = state.Jobs[0].Job.BinlogInfo.FinishedTS - 1
+	}
+	return ts
 }
 
 // calcResolvedTs update every changefeed's resolve ts and checkpoint ts.
-func (o *ownerImpl) calcResolvedTs() error {
+func (o *ownerImpl) calcResolvedTs(ctx context.Context) error {
 	for _, cfInfo := range o.changeFeedInfos {
 		if cfInfo.Status != model.ChangeFeedSyncDML {
 			continue
@@ -504,8 +967,11 @@ func (o *ownerImpl) calcResolvedTs() error {
 
 			ts = oracle.ComposeTS(physical, logical)
 
-			minResolvedTs = ts
-			minCheckpointTs = ts
+			// the changefeed doesn't sync beyond the target ts
+			if ts < cfInfo.TargetTs {
+				minResolvedTs = ts
+				minCheckpointTs = ts
+			}
 		} else {
 			// calc the min of all resolvedTs in captures
 			for _, pStatus := range cfInfo.ProcessorInfos {
@@ -524,8 +990,22 @@ func (o *ownerImpl) calcResolvedTs() error {
 		// there are some ddl jobs which finishedTs is smaller than minResolvedTs we don't know.
 		// so we need to call `pullDDLJob`, update the ddlJobHistory and ddlResolvedTs.
 		if minResolvedTs > cfInfo.ddlResolvedTs {
-			if err := cfInfo.pullDDLJob(); err != nil {
-				return errors.Trace(err)
+			pulled, err := cfInfo.pullDDLJob()
+			if err != nil {
+				if err := o.stopChangeFeedOnError(ctx, cfInfo.ID, cfInfo.ChangeFeedInfo, err); err != nil {
+					return errors.Trace(err)
+				}
+				continue
+			}
+			// the new DDL jobs are persisted before they are handled
+			if pulled {
+				if err := o.ddlStateRWriter.WriteDDLState(ctx, cfInfo.ID, cfInfo.ddlState()); err != nil {
+					err = o.stopChangeFeedOnError(ctx, cfInfo.ID, cfInfo.ChangeFeedInfo, errors.Annotate(err, "write ddl state failed"))
+					if err != nil {
+						return errors.Trace(err)
+					}
+					continue
+				}
 			}
 
 			if minResolvedTs > cfInfo.ddlResolvedTs {
@@ -534,17 +1014,22 @@ func (o *ownerImpl) calcResolvedTs() error {
 		}
 
 		// if minResolvedTs is greater than the finishedTS of ddl job which is not executed,
-		// we need to execute this ddl job
-		if len(cfInfo.ddlJobHistory) > cfInfo.DDLCurrentIndex &&
-			minResolvedTs > cfInfo.ddlJobHistory[cfInfo.DDLCurrentIndex].Job.BinlogInfo.FinishedTS {
-			minResolvedTs = cfInfo.ddlJobHistory[cfInfo.DDLCurrentIndex].Job.BinlogInfo.FinishedTS
-			cfInfo.Status = model.ChangeFeedWaitToExecDDL
+		// we need to execute this ddl job, the ddl job at the target ts is executed
+		// when the changefeed is resolved to the target ts
+		if len(cfInfo.ddlJobHistory) > cfInfo.DDLCurrentIndex {
+			finishedTs := cfInfo.ddlJobHistory[cfInfo.DDLCurrentIndex].Job.BinlogInfo.FinishedTS
+			if minResolvedTs > finishedTs || (minResolvedTs == cfInfo.TargetTs && finishedTs == cfInfo.TargetTs) {
+				minResolvedTs = finishedTs
+				cfInfo.Status = model.ChangeFeedWaitToExecDDL
+			}
 		}
 
 		cfInfo.ResolvedTs = minResolvedTs
 
 		if minCheckpointTs > cfInfo.CheckpointTs {
 			cfInfo.CheckpointTs = minCheckpointTs
+			// the changefeed recovers from the errors
+			cfInfo.ErrorCount = 0
 		}
 
 		log.Debug("update changefeed", zap.String("id", cfInfo.ID),
@@ -580,22 +1065,62 @@ waitCheckpointTsLoop:
 
 		err := cfInfo.applyJob(todoDDLJob.Job)
 		if err != nil {
-			return errors.Trace(err)
+			cfInfo.Status = model.ChangeFeedDDLExecuteFailed
+			log.Error("Apply DDL failed",
+				zap.String("ChangeFeedID", changeFeedID),
+				zap.Error(err),
+				zap.Reflect("ddlJob", todoDDLJob))
+			if err := o.stopChangeFeedOnError(ctx, changeFeedID, cfInfo.ChangeFeedInfo, err); err != nil {
+				return errors.Trace(err)
+			}
+			continue
 		}
 
 		cfInfo.banlanceOrphanTables(context.Background(), o.captures)
 
-		err = cfInfo.ddlHandler.ExecDDL(ctx, cfInfo.SinkURI, todoDDLJob)
-		// o.l.Lock()
-		// defer o.l.Unlock()
-		// If DDL executing failed, pause the changefeed and print log
+		// the ignored and skipped DDLs are applied to the schema storage above, but not executed
+		op := cfInfo.ddlOperation(todoDDLJob.Job.ID)
+		switch {
+		case cfInfo.filter.ShouldIgnoreTable(todoDDLJob.Database, todoDDLJob.Table) ||
+			cfInfo.filter.ShouldIgnoreDDLEvent(todoDDLJob.Database, todoDDLJob.Table, todoDDLJob.Job):
+			log.Info("DDL ignored by the filter",
+				zap.String("ChangeFeedID", changeFeedID),
+				zap.String("query", todoDDLJob.Job.Query))
+		case op != nil && op.Query == "":
+			log.Info("DDL skipped by the operator",
+				zap.String("ChangeFeedID", changeFeedID),
+				zap.String("query", todoDDLJob.Job.Query))
+		case op != nil:
+			log.Info("DDL replaced by the operator",
+				zap.String("ChangeFeedID", changeFeedID),
+				zap.String("query", todoDDLJob.Job.Query),
+				zap.String("new query", op.Query))
+			var ddl *model.DDL
+			ddl, err = replaceDDLQuery(todoDDLJob, op.Query)
+			if err == nil {
+				err = cfInfo.ddlHandler.ExecDDL(ctx, cfInfo.SinkURI, ddl)
+			}
+		default:
+			err = cfInfo.ddlHandler.ExecDDL(ctx, cfInfo.SinkURI, todoDDLJob)
+		}
+		// If DDL executing failed, stop the changefeed and print log, it's
+		// resumed automatically from its checkpoint later, or skipped or
+		// replaced by the operator
 		if err != nil {
 			cfInfo.Status = model.ChangeFeedDDLExecuteFailed
+			cfInfo.FailedDDL = &model.DDLJobRef{
+				JobID:      todoDDLJob.Job.ID,
+				FinishedTs: todoDDLJob.Job.BinlogInfo.FinishedTS,
+				Query:      todoDDLJob.Job.Query,
+			}
 			log.Error("Execute DDL failed",
 				zap.String("ChangeFeedID", changeFeedID),
 				zap.Error(err),
 				zap.Reflect("ddlJob", todoDDLJob))
-			return err
+			if err := o.stopChangeFeedOnError(ctx, changeFeedID, cfInfo.ChangeFeedInfo, err); err != nil {
+				return errors.Trace(err)
+			}
+			continue
 		}
 		log.Info("Execute DDL succeeded",
 			zap.String("ChangeFeedID", changeFeedID),
@@ -605,9 +1130,24 @@ waitCheckpointTsLoop:
 				zap.String("ChangeFeedID", changeFeedID),
 				zap.String("ChangeFeedState", cfInfo.Status.String()))
 		}
+		cfInfo.removeDDLOperations(todoDDLJob.Job.BinlogInfo.FinishedTS)
+		cfInfo.FailedDDL = nil
+		cfInfo.lastHandledDDL = &model.DDLJobRef{
+			JobID:      todoDDLJob.Job.ID,
+			FinishedTs: todoDDLJob.Job.BinlogInfo.FinishedTS,
+			Query:      todoDDLJob.Job.Query,
+		}
 		cfInfo.DDLCurrentIndex += 1
 		cfInfo.Status = model.ChangeFeedSyncDML
-		return nil
+		// the progress is persisted before the DDL job is handled by another
+		// owner, a DDL job executed right before the owner fails is executed
+		// again by the new owner
+		if err := o.ddlStateRWriter.WriteDDLState(ctx, changeFeedID, cfInfo.ddlState()); err != nil {
+			err = o.stopChangeFeedOnError(ctx, changeFeedID, cfInfo.ChangeFeedInfo, errors.Annotate(err, "write ddl state failed"))
+			if err != nil {
+				return errors.Trace(err)
+			}
+		}
 	}
 
 	return nil
@@ -656,12 +1196,22 @@ func (o *ownerImpl) run(ctx context.Context) error {
 	o.l.Lock()
 	defer o.l.Unlock()
 
-	err := o.loadChangeFeedInfos(cctx)
+	err := o.handleAdminJob(cctx)
 	if err != nil {
 		return errors.Trace(err)
 	}
 
-	err = o.calcResolvedTs()
+	err = o.loadChangeFeedInfos(cctx)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	err = o.handleProcessorErrors(cctx)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	err = o.calcResolvedTs(cctx)
 	if err != nil {
 		return errors.Trace(err)
 	}
@@ -671,6 +1221,11 @@ func (o *ownerImpl) run(ctx context.Context) error {
 		return errors.Trace(err)
 	}
 
+	err = o.handleFinishedChangeFeeds(cctx)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
 	err = o.flushChangeFeedInfos(cctx)
 	if err != nil {
 		return errors.Trace(err)
@@ -681,10 +1236,3 @@ func (o *ownerImpl) run(ctx context.Context) error {
 func (o *ownerImpl) IsOwner(_ context.Context) bool {
 	return o.manager.IsOwner()
 }
-
-func (o *ownerImpl) writeDebugInfo(w io.Writer) {
-	for _, info := range o.changeFeedInfos {
-		// fmt.Fprintf(w, "%+v\n", *info)
-		fmt.Fprintf(w, "%s\n", info)
-	}
-}
@@ -15,7 +15,6 @@ package cdc
 
 import (
 	"context"
-	"database/sql"
 	"sync"
 
 	"github.com/pingcap/errors"
@@ -25,6 +24,7 @@ import (
 	"github.com/pingcap/ticdc/cdc/model"
 	"github.com/pingcap/ticdc/cdc/puller"
 	"github.com/pingcap/ticdc/cdc/sink"
+	"github.com/pingcap/ticdc/pkg/router"
 	"github.com/pingcap/ticdc/pkg/util"
 	"go.uber.org/zap"
 	"golang.org/x/sync/errgroup"
@@ -34,15 +34,21 @@ import (
 type ddlHandler struct {
 	puller     puller.Puller
 	mounter    *entry.Mounter
+	router     *router.Router
 	resolvedTS uint64
 	ddlJobs    []*model.DDL
 
+	// sink is the DDL-only sink of the changefeed, it's created on the first
+	// DDL and reused until the sink URI changes or the handler is closed.
+	sink    sink.Sink
+	sinkURI string
+
 	mu     sync.Mutex
 	wg     *errgroup.Group
 	cancel func()
 }
 
-func newDDLHandler(pdCli pd.Client, checkpointTS uint64) *ddlHandler {
+func newDDLHandler(pdCli pd.Client, checkpointTS uint64, router *router.Router) *ddlHandler {
 	// The key in DDL kv pair returned from TiKV is already memcompariable encoded,
 	// so we set `needEncode` to false.
 	puller := puller.NewPuller(pdCli, checkpointTS, []util.Span{util.GetDDLSpan()}, false)
@@ -53,6 +59,7 @@ func newDDLHandler(pdCli pd.Client, checkpointTS uint64) *ddlHandler {
 		puller:  puller,
 		cancel:  cancel,
 		mounter: txnMounter,
+		router:  router,
 	}
 	// Set it up so that one failed goroutine cancels all others sharing the same ctx
 	errg, ctx := errgroup.WithContext(ctx)
@@ -80,7 +87,7 @@ func (h *ddlHandler) receiveDDL(ctx context.Context, rawTxn model.RawTxn) error
 	if len(rawTxn.Entries) == 0 {
 		return nil
 	}
-	t, err := h.mounter.Mount(rawTxn)
+	t, err := h.mounter.Mount(ctx, rawTxn)
 	if err != nil {
 		return errors.Trace(err)
 	}
@@ -105,21 +112,45 @@ func (h *ddlHandler) PullDDL() (uint64, []*model.DDL, error) {
 
 // ExecDDL implements roles.OwnerDDLHandler interface.
 func (h *ddlHandler) ExecDDL(ctx context.Context, sinkURI string, ddl *model.DDL) error {
-	// TODO cache the sink
-	// TODO handle other target database, kile kafka, file
-	db, err := sql.Open("mysql", sinkURI)
+	s, err := h.ddlSink(sinkURI)
 	if err != nil {
 		return errors.Trace(err)
 	}
-	defer db.Close()
-	s := sink.NewMySQLSinkDDLOnly(db)
-
 	err = s.Emit(ctx, model.Txn{Ts: ddl.Job.BinlogInfo.FinishedTS, DDL: ddl})
-	return errors.Trace(err)
+	if err != nil {
+		// Drop the sink so that the retry starts from a fresh connection.
+		h.closeSink()
+		return errors.Trace(err)
+	}
+	return nil
+}
+
+func (h *ddlHandler) ddlSink(sinkURI string) (sink.Sink, error) {
+	if h.sink != nil && h.sinkURI == sinkURI {
+		return h.sink, nil
+	}
+	h.closeSink()
+	s, err := sink.NewSink(sinkURI, nil, h.router, nil, map[string]string{sink.WriterOpt: sink.DDLWriter})
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	h.sink, h.sinkURI = s, sinkURI
+	return s, nil
+}
+
+func (h *ddlHandler) closeSink() {
+	if h.sink == nil {
+		return
+	}
+	if err := h.sink.Close(); err != nil {
+		log.Warn("close ddl sink failed", zap.String("sinkURI", h.sinkURI), zap.Error(err))
+	}
+	h.sink, h.sinkURI = nil, ""
 }
 
 func (h *ddlHandler) Close() error {
 	h.cancel()
 	err := h.wg.Wait()
+	h.closeSink()
 	return err
 }
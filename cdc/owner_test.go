@@ -1,9 +1,14 @@
 package cdc
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"io/ioutil"
 	"math"
 	"net/url"
+	"path/filepath"
+	"strconv"
 	"sync"
 	"time"
 
@@ -14,11 +19,17 @@ import (
 	"github.com/pingcap/errors"
 	"github.com/pingcap/log"
 	timodel "github.com/pingcap/parser/model"
+	"github.com/pingcap/parser/mysql"
 	"github.com/pingcap/ticdc/cdc/model"
 	"github.com/pingcap/ticdc/cdc/roles"
 	"github.com/pingcap/ticdc/cdc/schema"
+	"github.com/pingcap/ticdc/cdc/sink"
 	"github.com/pingcap/ticdc/pkg/etcd"
+	"github.com/pingcap/ticdc/pkg/filter"
+	"github.com/pingcap/ticdc/pkg/projection"
+	"github.com/pingcap/ticdc/pkg/router"
 	"github.com/pingcap/ticdc/pkg/util"
+	"github.com/pingcap/tidb/types"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -80,6 +91,27 @@ func (h *handlerForPrueDMLTest) Close() error {
 }
 
 var _ ChangeFeedInfoRWriter = &handlerForPrueDMLTest{}
+var _ AdminJobRWriter = &handlerForPrueDMLTest{}
+
+// ReadAdminJobs implements AdminJobRWriter interface.
+func (h *handlerForPrueDMLTest) ReadAdminJobs(ctx context.Context) ([]*model.AdminJob, error) {
+	return nil, nil
+}
+
+// ApplyAdminJob implements AdminJobRWriter interface.
+func (h *handlerForPrueDMLTest) ApplyAdminJob(ctx context.Context, job *model.AdminJob, info *model.ChangeFeedInfo) error {
+	panic("unreachable")
+}
+
+// ApplyDDLOperation implements AdminJobRWriter interface.
+func (h *handlerForPrueDMLTest) ApplyDDLOperation(ctx context.Context, job *model.AdminJob, info *model.ChangeFeedInfo, record *model.DDLAuditRecord) error {
+	panic("unreachable")
+}
+
+// ReadChangeFeedInfo implements AdminJobRWriter interface.
+func (h *handlerForPrueDMLTest) ReadChangeFeedInfo(ctx context.Context, changefeedID model.ChangeFeedID) (*model.ChangeFeedInfo, error) {
+	panic("unreachable")
+}
 
 // Read implements ChangeFeedInfoRWriter interface.
 func (h *handlerForPrueDMLTest) Read(ctx context.Context) (map[model.ChangeFeedID]*model.ChangeFeedDetail, map[model.ChangeFeedID]model.ProcessorsInfos, error) {
@@ -151,6 +183,7 @@ func (s *ownerSuite) TestPureDML(c *check.C) {
 		cancelWatchCapture: cancel,
 		changeFeedInfos:    changeFeedInfos,
 		cfRWriter:          handler,
+		adminJobRWriter:    handler,
 		manager:            manager,
 	}
 	s.owner = owner
@@ -202,6 +235,36 @@ func (h *handlerForDDLTest) Close() error {
 	return nil
 }
 
+// ReadAdminJobs implements AdminJobRWriter interface.
+func (h *handlerForDDLTest) ReadAdminJobs(ctx context.Context) ([]*model.AdminJob, error) {
+	return nil, nil
+}
+
+// ApplyAdminJob implements AdminJobRWriter interface.
+func (h *handlerForDDLTest) ApplyAdminJob(ctx context.Context, job *model.AdminJob, info *model.ChangeFeedInfo) error {
+	panic("unreachable")
+}
+
+// ApplyDDLOperation implements AdminJobRWriter interface.
+func (h *handlerForDDLTest) ApplyDDLOperation(ctx context.Context, job *model.AdminJob, info *model.ChangeFeedInfo, record *model.DDLAuditRecord) error {
+	panic("unreachable")
+}
+
+// ReadChangeFeedInfo implements AdminJobRWriter interface.
+func (h *handlerForDDLTest) ReadChangeFeedInfo(ctx context.Context, changefeedID model.ChangeFeedID) (*model.ChangeFeedInfo, error) {
+	panic("unreachable")
+}
+
+// ReadDDLState implements DDLStateRWriter interface.
+func (h *handlerForDDLTest) ReadDDLState(ctx context.Context, changefeedID model.ChangeFeedID) (*model.ChangeFeedDDLState, error) {
+	return nil, nil
+}
+
+// WriteDDLState implements DDLStateRWriter interface.
+func (h *handlerForDDLTest) WriteDDLState(ctx context.Context, changefeedID model.ChangeFeedID, state *model.ChangeFeedDDLState) error {
+	return nil
+}
+
 func (h *handlerForDDLTest) Read(ctx context.Context) (map[model.CaptureID]*model.ChangeFeedDetail, map[model.ChangeFeedID]model.ProcessorsInfos, error) {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
@@ -330,8 +393,10 @@ func (s *ownerSuite) TestDDL(c *check.C) {
 		changeFeedInfos:    changeFeedInfos,
 
 		// ddlHandler: handler,
-		cfRWriter: handler,
-		manager:   manager,
+		cfRWriter:       handler,
+		adminJobRWriter: handler,
+		ddlStateRWriter: handler,
+		manager:         manager,
 	}
 	s.owner = owner
 	err = owner.Run(ctx, 50*time.Millisecond)
@@ -369,3 +434,711 @@ func (s *changefeedInfoSuite) TestMinimumTables(c *check.C) {
 	captures["c4"] = &model.CaptureInfo{}
 	c.Assert(cf.minimumTablesCapture(captures), check.Equals, "c4")
 }
+
+type adminJobRecorder struct {
+	jobs        []*model.AdminJob
+	applied     map[model.ChangeFeedID]*model.ChangeFeedInfo
+	appliedJobs []*model.AdminJob
+	records     []*model.DDLAuditRecord
+	// stopped are the infos of the changefeeds which aren't running
+	stopped map[model.ChangeFeedID]*model.ChangeFeedInfo
+}
+
+func (r *adminJobRecorder) ReadAdminJobs(ctx context.Context) ([]*model.AdminJob, error) {
+	jobs := r.jobs
+	r.jobs = nil
+	return jobs, nil
+}
+
+func (r *adminJobRecorder) ApplyAdminJob(ctx context.Context, job *model.AdminJob, info *model.ChangeFeedInfo) error {
+	r.applied[job.CfID] = info
+	r.appliedJobs = append(r.appliedJobs, job)
+	return nil
+}
+
+func (r *adminJobRecorder) ApplyDDLOperation(ctx context.Context, job *model.AdminJob, info *model.ChangeFeedInfo, record *model.DDLAuditRecord) error {
+	if info != nil {
+		r.applied[job.CfID] = info
+	}
+	r.records = append(r.records, record)
+	return nil
+}
+
+func (r *adminJobRecorder) ReadChangeFeedInfo(ctx context.Context, changefeedID model.ChangeFeedID) (*model.ChangeFeedInfo, error) {
+	return r.stopped[changefeedID], nil
+}
+
+type closeRecorder struct {
+	OwnerDDLHandler
+	closed bool
+}
+
+func (h *closeRecorder) Close() error {
+	h.closed = true
+	return nil
+}
+
+func (s *ownerSuite) TestHandleAdminJob(c *check.C) {
+	running := map[model.ChangeFeedID]*closeRecorder{
+		"stop":   {},
+		"remove": {},
+		"resume": {},
+	}
+	changeFeedInfos := make(map[model.ChangeFeedID]*changeFeedInfo)
+	for id, handler := range running {
+		changeFeedInfos[id] = &changeFeedInfo{
+			ID:             id,
+			ChangeFeedInfo: &model.ChangeFeedInfo{CheckpointTs: 100},
+			ddlHandler:     handler,
+		}
+	}
+	recorder := &adminJobRecorder{
+		jobs: []*model.AdminJob{
+			{CfID: "stop", Type: model.AdminStop},
+			{CfID: "remove", Type: model.AdminRemove},
+			{CfID: "resume", Type: model.AdminResume},
+			{CfID: "stopped", Type: model.AdminResume},
+		},
+		applied: make(map[model.ChangeFeedID]*model.ChangeFeedInfo),
+	}
+	owner := &ownerImpl{
+		changeFeedInfos: changeFeedInfos,
+		adminJobRWriter: recorder,
+	}
+	c.Assert(owner.handleAdminJob(context.Background()), check.IsNil)
+
+	c.Assert(owner.changeFeedInfos, check.HasLen, 1)
+	c.Assert(owner.changeFeedInfos["resume"], check.NotNil)
+	c.Assert(running["stop"].closed, check.IsTrue)
+	c.Assert(running["remove"].closed, check.IsTrue)
+	c.Assert(running["resume"].closed, check.IsFalse)
+
+	c.Assert(recorder.applied, check.HasLen, 4)
+	c.Assert(recorder.applied["stop"], check.DeepEquals, &model.ChangeFeedInfo{CheckpointTs: 100, AdminJobType: model.AdminStop})
+	c.Assert(recorder.applied["remove"].AdminJobType, check.Equals, model.AdminRemove)
+	c.Assert(recorder.applied["resume"].AdminJobType, check.Equals, model.AdminResume)
+	c.Assert(recorder.applied["stopped"], check.IsNil)
+}
+
+func (s *ownerSuite) TestHandleFinishedChangeFeeds(c *check.C) {
+	ddlAt := func(ts uint64) *model.DDL {
+		return &model.DDL{Job: &timodel.Job{BinlogInfo: &timodel.HistoryInfo{FinishedTS: ts}}}
+	}
+	handlers := make(map[model.ChangeFeedID]*closeRecorder)
+	newInfo := func(id string, targetTs, checkpointTs uint64, ddls ...*model.DDL) *changeFeedInfo {
+		handlers[id] = &closeRecorder{}
+		return &changeFeedInfo{
+			ID:             id,
+			ChangeFeedInfo: &model.ChangeFeedInfo{CheckpointTs: checkpointTs, ResolvedTs: checkpointTs},
+			Status:         model.ChangeFeedSyncDML,
+			TargetTs:       targetTs,
+			ddlHandler:     handlers[id],
+			ddlJobHistory:  ddls,
+		}
+	}
+	changeFeedInfos := map[model.ChangeFeedID]*changeFeedInfo{
+		"finished":          newInfo("finished", 100, 100, ddlAt(101)),
+		"no-target":         newInfo("no-target", math.MaxUint64, 100),
+		"syncing":           newInfo("syncing", 100, 99),
+		"ddl-at-target":     newInfo("ddl-at-target", 100, 100, ddlAt(100)),
+		"ddl-after-target":  newInfo("ddl-after-target", 100, 100, ddlAt(90), ddlAt(110)),
+		"ddl-before-target": newInfo("ddl-before-target", 100, 100, ddlAt(90), ddlAt(110)),
+	}
+	changeFeedInfos["ddl-after-target"].DDLCurrentIndex = 1
+	recorder := &adminJobRecorder{applied: make(map[model.ChangeFeedID]*model.ChangeFeedInfo)}
+	owner := &ownerImpl{
+		changeFeedInfos: changeFeedInfos,
+		adminJobRWriter: recorder,
+	}
+	c.Assert(owner.handleFinishedChangeFeeds(context.Background()), check.IsNil)
+
+	c.Assert(recorder.applied, check.HasLen, 2)
+	for _, id := range []string{"finished", "ddl-after-target"} {
+		c.Assert(recorder.applied[id], check.DeepEquals, &model.ChangeFeedInfo{
+			CheckpointTs: 100,
+			ResolvedTs:   100,
+			AdminJobType: model.AdminStop,
+			Finished:     true,
+		})
+		c.Assert(handlers[id].closed, check.IsTrue)
+	}
+	c.Assert(owner.changeFeedInfos, check.HasLen, 4)
+	c.Assert(handlers["ddl-at-target"].closed, check.IsFalse)
+}
+
+func (s *ownerSuite) TestCalcResolvedTsWithTargetTs(c *check.C) {
+	ddl := &model.DDL{Job: &timodel.Job{BinlogInfo: &timodel.HistoryInfo{FinishedTS: 100}}}
+	cfInfo := &changeFeedInfo{
+		ID:             "test",
+		ChangeFeedInfo: &model.ChangeFeedInfo{CheckpointTs: 50},
+		Status:         model.ChangeFeedSyncDML,
+		TargetTs:       100,
+		ProcessorInfos: model.ProcessorsInfos{
+			"capture": {CheckPointTs: 80, ResolvedTs: 120},
+		},
+		tables:        map[uint64]schema.TableName{1: {Schema: "test", Table: "t"}},
+		ddlResolvedTs: 200,
+		ddlJobHistory: []*model.DDL{ddl},
+	}
+	owner := &ownerImpl{changeFeedInfos: map[model.ChangeFeedID]*changeFeedInfo{"test": cfInfo}}
+	c.Assert(owner.calcResolvedTs(context.Background()), check.IsNil)
+	// the DDL at the target ts is executed before the changefeed is finished
+	c.Assert(cfInfo.ResolvedTs, check.Equals, uint64(100))
+	c.Assert(cfInfo.CheckpointTs, check.Equals, uint64(80))
+	c.Assert(cfInfo.Status, check.Equals, model.ChangeFeedWaitToExecDDL)
+
+	ddl.Job.BinlogInfo.FinishedTS = 150
+	cfInfo.Status = model.ChangeFeedSyncDML
+	c.Assert(owner.calcResolvedTs(context.Background()), check.IsNil)
+	c.Assert(cfInfo.ResolvedTs, check.Equals, uint64(100))
+	c.Assert(cfInfo.Status, check.Equals, model.ChangeFeedSyncDML)
+}
+
+type execDDLErrorHandler struct {
+	closeRecorder
+}
+
+func (h *execDDLErrorHandler) ExecDDL(ctx context.Context, sinkURI string, ddl *model.DDL) error {
+	return errors.New("mock exec ddl error")
+}
+
+func (s *ownerSuite) TestStopChangeFeedOnError(c *check.C) {
+	ddlHandler := &execDDLErrorHandler{}
+	processorHandler := &closeRecorder{}
+	runningHandler := &closeRecorder{}
+	processorErr := &model.RunningError{CaptureID: "capture2", Message: "mock processor error", Time: time.Now()}
+	changeFeedInfos := map[model.ChangeFeedID]*changeFeedInfo{
+		"ddl-error": {
+			ID:             "ddl-error",
+			ChangeFeedInfo: &model.ChangeFeedInfo{CheckpointTs: 100, ErrorCount: 1},
+			Status:         model.ChangeFeedWaitToExecDDL,
+			ProcessorInfos: model.ProcessorsInfos{"capture1": {CheckPointTs: 100}},
+			ddlHandler:     ddlHandler,
+			ddlJobHistory: []*model.DDL{
+				{Job: &timodel.Job{Query: "create table t(id int)", BinlogInfo: &timodel.HistoryInfo{FinishedTS: 100}}},
+			},
+		},
+		"processor-error": {
+			ID:             "processor-error",
+			ChangeFeedInfo: &model.ChangeFeedInfo{CheckpointTs: 100},
+			Status:         model.ChangeFeedSyncDML,
+			ProcessorInfos: model.ProcessorsInfos{
+				"capture1": {CheckPointTs: 100},
+				"capture2": {CheckPointTs: 100, Error: processorErr},
+			},
+			ddlHandler: processorHandler,
+		},
+		"running": {
+			ID:             "running",
+			ChangeFeedInfo: &model.ChangeFeedInfo{CheckpointTs: 100},
+			Status:         model.ChangeFeedSyncDML,
+			ProcessorInfos: model.ProcessorsInfos{"capture1": {CheckPointTs: 100}},
+			ddlHandler:     runningHandler,
+		},
+	}
+	recorder := &adminJobRecorder{applied: make(map[model.ChangeFeedID]*model.ChangeFeedInfo)}
+	owner := &ownerImpl{
+		changeFeedInfos: changeFeedInfos,
+		adminJobRWriter: recorder,
+		manager:         roles.NewMockManager("owner", func() {}),
+	}
+
+	// the errors stop the changefeeds causing them only
+	c.Assert(owner.handleProcessorErrors(context.Background()), check.IsNil)
+	c.Assert(owner.handleDDL(context.Background()), check.IsNil)
+	c.Assert(owner.changeFeedInfos, check.HasLen, 1)
+	c.Assert(owner.changeFeedInfos["running"], check.NotNil)
+	c.Assert(processorHandler.closed, check.IsTrue)
+	c.Assert(ddlHandler.closed, check.IsTrue)
+	c.Assert(runningHandler.closed, check.IsFalse)
+
+	c.Assert(recorder.appliedJobs, check.HasLen, 2)
+	job := recorder.appliedJobs[0]
+	c.Assert(job.CfID, check.Equals, "processor-error")
+	c.Assert(job.Type, check.Equals, model.AdminStop)
+	c.Assert(job.Error, check.Equals, processorErr)
+	job = recorder.appliedJobs[1]
+	c.Assert(job.CfID, check.Equals, "ddl-error")
+	c.Assert(job.Type, check.Equals, model.AdminStop)
+	c.Assert(job.Error.CaptureID, check.Equals, "owner")
+	c.Assert(job.Error.Message, check.Equals, "mock exec ddl error")
+	c.Assert(recorder.applied["ddl-error"].ErrorCount, check.Equals, 1)
+}
+
+func (s *ownerSuite) TestShouldAutoResume(c *check.C) {
+	now := time.Now()
+	newInfo := func(tp model.AdminJobType, errorCount int, errorTime time.Time) *model.ChangeFeedInfo {
+		return &model.ChangeFeedInfo{
+			AdminJobType: tp,
+			Error:        &model.RunningError{Message: "test", Time: errorTime},
+			ErrorCount:   errorCount,
+		}
+	}
+
+	c.Assert(errorBackoff(1), check.Equals, errorResumeBackoff)
+	c.Assert(errorBackoff(3), check.Equals, 4*errorResumeBackoff)
+	c.Assert(errorBackoff(errorRetryBudget+100), check.Equals, errorResumeMaxBackoff)
+
+	c.Assert(shouldAutoResume(newInfo(model.AdminStop, 1, now.Add(-errorResumeBackoff)), now), check.IsTrue)
+	c.Assert(shouldAutoResume(newInfo(model.AdminStop, 1, now.Add(-errorResumeBackoff/2)), now), check.IsFalse)
+	c.Assert(shouldAutoResume(newInfo(model.AdminStop, 2, now.Add(-errorResumeBackoff)), now), check.IsFalse)
+	c.Assert(shouldAutoResume(newInfo(model.AdminStop, 2, now.Add(-2*errorResumeBackoff)), now), check.IsTrue)
+	// the retry budget is exhausted
+	c.Assert(shouldAutoResume(newInfo(model.AdminStop, errorRetryBudget+1, now.Add(-time.Hour)), now), check.IsFalse)
+	// the changefeeds stopped or removed by the user
+	c.Assert(shouldAutoResume(&model.ChangeFeedInfo{AdminJobType: model.AdminStop, ErrorCount: 1}, now), check.IsFalse)
+	c.Assert(shouldAutoResume(newInfo(model.AdminRemove, 1, now.Add(-time.Hour)), now), check.IsFalse)
+}
+
+func (s *ownerSuite) TestHandleDDLOperation(c *check.C) {
+	pendingDDL := &model.DDL{Job: &timodel.Job{ID: 1, Query: "create table t1(id int)", BinlogInfo: &timodel.HistoryInfo{FinishedTS: 100}}}
+	runningInfo := &model.ChangeFeedInfo{CheckpointTs: 100}
+	changeFeedInfos := map[model.ChangeFeedID]*changeFeedInfo{
+		"running": {
+			ID:             "running",
+			ChangeFeedInfo: runningInfo,
+			Status:         model.ChangeFeedWaitToExecDDL,
+			ddlJobHistory:  []*model.DDL{pendingDDL},
+		},
+		"no-ddl": {
+			ID:             "no-ddl",
+			ChangeFeedInfo: &model.ChangeFeedInfo{CheckpointTs: 100},
+			Status:         model.ChangeFeedSyncDML,
+		},
+	}
+	recorder := &adminJobRecorder{
+		jobs: []*model.AdminJob{
+			{CfID: "running", Type: model.AdminSkipDDL},
+			{CfID: "failed", Type: model.AdminReplaceDDL, DDLJobID: 2, Query: "alter table t2 add column c int"},
+			{CfID: "no-ddl", Type: model.AdminSkipDDL},
+			{CfID: "not-exist", Type: model.AdminSkipDDL},
+			{CfID: "mismatched", Type: model.AdminSkipDDL, DDLJobID: 3},
+			{CfID: "empty-query", Type: model.AdminReplaceDDL},
+		},
+		applied: make(map[model.ChangeFeedID]*model.ChangeFeedInfo),
+		stopped: make(map[model.ChangeFeedID]*model.ChangeFeedInfo),
+	}
+	for _, id := range []string{"failed", "mismatched", "empty-query"} {
+		recorder.stopped[id] = &model.ChangeFeedInfo{
+			CheckpointTs: 199,
+			AdminJobType: model.AdminStop,
+			Error:        &model.RunningError{Message: "test"},
+			ErrorCount:   2,
+			FailedDDL:    &model.DDLJobRef{JobID: 2, FinishedTs: 200, Query: "alter table t2 add column c varchar"},
+		}
+	}
+	owner := &ownerImpl{
+		changeFeedInfos: changeFeedInfos,
+		adminJobRWriter: recorder,
+		manager:         roles.NewMockManager("owner", func() {}),
+	}
+	c.Assert(owner.handleAdminJob(context.Background()), check.IsNil)
+
+	// the operation on the running changefeed is applied by handleDDL
+	c.Assert(owner.changeFeedInfos, check.HasLen, 2)
+	c.Assert(runningInfo.DDLOperations, check.DeepEquals, []*model.DDLOperation{{JobID: 1, FinishedTs: 100}})
+	c.Assert(recorder.applied["running"], check.Equals, runningInfo)
+	// the stopped changefeed is resumed
+	c.Assert(recorder.applied["failed"], check.DeepEquals, &model.ChangeFeedInfo{
+		CheckpointTs:  199,
+		AdminJobType:  model.AdminReplaceDDL,
+		FailedDDL:     &model.DDLJobRef{JobID: 2, FinishedTs: 200, Query: "alter table t2 add column c varchar"},
+		DDLOperations: []*model.DDLOperation{{JobID: 2, FinishedTs: 200, Query: "alter table t2 add column c int"}},
+	})
+	c.Assert(recorder.applied["failed"].IsStopped(), check.IsFalse)
+	c.Assert(recorder.applied, check.HasLen, 2)
+
+	// all the operations are audited
+	c.Assert(recorder.records, check.HasLen, 6)
+	for i, id := range []string{"running", "failed", "no-ddl", "not-exist", "mismatched", "empty-query"} {
+		record := recorder.records[i]
+		c.Assert(record.ChangefeedID, check.Equals, id)
+		c.Assert(record.CaptureID, check.Equals, "owner")
+		if i < 2 {
+			c.Assert(record.Rejected, check.Equals, "")
+		} else {
+			c.Assert(record.Rejected, check.Not(check.Equals), "")
+		}
+	}
+	c.Assert(recorder.records[0].Action, check.Equals, "skip-ddl")
+	c.Assert(recorder.records[0].OriginQuery, check.Equals, pendingDDL.Job.Query)
+	c.Assert(recorder.records[1].Action, check.Equals, "replace-ddl")
+	c.Assert(recorder.records[1].FinishedTs, check.Equals, uint64(200))
+	c.Assert(recorder.records[1].NewQuery, check.Equals, "alter table t2 add column c int")
+}
+
+type execDDLRecorder struct {
+	closeRecorder
+	queries []string
+}
+
+func (h *execDDLRecorder) ExecDDL(ctx context.Context, sinkURI string, ddl *model.DDL) error {
+	h.queries = append(h.queries, ddl.Job.Query)
+	return nil
+}
+
+func (s *ownerSuite) TestHandleDDLWithOperations(c *check.C) {
+	newDDL := func(id int64, ts uint64, query string) *model.DDL {
+		return &model.DDL{Job: &timodel.Job{ID: id, Query: query, BinlogInfo: &timodel.HistoryInfo{FinishedTS: ts}}}
+	}
+	handler := &execDDLRecorder{}
+	cfInfo := &changeFeedInfo{
+		ID: "test",
+		ChangeFeedInfo: &model.ChangeFeedInfo{
+			FailedDDL: &model.DDLJobRef{JobID: 1, FinishedTs: 100, Query: "alter table t add column c varchar"},
+			DDLOperations: []*model.DDLOperation{
+				{JobID: 1, FinishedTs: 100, Query: "alter table t add column c int"},
+				{JobID: 2, FinishedTs: 200},
+			},
+		},
+		ProcessorInfos: model.ProcessorsInfos{"capture": {}},
+		ddlHandler:     handler,
+		ddlJobHistory: []*model.DDL{
+			newDDL(1, 100, "alter table t add column c varchar"),
+			newDDL(2, 200, "drop table t"),
+			newDDL(3, 300, "create table t(id int)"),
+		},
+	}
+	states := &ddlStateRecorder{}
+	owner := &ownerImpl{
+		changeFeedInfos: map[model.ChangeFeedID]*changeFeedInfo{"test": cfInfo},
+		ddlStateRWriter: states,
+	}
+	execNext := func() {
+		cfInfo.Status = model.ChangeFeedWaitToExecDDL
+		cfInfo.ProcessorInfos["capture"].CheckPointTs = cfInfo.ddlJobHistory[cfInfo.DDLCurrentIndex].Job.BinlogInfo.FinishedTS
+		c.Assert(owner.handleDDL(context.Background()), check.IsNil)
+		c.Assert(cfInfo.Status, check.Equals, model.ChangeFeedSyncDML)
+	}
+
+	// the replaced query is executed, the job in the history is kept
+	execNext()
+	c.Assert(handler.queries, check.DeepEquals, []string{"alter table t add column c int"})
+	c.Assert(cfInfo.ddlJobHistory[0].Job.Query, check.Equals, "alter table t add column c varchar")
+	c.Assert(cfInfo.FailedDDL, check.IsNil)
+	c.Assert(cfInfo.DDLOperations, check.DeepEquals, []*model.DDLOperation{{JobID: 2, FinishedTs: 200}})
+
+	// the skipped DDL isn't executed
+	execNext()
+	c.Assert(handler.queries, check.HasLen, 1)
+	c.Assert(cfInfo.DDLOperations, check.IsNil)
+
+	execNext()
+	c.Assert(handler.queries, check.DeepEquals, []string{"alter table t add column c int", "create table t(id int)"})
+	c.Assert(cfInfo.DDLCurrentIndex, check.Equals, 3)
+
+	// the progress is persisted after each DDL job is handled
+	c.Assert(states.states, check.HasLen, 3)
+	c.Assert(states.states[1].LastHandled, check.DeepEquals, &model.DDLJobRef{JobID: 2, FinishedTs: 200, Query: "drop table t"})
+	c.Assert(states.states[1].Jobs, check.HasLen, 1)
+	c.Assert(states.states[2].Jobs, check.HasLen, 0)
+}
+
+type ddlStateRecorder struct {
+	states []*model.ChangeFeedDDLState
+}
+
+func (r *ddlStateRecorder) ReadDDLState(ctx context.Context, changefeedID model.ChangeFeedID) (*model.ChangeFeedDDLState, error) {
+	if len(r.states) == 0 {
+		return nil, nil
+	}
+	return r.states[len(r.states)-1], nil
+}
+
+func (r *ddlStateRecorder) WriteDDLState(ctx context.Context, changefeedID model.ChangeFeedID, state *model.ChangeFeedDDLState) error {
+	r.states = append(r.states, state)
+	return nil
+}
+
+type pullDDLRecorder struct {
+	closeRecorder
+	resolvedTs uint64
+	jobs       []*model.DDL
+}
+
+func (h *pullDDLRecorder) PullDDL() (uint64, []*model.DDL, error) {
+	return h.resolvedTs, h.jobs, nil
+}
+
+func (h *pullDDLRecorder) ExecDDL(ctx context.Context, sinkURI string, ddl *model.DDL) error {
+	return nil
+}
+
+func (s *ownerSuite) TestPullDDLJobWithState(c *check.C) {
+	newDDL := func(id int64, ts uint64) *model.DDL {
+		return &model.DDL{Job: &timodel.Job{ID: id, BinlogInfo: &timodel.HistoryInfo{FinishedTS: ts}}}
+	}
+	handler := &pullDDLRecorder{}
+	cfInfo := &changeFeedInfo{
+		ID:             "test",
+		ddlHandler:     handler,
+		ddlResolvedTs:  150,
+		ddlJobHistory:  []*model.DDL{newDDL(2, 120)},
+		lastHandledDDL: &model.DDLJobRef{JobID: 1, FinishedTs: 100},
+	}
+
+	// the jobs restored from the state are pulled again
+	handler.resolvedTs, handler.jobs = 140, []*model.DDL{newDDL(1, 100), newDDL(2, 120)}
+	pulled, err := cfInfo.pullDDLJob()
+	c.Assert(err, check.IsNil)
+	c.Assert(pulled, check.IsFalse)
+	c.Assert(cfInfo.ddlResolvedTs, check.Equals, uint64(150))
+	c.Assert(cfInfo.ddlJobHistory, check.HasLen, 1)
+
+	handler.resolvedTs, handler.jobs = 200, []*model.DDL{newDDL(3, 180)}
+	pulled, err = cfInfo.pullDDLJob()
+	c.Assert(err, check.IsNil)
+	c.Assert(pulled, check.IsTrue)
+	c.Assert(cfInfo.ddlResolvedTs, check.Equals, uint64(200))
+	c.Assert(cfInfo.ddlJobHistory, check.HasLen, 2)
+
+	cfInfo.DDLCurrentIndex = 1
+	state := cfInfo.ddlState()
+	c.Assert(state.ResolvedTs, check.Equals, uint64(200))
+	c.Assert(state.Jobs, check.DeepEquals, []*model.DDL{newDDL(3, 180)})
+	c.Assert(state.LastHandled, check.DeepEquals, &model.DDLJobRef{JobID: 1, FinishedTs: 100})
+}
+
+func (s *ownerSuite) TestDDLSchemaTs(c *check.C) {
+	newDDL := func(id int64, ts uint64) *model.DDL {
+		return &model.DDL{Job: &timodel.Job{ID: id, BinlogInfo: &timodel.HistoryInfo{FinishedTS: ts}}}
+	}
+	c.Assert(ddlSchemaTs(100, nil), check.Equals, uint64(100))
+	c.Assert(ddlSchemaTs(100, &model.ChangeFeedDDLState{}), check.Equals, uint64(100))
+
+	// the DDL job is handled but the checkpoint isn't flushed
+	state := &model.ChangeFeedDDLState{LastHandled: &model.DDLJobRef{JobID: 1, FinishedTs: 120}}
+	c.Assert(ddlSchemaTs(100, state), check.Equals, uint64(120))
+
+	// the pending DDL job isn't applied to the schema
+	state.Jobs = []*model.DDL{newDDL(2, 150)}
+	c.Assert(ddlSchemaTs(150, state), check.Equals, uint64(149))
+	c.Assert(ddlSchemaTs(140, state), check.Equals, uint64(140))
+}
+
+// countingSink counts the DDLs it receives and whether it's closed
+type countingSink struct {
+	sink.Sink
+	ddls   int
+	closed bool
+}
+
+func (s *countingSink) Emit(ctx context.Context, t model.Txn) error {
+	s.ddls++
+	return nil
+}
+
+func (s *countingSink) Close() error {
+	s.closed = true
+	return nil
+}
+
+func (s *ownerSuite) TestExecDDLReusesSink(c *check.C) {
+	var created []*countingSink
+	sink.Register("ddl-test", func(*url.URL, *filter.Filter, *router.Router, sink.TableInfoGetter, map[string]string) (sink.Sink, error) {
+		cs := &countingSink{}
+		created = append(created, cs)
+		return cs, nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	errg, _ := errgroup.WithContext(ctx)
+	h := &ddlHandler{wg: errg, cancel: cancel}
+
+	ddl := &model.DDL{Job: &timodel.Job{ID: 1, BinlogInfo: &timodel.HistoryInfo{FinishedTS: 100}}}
+	c.Assert(h.ExecDDL(context.Background(), "ddl-test://a", ddl), check.IsNil)
+	c.Assert(h.ExecDDL(context.Background(), "ddl-test://a", ddl), check.IsNil)
+	c.Assert(created, check.HasLen, 1)
+	c.Assert(created[0].ddls, check.Equals, 2)
+
+	// the sink is recreated when the sink uri is changed
+	c.Assert(h.ExecDDL(context.Background(), "ddl-test://b", ddl), check.IsNil)
+	c.Assert(created, check.HasLen, 2)
+	c.Assert(created[0].closed, check.IsTrue)
+
+	c.Assert(h.Close(), check.IsNil)
+	c.Assert(created[1].closed, check.IsTrue)
+}
+
+func (s *ownerSuite) TestExecDDLWithFileSink(c *check.C) {
+	dir := c.MkDir()
+	sinkURI := "file://" + dir
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	errg, _ := errgroup.WithContext(ctx)
+	h := &ddlHandler{wg: errg, cancel: cancel}
+
+	// a processor writes into the same root
+	processorSink, err := sink.NewSink(sinkURI, nil, nil, nil, map[string]string{sink.WriterOpt: "capture-1"})
+	c.Assert(err, check.IsNil)
+	defer processorSink.Close()
+	err = processorSink.Emit(context.Background(), model.Txn{
+		Ts: 90,
+		DMLs: []*model.DML{{
+			Database: "test",
+			Table:    "t1",
+			Tp:       model.InsertDMLType,
+			Values:   map[string]types.Datum{"id": types.NewIntDatum(1)},
+		}},
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(processorSink.EmitResolvedTimestamp(context.Background(), 100), check.IsNil)
+
+	for i, query := range []string{"create table t2(id int)", "create table t3(id int)"} {
+		ddl := &model.DDL{
+			Database: "test",
+			Table:    "t" + strconv.Itoa(i+2),
+			Job: &timodel.Job{
+				ID:         int64(i + 1),
+				Type:       timodel.ActionCreateTable,
+				Query:      query,
+				BinlogInfo: &timodel.HistoryInfo{FinishedTS: uint64(100 + i)},
+			},
+		}
+		c.Assert(h.ExecDDL(context.Background(), sinkURI, ddl), check.IsNil)
+	}
+	c.Assert(h.Close(), check.IsNil)
+
+	readManifest := func(writer string) map[string]interface{} {
+		data, err := ioutil.ReadFile(filepath.Join(dir, writer, "manifest.json"))
+		c.Assert(err, check.IsNil)
+		manifest := make(map[string]interface{})
+		c.Assert(json.Unmarshal(data, &manifest), check.IsNil)
+		return manifest
+	}
+	manifest := readManifest(sink.DDLWriter)
+	c.Assert(manifest["resolved-ts"], check.Equals, float64(101))
+	streams := manifest["streams"].([]interface{})
+	c.Assert(streams, check.HasLen, 1)
+	stream := streams[0].(map[string]interface{})
+	c.Assert(stream["dir"], check.Equals, "ddl")
+	c.Assert(stream["seq"], check.Equals, float64(1))
+	// both DDLs are in the same file
+	data, err := ioutil.ReadFile(filepath.Join(dir, sink.DDLWriter, "ddl", "000001.log"))
+	c.Assert(err, check.IsNil)
+	c.Assert(stream["size"], check.Equals, float64(len(data)))
+	c.Assert(bytes.Contains(data, []byte("create table t2(id int)")), check.IsTrue)
+	c.Assert(bytes.Contains(data, []byte("create table t3(id int)")), check.IsTrue)
+
+	// the manifest of the processor is kept
+	manifest = readManifest("capture-1")
+	c.Assert(manifest["resolved-ts"], check.Equals, float64(100))
+	streams = manifest["streams"].([]interface{})
+	c.Assert(streams, check.HasLen, 1)
+	c.Assert(streams[0].(map[string]interface{})["dir"], check.Equals, "test/t1")
+}
+
+func (s *ownerSuite) TestApplyRenameTableJob(c *check.C) {
+	dbInfo := &timodel.DBInfo{ID: 1, Name: timodel.NewCIStr("test"), State: timodel.StatePublic}
+	newJob := func(id int64, tp timodel.ActionType, table string, ts uint64) *timodel.Job {
+		return &timodel.Job{
+			ID:       id,
+			State:    timodel.JobStateSynced,
+			SchemaID: 1,
+			TableID:  2,
+			Type:     tp,
+			Query:    "ddl",
+			BinlogInfo: &timodel.HistoryInfo{
+				SchemaVersion: id,
+				TableInfo:     &timodel.TableInfo{ID: 2, Name: timodel.NewCIStr(table)},
+				FinishedTS:    ts,
+			},
+		}
+	}
+	schemaStorage, err := schema.NewStorage([]*timodel.Job{
+		{
+			ID:         1,
+			State:      timodel.JobStateSynced,
+			SchemaID:   1,
+			Type:       timodel.ActionCreateSchema,
+			BinlogInfo: &timodel.HistoryInfo{SchemaVersion: 1, DBInfo: dbInfo, FinishedTS: 10},
+			Query:      "create database test",
+		},
+		newJob(2, timodel.ActionCreateTable, "t1", 20),
+	}, false)
+	c.Assert(err, check.IsNil)
+	c.Assert(schemaStorage.HandlePreviousDDLJobIfNeed(20), check.IsNil)
+	f, err := filter.New(&filter.Rules{IgnoreTables: []*filter.Table{{Schema: "test", Name: "ignored*"}}}, nil)
+	c.Assert(err, check.IsNil)
+	cfInfo := &changeFeedInfo{
+		filter:        f,
+		schema:        schemaStorage,
+		tables:        map[uint64]schema.TableName{2: {Schema: "test", Table: "t1"}},
+		orphanTables:  make(map[uint64]model.ProcessTableInfo),
+		toCleanTables: make(map[uint64]struct{}),
+	}
+
+	// the table is renamed out of the filter
+	c.Assert(cfInfo.applyJob(newJob(3, timodel.ActionRenameTable, "ignored1", 30)), check.IsNil)
+	c.Assert(cfInfo.tables, check.HasLen, 0)
+	c.Assert(cfInfo.toCleanTables, check.DeepEquals, map[uint64]struct{}{2: {}})
+
+	// the table is renamed back into the filter
+	c.Assert(cfInfo.applyJob(newJob(4, timodel.ActionRenameTable, "t2", 40)), check.IsNil)
+	c.Assert(cfInfo.tables, check.DeepEquals, map[uint64]schema.TableName{2: {Schema: "test", Table: "t2"}})
+	c.Assert(cfInfo.orphanTables, check.DeepEquals, map[uint64]model.ProcessTableInfo{2: {ID: 2, StartTs: 40}})
+
+	c.Assert(cfInfo.applyJob(newJob(5, timodel.ActionRenameTable, "t3", 50)), check.IsNil)
+	c.Assert(cfInfo.tables, check.DeepEquals, map[uint64]schema.TableName{2: {Schema: "test", Table: "t3"}})
+
+	// the renamed table is checked against the column rules
+	cfInfo.projector, err = projection.New([]*projection.Rule{{SchemaPattern: "test", TablePattern: "t4", Exclude: []string{"id"}}})
+	c.Assert(err, check.IsNil)
+	job := newJob(6, timodel.ActionRenameTable, "t4", 60)
+	idCol := &timodel.ColumnInfo{Name: timodel.NewCIStr("id")}
+	idCol.Flag = mysql.PriKeyFlag
+	job.BinlogInfo.TableInfo.PKIsHandle = true
+	job.BinlogInfo.TableInfo.Columns = []*timodel.ColumnInfo{idCol}
+	c.Assert(cfInfo.applyJob(job), check.ErrorMatches, "key column id of test.t4 can't be dropped.*")
+}
+
+type failingDDLStateWriter struct {
+	ddlStateRecorder
+	failed model.ChangeFeedID
+}
+
+func (w *failingDDLStateWriter) WriteDDLState(ctx context.Context, changefeedID model.ChangeFeedID, state *model.ChangeFeedDDLState) error {
+	if changefeedID == w.failed {
+		return errors.New("mock write ddl state error")
+	}
+	return w.ddlStateRecorder.WriteDDLState(ctx, changefeedID, state)
+}
+
+func (s *ownerSuite) TestHandleDDLStateError(c *check.C) {
+	newCfInfo := func(id model.ChangeFeedID, handler OwnerDDLHandler) *changeFeedInfo {
+		return &changeFeedInfo{
+			ID:             id,
+			ChangeFeedInfo: &model.ChangeFeedInfo{CheckpointTs: 100},
+			Status:         model.ChangeFeedWaitToExecDDL,
+			ProcessorInfos: model.ProcessorsInfos{"capture": {CheckPointTs: 100}},
+			ddlHandler:     handler,
+			ddlJobHistory: []*model.DDL{
+				{Job: &timodel.Job{ID: 1, Query: "create table t(id int)", BinlogInfo: &timodel.HistoryInfo{FinishedTS: 100}}},
+			},
+		}
+	}
+	brokenHandler, okHandler := &execDDLRecorder{}, &execDDLRecorder{}
+	states := &failingDDLStateWriter{failed: "broken"}
+	recorder := &adminJobRecorder{applied: make(map[model.ChangeFeedID]*model.ChangeFeedInfo)}
+	owner := &ownerImpl{
+		changeFeedInfos: map[model.ChangeFeedID]*changeFeedInfo{
+			"broken": newCfInfo("broken", brokenHandler),
+			"ok":     newCfInfo("ok", okHandler),
+		},
+		adminJobRWriter: recorder,
+		ddlStateRWriter: states,
+		manager:         roles.NewMockManager("owner", func() {}),
+	}
+
+	// the failure of a changefeed stops it only rather than the owner
+	c.Assert(owner.handleDDL(context.Background()), check.IsNil)
+	c.Assert(owner.changeFeedInfos, check.HasLen, 1)
+	c.Assert(owner.changeFeedInfos["ok"].Status, check.Equals, model.ChangeFeedSyncDML)
+	c.Assert(okHandler.queries, check.HasLen, 1)
+	c.Assert(brokenHandler.closed, check.IsTrue)
+	c.Assert(states.states, check.HasLen, 1)
+	c.Assert(recorder.appliedJobs, check.HasLen, 1)
+	c.Assert(recorder.appliedJobs[0].CfID, check.Equals, "broken")
+	c.Assert(recorder.appliedJobs[0].Type, check.Equals, model.AdminStop)
+	c.Assert(recorder.appliedJobs[0].Error.Message, check.Matches, ".*mock write ddl state error.*")
+}
@@ -15,8 +15,7 @@ package cdc
 
 import (
 	"context"
-	"fmt"
-	"io"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -28,12 +27,13 @@ import (
 	"github.com/pingcap/log"
 	pd "github.com/pingcap/pd/client"
 	"github.com/pingcap/ticdc/cdc/entry"
-	"github.com/pingcap/ticdc/cdc/kv"
 	"github.com/pingcap/ticdc/cdc/model"
 	"github.com/pingcap/ticdc/cdc/puller"
 	"github.com/pingcap/ticdc/cdc/roles/storage"
 	"github.com/pingcap/ticdc/cdc/schema"
 	"github.com/pingcap/ticdc/cdc/sink"
+	"github.com/pingcap/ticdc/pkg/filter"
+	"github.com/pingcap/ticdc/pkg/projection"
 	"github.com/pingcap/ticdc/pkg/retry"
 	"github.com/pingcap/ticdc/pkg/util"
 	"github.com/pingcap/tidb/store/tikv/oracle"
@@ -43,15 +43,22 @@ import (
 )
 
 var (
-	fCreateSchema = createSchemaStore
 	fNewPDCli     = pd.NewClient
 	fNewTsRWriter = createTsRWriter
 	fNewMounter   = newMounter
-	fNewMySQLSink = sink.NewMySQLSink
+	fNewSink      = sink.NewSink
+
+	// checkpointFlushInterval is how often the sink is flushed to advance
+	// the checkpoint ts.
+	checkpointFlushInterval = time.Second
 )
 
+// enableOldValueOpt is the changefeed option to mount updates with the old
+// values of the rows, which are read from TiKV.
+const enableOldValueOpt = "enable-old-value"
+
 type mounter interface {
-	Mount(rawTxn model.RawTxn) (*model.Txn, error)
+	Mount(ctx context.Context, rawTxn model.RawTxn) (*model.Txn, error)
 }
 
 type txnChannel struct {
@@ -162,13 +169,15 @@ type processor struct {
 	pdCli   pd.Client
 	etcdCli *clientv3.Client
 
-	mounter       mounter
-	schemaStorage *schema.Storage
-	sink          sink.Sink
-
-	ddlPuller    puller.Puller
-	ddlJobsCh    chan model.RawTxn
-	ddlResolveTS uint64
+	mounter mounter
+	// schemaStorage is shared by the processors of the capture, which pulls
+	// the DDL jobs into it
+	schemaStorage *schema.MultiVersionStorage
+	// schemaView is the schema as of the commit ts of the changes emitted to the sink
+	schemaView *schema.StorageView
+	filter     *filter.Filter
+	projector  *projection.Projector
+	sink       sink.Sink
 
 	tsRWriter       storage.ProcessorTsRWriter
 	resolvedEntries chan ProcessorEntry
@@ -200,7 +209,7 @@ func (t *tableInfo) storeResolvedTS(ts uint64) {
 }
 
 // NewProcessor creates and returns a processor for the specified change feed
-func NewProcessor(pdEndpoints []string, changefeed model.ChangeFeedDetail, changefeedID, captureID string) (*processor, error) {
+func NewProcessor(pdEndpoints []string, schemaStorage *schema.MultiVersionStorage, changefeed model.ChangeFeedDetail, changefeedID, captureID string) (*processor, error) {
 	pdCli, err := fNewPDCli(pdEndpoints, pd.SecurityOption{})
 	if err != nil {
 		return nil, errors.Annotatef(err, "create pd client failed, addr: %v", pdEndpoints)
@@ -217,24 +226,46 @@ func NewProcessor(pdEndpoints []string, changefeed model.ChangeFeedDetail, chang
 		return nil, errors.Annotate(err, "new etcd client")
 	}
 
-	schemaStorage, err := fCreateSchema(pdEndpoints)
-	if err != nil {
-		return nil, err
-	}
-
 	tsRWriter, err := fNewTsRWriter(etcdCli, changefeedID, captureID)
 	if err != nil {
 		return nil, errors.Annotate(err, "failed to create ts RWriter")
 	}
 
-	// The key in DDL kv pair returned from TiKV is already memcompariable encoded,
-	// so we set `needEncode` to false.
-	ddlPuller := puller.NewPuller(pdCli, changefeed.GetCheckpointTs(), []util.Span{util.GetDDLSpan()}, false)
+	var fetcher entry.OldValueFetcher
+	if enabled, _ := strconv.ParseBool(changefeed.Opts[enableOldValueOpt]); enabled {
+		kvStore, err := createTiStore(strings.Join(pdEndpoints, ","))
+		if err != nil {
+			return nil, errors.Annotate(err, "create tikv store for old values")
+		}
+		fetcher = entry.NewSnapshotOldValueFetcher(kvStore)
+	}
 
 	// TODO: get time zone from config
-	mounter := fNewMounter(schemaStorage)
+	// the mounter decodes the rows with the tables as of their commit ts
+	mounter := fNewMounter(schemaStorage, fetcher)
+
+	filter, err := changefeed.Filter()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
 
-	sink, err := fNewMySQLSink(changefeed.SinkURI, schemaStorage, changefeed.Opts)
+	router, err := changefeed.Router()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	projector, err := changefeed.Projector()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	sinkOpts := make(map[string]string, len(changefeed.Opts)+1)
+	for k, v := range changefeed.Opts {
+		sinkOpts[k] = v
+	}
+	sinkOpts[sink.WriterOpt] = captureID
+	schemaView := schemaStorage.NewView(changefeed.GetCheckpointTs())
+	sink, err := fNewSink(changefeed.SinkURI, filter, router, schemaView, sinkOpts)
 	if err != nil {
 		return nil, err
 	}
@@ -247,30 +278,33 @@ func NewProcessor(pdEndpoints []string, changefeed model.ChangeFeedDetail, chang
 		etcdCli:       etcdCli,
 		mounter:       mounter,
 		schemaStorage: schemaStorage,
+		schemaView:    schemaView,
+		filter:        filter,
+		projector:     projector,
 		sink:          sink,
-		ddlPuller:     ddlPuller,
 
 		tsRWriter:       tsRWriter,
 		subInfo:         tsRWriter.GetSubChangeFeedInfo(),
 		resolvedEntries: make(chan ProcessorEntry, 1),
 		executedEntries: make(chan ProcessorEntry, 1),
-		ddlJobsCh:       make(chan model.RawTxn, 16),
 
 		tables: make(map[int64]*tableInfo),
 	}
 
-	for _, table := range p.subInfo.TableInfos {
-		p.addTable(context.Background(), int64(table.ID), table.StartTs)
-	}
-
 	return p, nil
 }
 
+// Run starts the processor, the processor exits when the ctx is canceled or
+// any worker fails, then the error, which may be nil, is sent to errCh.
 func (p *processor) Run(ctx context.Context, errCh chan<- error) {
 	wg, cctx := errgroup.WithContext(ctx)
 	p.wg = wg
 	p.errCh = errCh
 
+	for _, table := range p.subInfo.TableInfos {
+		p.addTable(cctx, int64(table.ID), table.StartTs)
+	}
+
 	wg.Go(func() error {
 		return p.localResolvedWorker(cctx)
 	})
@@ -283,27 +317,31 @@ func (p *processor) Run(ctx context.Context, errCh chan<- error) {
 		return p.syncResolved(cctx)
 	})
 
-	wg.Go(func() error {
-		return p.pullDDLJob(cctx)
-	})
-
 	go func() {
-		if err := wg.Wait(); err != nil {
-			errCh <- err
-		}
+		err := wg.Wait()
+		p.stop()
+		errCh <- err
 	}()
 }
 
-func (p *processor) writeDebugInfo(w io.Writer) {
-	fmt.Fprintf(w, "changefeedID: %s, detail: %+v, subInfo: %+v\n", p.changefeedID, p.changefeed, p.subInfo)
-
+// stop releases the resources of the processor after all the workers exit
+func (p *processor) stop() {
 	p.tablesMu.Lock()
 	for _, table := range p.tables {
-		fmt.Fprintf(w, "\ttable id: %d, resolveTS: %d\n", table.id, table.loadResolvedTS())
+		table.puller.Cancel()
 	}
 	p.tablesMu.Unlock()
 
-	fmt.Fprintf(w, "\n")
+	if err := p.sink.Close(); err != nil {
+		log.Warn("failed to close the sink", zap.String("changefeed id", p.changefeedID), zap.Error(err))
+	}
+	if p.pdCli != nil {
+		p.pdCli.Close()
+	}
+	if err := p.etcdCli.Close(); err != nil {
+		log.Warn("failed to close the etcd client", zap.String("changefeed id", p.changefeedID), zap.Error(err))
+	}
+	log.Info("processor stopped", zap.String("changefeed id", p.changefeedID))
 }
 
 // localResolvedWorker do the flowing works.
@@ -337,7 +375,8 @@ func (p *processor) localResolvedWorker(ctx context.Context) error {
 				continue
 			}
 
-			minResolvedTs := atomic.LoadUint64(&p.ddlResolveTS)
+			// the changes after the resolved ts of the schema storage can't be mounted
+			minResolvedTs := p.schemaStorage.ResolvedTs()
 
 			for _, table := range p.tables {
 				ts := table.loadResolvedTS()
@@ -353,9 +392,14 @@ func (p *processor) localResolvedWorker(ctx context.Context) error {
 				log.Info("Checkpoint worker exited")
 				return nil
 			}
+			// the checkpoint ts only advances to what the sink has acknowledged,
+			// which may be less than the resolved ts handed to it
 			if e.Typ == processorEntryResolved {
-				p.subInfo.CheckPointTs = e.Ts
-				checkpointTsGauge.WithLabelValues(p.changefeedID, p.captureID).Set(float64(oracle.ExtractPhysical(e.Ts)))
+				checkpointTs := p.sink.CheckpointTs()
+				if checkpointTs > p.subInfo.CheckPointTs {
+					p.subInfo.CheckPointTs = checkpointTs
+					checkpointTsGauge.WithLabelValues(p.changefeedID, p.captureID).Set(float64(oracle.ExtractPhysical(checkpointTs)))
+				}
 			}
 		case <-updateInfoTick.C:
 			err := retry.Run(func() error {
@@ -522,66 +566,28 @@ func (p *processor) globalResolvedWorker(ctx context.Context) error {
 	}
 }
 
-// pullDDLJob push ddl job into `p.ddlJobsCh`.
-func (p *processor) pullDDLJob(ctx context.Context) error {
-	defer close(p.ddlJobsCh)
-	errg, ctx := errgroup.WithContext(ctx)
-
-	errg.Go(func() error {
-		return p.ddlPuller.Run(ctx)
-	})
-
-	errg.Go(func() error {
-		err := p.ddlPuller.CollectRawTxns(ctx, func(ctxInner context.Context, rawTxn model.RawTxn) error {
-			select {
-			case <-ctxInner.Done():
-				return ctxInner.Err()
-			case p.ddlJobsCh <- rawTxn:
-				return nil
-			}
-		})
-		if err != nil {
-			return errors.Annotate(err, "span: ddl")
-		}
-		return nil
-	})
-	return errg.Wait()
-}
-
-// syncResolved handle `p.ddlJobsCh` and `p.resolvedEntries`
+// syncResolved handle `p.resolvedEntries`, the sink is flushed regularly
+// instead of at every resolved ts, as flushing waits for all pending writes.
 func (p *processor) syncResolved(ctx context.Context) error {
+	flushTick := time.NewTicker(checkpointFlushInterval)
+	defer flushTick.Stop()
+
+	var resolvedTs, flushedTs uint64
 	for {
 		select {
-		case rawTxn, ok := <-p.ddlJobsCh:
-			if !ok {
-				return nil
-			}
-			if len(rawTxn.Entries) == 0 {
-				// fake txn
-				atomic.StoreUint64(&p.ddlResolveTS, rawTxn.Ts)
-				continue
-			}
-			t, err := p.mounter.Mount(rawTxn)
-			if err != nil {
-				return errors.Trace(err)
-			}
-			if !t.IsDDL() {
-				continue
-			}
-			p.schemaStorage.AddJob(t.DDL.Job)
-			atomic.StoreUint64(&p.ddlResolveTS, rawTxn.Ts)
 		case e, ok := <-p.resolvedEntries:
 			if !ok {
 				return nil
 			}
 			switch e.Typ {
 			case processorEntryDMLS:
-				err := p.schemaStorage.HandlePreviousDDLJobIfNeed(e.Ts)
+				txn, err := p.mounter.Mount(ctx, e.Txn)
 				if err != nil {
 					return errors.Trace(err)
 				}
-				txn, err := p.mounter.Mount(e.Txn)
-				if err != nil {
+				p.schemaView.SetTs(e.Ts)
+				filterDMLEvents(p.filter, txn)
+				if err := projectDMLs(p.projector, txn); err != nil {
 					return errors.Trace(err)
 				}
 				if err := p.sink.Emit(ctx, *txn); err != nil {
@@ -589,11 +595,24 @@ func (p *processor) syncResolved(ctx context.Context) error {
 				}
 				txnCounter.WithLabelValues("executed", p.changefeedID, p.captureID).Inc()
 			case processorEntryResolved:
-				select {
-				case p.executedEntries <- e:
-				case <-ctx.Done():
-					return errors.Trace(ctx.Err())
+				if err := p.sink.EmitResolvedTimestamp(ctx, e.Ts); err != nil {
+					return errors.Trace(err)
 				}
+				resolvedTs = e.Ts
+			}
+		case <-flushTick.C:
+			// the checkpoint ts can't advance without a new resolved ts
+			if resolvedTs <= flushedTs {
+				continue
+			}
+			if err := p.sink.Flush(ctx); err != nil {
+				return errors.Trace(err)
+			}
+			flushedTs = resolvedTs
+			select {
+			case p.executedEntries <- newProcessorResolvedEntry(flushedTs):
+			case <-ctx.Done():
+				return errors.Trace(ctx.Err())
 			}
 		case <-ctx.Done():
 			return ctx.Err()
@@ -601,23 +620,6 @@ func (p *processor) syncResolved(ctx context.Context) error {
 	}
 }
 
-func createSchemaStore(pdEndpoints []string) (*schema.Storage, error) {
-	// here we create another pb client,we should reuse them
-	kvStore, err := createTiStore(strings.Join(pdEndpoints, ","))
-	if err != nil {
-		return nil, err
-	}
-	jobs, err := kv.LoadHistoryDDLJobs(kvStore)
-	if err != nil {
-		return nil, err
-	}
-	schemaStorage, err := schema.NewStorage(jobs, false)
-	if err != nil {
-		return nil, errors.Trace(err)
-	}
-	return schemaStorage, nil
-}
-
 func createTsRWriter(cli *clientv3.Client, changefeedID, captureID string) (storage.ProcessorTsRWriter, error) {
 	return storage.NewProcessorTsEtcdRWriter(cli, changefeedID, captureID)
 }
@@ -684,7 +686,7 @@ func (p *processor) startPuller(ctx context.Context, span util.Span, checkpointT
 
 	go func() {
 		err := errg.Wait()
-		if errors.Cause(err) != context.Canceled {
+		if err != nil && errors.Cause(err) != context.Canceled {
 			errCh <- err
 		}
 	}()
@@ -692,6 +694,43 @@ func (p *processor) startPuller(ctx context.Context, span util.Span, checkpointT
 	return puller
 }
 
-func newMounter(schema *schema.Storage) mounter {
+// filterDMLEvents removes the DMLs of the event types ignored by the filter
+func filterDMLEvents(f *filter.Filter, t *model.Txn) {
+	dmls := make([]*model.DML, 0, len(t.DMLs))
+	for _, dml := range t.DMLs {
+		var event filter.EventType
+		switch dml.Tp {
+		case model.InsertDMLType:
+			event = filter.InsertEvent
+		case model.UpdateDMLType:
+			event = filter.UpdateEvent
+		case model.DeleteDMLType:
+			event = filter.DeleteEvent
+		}
+		if event != "" && f.ShouldIgnoreDMLEvent(dml.Database, dml.Table, event) {
+			continue
+		}
+		dmls = append(dmls, dml)
+	}
+	t.DMLs = dmls
+}
+
+// projectDMLs drops and transforms the column values of the DMLs by the column rules
+func projectDMLs(p *projection.Projector, t *model.Txn) error {
+	for _, dml := range t.DMLs {
+		if err := p.Project(dml.Database, dml.Table, dml.Values); err != nil {
+			return errors.Trace(err)
+		}
+		if err := p.Project(dml.Database, dml.Table, dml.OldValues); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
+
+func newMounter(schema entry.SchemaGetter, fetcher entry.OldValueFetcher) mounter {
+	if fetcher != nil {
+		return entry.NewTxnMounterWithOldValue(schema, fetcher)
+	}
 	return entry.NewTxnMounter(schema)
 }
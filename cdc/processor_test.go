@@ -21,12 +21,18 @@ import (
 
 	"github.com/coreos/etcd/clientv3"
 	"github.com/pingcap/check"
+	"github.com/pingcap/errors"
 	pd "github.com/pingcap/pd/client"
+	"github.com/pingcap/ticdc/cdc/entry"
 	"github.com/pingcap/ticdc/cdc/model"
 	"github.com/pingcap/ticdc/cdc/roles/storage"
 	"github.com/pingcap/ticdc/cdc/schema"
 	"github.com/pingcap/ticdc/cdc/sink"
 	"github.com/pingcap/ticdc/pkg/etcd"
+	"github.com/pingcap/ticdc/pkg/filter"
+	"github.com/pingcap/ticdc/pkg/projection"
+	"github.com/pingcap/ticdc/pkg/router"
+	"github.com/pingcap/tidb/types"
 )
 
 type processorSuite struct{}
@@ -79,15 +85,17 @@ func (s *mockTsRWriter) SetGlobalResolvedTs(ts uint64) {
 // mockMounter pretend to decode a RawTxn by returning a Txn of the same Ts
 type mockMounter struct{}
 
-func (m mockMounter) Mount(rawTxn model.RawTxn) (*model.Txn, error) {
+func (m mockMounter) Mount(ctx context.Context, rawTxn model.RawTxn) (*model.Txn, error) {
 	return &model.Txn{Ts: rawTxn.Ts}, nil
 }
 
 // mockSinker append all received Txns for validation
 type mockSinker struct {
 	sink.Sink
-	synced []model.Txn
-	mu     sync.Mutex
+	synced     []model.Txn
+	resolvedTs uint64
+	flushes    int
+	mu         sync.Mutex
 }
 
 func (m *mockSinker) Emit(ctx context.Context, t model.Txn) error {
@@ -97,6 +105,26 @@ func (m *mockSinker) Emit(ctx context.Context, t model.Txn) error {
 	return nil
 }
 
+func (m *mockSinker) EmitResolvedTimestamp(ctx context.Context, resolved uint64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.resolvedTs = resolved
+	return nil
+}
+
+func (m *mockSinker) Flush(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.flushes++
+	return nil
+}
+
+func (m *mockSinker) CheckpointTs() uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.resolvedTs
+}
+
 var _ = check.Suite(&processorSuite{})
 
 type processorTestCase struct {
@@ -123,10 +151,6 @@ func (p *processorSuite) TestProcessor(c *check.C) {
 }
 
 func runCase(c *check.C, cases *processorTestCase) {
-	origFSchema := fCreateSchema
-	fCreateSchema = func(pdEndpoints []string) (*schema.Storage, error) {
-		return nil, nil
-	}
 	origFNewPD := fNewPDCli
 	fNewPDCli = func(pdAddrs []string, security pd.SecurityOption) (pd.Client, error) {
 		return nil, nil
@@ -136,20 +160,19 @@ func runCase(c *check.C, cases *processorTestCase) {
 		return &mockTsRWriter{}, nil
 	}
 	origFNewMounter := fNewMounter
-	fNewMounter = func(schema *schema.Storage) mounter {
+	fNewMounter = func(schema entry.SchemaGetter, fetcher entry.OldValueFetcher) mounter {
 		return mockMounter{}
 	}
-	origFNewSink := fNewMySQLSink
+	origFNewSink := fNewSink
 	sinker := &mockSinker{}
-	fNewMySQLSink = func(sinkURI string, infoGetter sink.TableInfoGetter, opts map[string]string) (sink.Sink, error) {
+	fNewSink = func(sinkURI string, filter *filter.Filter, router *router.Router, infoGetter sink.TableInfoGetter, opts map[string]string) (sink.Sink, error) {
 		return sinker, nil
 	}
 	defer func() {
-		fCreateSchema = origFSchema
 		fNewPDCli = origFNewPD
 		fNewTsRWriter = origFNewTsRw
 		fNewMounter = origFNewMounter
-		fNewMySQLSink = origFNewSink
+		fNewSink = origFNewSink
 	}()
 
 	dir := c.MkDir()
@@ -157,7 +180,9 @@ func runCase(c *check.C, cases *processorTestCase) {
 	c.Assert(err, check.IsNil)
 	defer etcd.Close()
 
-	p, err := NewProcessor([]string{etcdURL.String()}, model.ChangeFeedDetail{}, "", "")
+	schemaStorage, err := schema.NewMultiVersionStorage(nil, false)
+	c.Assert(err, check.IsNil)
+	p, err := NewProcessor([]string{etcdURL.String()}, schemaStorage, model.ChangeFeedDetail{}, "", "")
 	c.Assert(err, check.IsNil)
 	errCh := make(chan error, 1)
 	ctx, cancel := context.WithCancel(context.Background())
@@ -235,6 +260,102 @@ func (p *processorSuite) TestDiffProcessTableInfos(c *check.C) {
 	}
 }
 
+func (p *processorSuite) TestFilterDMLEvents(c *check.C) {
+	f, err := filter.New(nil, []*filter.EventRule{{
+		SchemaPattern: "archive",
+		TablePattern:  "*",
+		Events:        []filter.EventType{filter.DeleteEvent},
+		Action:        "Ignore",
+	}})
+	c.Assert(err, check.IsNil)
+	txn := &model.Txn{
+		Ts: 1,
+		DMLs: []*model.DML{
+			{Database: "archive", Table: "t", Tp: model.DeleteDMLType},
+			{Database: "archive", Table: "t", Tp: model.InsertDMLType},
+			{Database: "test", Table: "t", Tp: model.DeleteDMLType},
+		},
+	}
+	filterDMLEvents(f, txn)
+	c.Assert(txn.DMLs, check.DeepEquals, []*model.DML{
+		{Database: "archive", Table: "t", Tp: model.InsertDMLType},
+		{Database: "test", Table: "t", Tp: model.DeleteDMLType},
+	})
+}
+
+func (p *processorSuite) TestProjectDMLs(c *check.C) {
+	projector, err := projection.New([]*projection.Rule{{
+		SchemaPattern: "test",
+		TablePattern:  "user",
+		Exclude:       []string{"ssn"},
+		Transforms:    map[string]projection.Transform{"phone": projection.TransformNull},
+	}})
+	c.Assert(err, check.IsNil)
+	txn := &model.Txn{
+		Ts: 1,
+		DMLs: []*model.DML{{
+			Database: "test",
+			Table:    "user",
+			Tp:       model.UpdateDMLType,
+			Values: map[string]types.Datum{
+				"id":    types.NewIntDatum(1),
+				"phone": types.NewStringDatum("13800001234"),
+				"ssn":   types.NewStringDatum("123-45-6789"),
+			},
+			OldValues: map[string]types.Datum{
+				"id":  types.NewIntDatum(1),
+				"ssn": types.NewStringDatum("123-45-6789"),
+			},
+		}},
+	}
+	c.Assert(projectDMLs(projector, txn), check.IsNil)
+	c.Assert(txn.DMLs[0].Values, check.DeepEquals, map[string]types.Datum{
+		"id":    types.NewIntDatum(1),
+		"phone": types.NewDatum(nil),
+	})
+	c.Assert(txn.DMLs[0].OldValues, check.DeepEquals, map[string]types.Datum{
+		"id": types.NewIntDatum(1),
+	})
+}
+
+func (p *processorSuite) TestSyncResolvedFlushesRegularly(c *check.C) {
+	defer func(interval time.Duration) {
+		checkpointFlushInterval = interval
+	}(checkpointFlushInterval)
+	checkpointFlushInterval = 50 * time.Millisecond
+
+	sinker := &mockSinker{}
+	proc := &processor{
+		sink:            sinker,
+		resolvedEntries: make(chan ProcessorEntry, 3),
+		executedEntries: make(chan ProcessorEntry, 1),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- proc.syncResolved(ctx)
+	}()
+
+	for _, ts := range []uint64{1, 2, 3} {
+		proc.resolvedEntries <- newProcessorResolvedEntry(ts)
+	}
+	select {
+	case e := <-proc.executedEntries:
+		c.Assert(e.Ts, check.Equals, uint64(3))
+	case <-time.After(time.Second):
+		c.Fatal("Timeout waiting for the flushed resolved ts")
+	}
+	// no flush without a new resolved ts
+	time.Sleep(4 * checkpointFlushInterval)
+	sinker.mu.Lock()
+	c.Assert(sinker.flushes, check.Equals, 1)
+	sinker.mu.Unlock()
+
+	cancel()
+	c.Assert(errors.Cause(<-errCh), check.Equals, context.Canceled)
+}
+
 type txnChannelSuite struct{}
 
 var _ = check.Suite(&txnChannelSuite{})
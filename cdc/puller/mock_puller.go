@@ -262,6 +262,11 @@ func (m *MockPullerManager) GetDDLJobs() []*timodel.Job {
 	return jobs
 }
 
+// GetStorage returns the mock TiKV storage
+func (m *MockPullerManager) GetStorage() tidbkv.Storage {
+	return m.store
+}
+
 func (p *mockPuller) sendRawTxn(ctx context.Context, rawTxn model.RawTxn, outputFn func(context.Context, model.RawTxn) error) {
 	toSend := model.RawTxn{Ts: rawTxn.Ts}
 	if len(rawTxn.Entries) > 0 {
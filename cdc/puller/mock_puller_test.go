@@ -59,7 +59,7 @@ func (s *mockPullerSuite) TestDDLPuller(c *check.C) {
 			for _, e := range rawTxn.Entries {
 				c.Assert(util.KeyInSpan(e.Key, util.GetDDLSpan()), check.IsTrue)
 			}
-			t, err := txnMounter.Mount(rawTxn)
+			t, err := txnMounter.Mount(ctx, rawTxn)
 			c.Assert(err, check.IsNil)
 			if !t.IsDDL() {
 				return nil
@@ -223,14 +223,7 @@ func (m *ownerManager) revokeSession(leaseID clientv3.LeaseID) {
 
 // GetOwnerID implements Manager.GetOwnerID interface.
 func (m *ownerManager) GetOwnerID(ctx context.Context) (string, error) {
-	resp, err := m.etcdCli.Get(ctx, m.key, clientv3.WithFirstCreate()...)
-	if err != nil {
-		return "", errors.Trace(err)
-	}
-	if len(resp.Kvs) == 0 {
-		return "", concurrency.ErrElectionNoLeader
-	}
-	return string(resp.Kvs[0].Value), nil
+	return GetOwnerID(ctx, m.etcdCli, m.key)
 }
 
 // GetOwnerInfo check the owner is id and return the owner key.
@@ -253,6 +246,19 @@ func GetOwnerInfo(ctx context.Context, elec *concurrency.Election, id string) (s
 	return string(resp.Kvs[0].Key), nil
 }
 
+// GetOwnerID returns the ID of the owner elected with the key, it returns
+// concurrency.ErrElectionNoLeader if there is no owner.
+func GetOwnerID(ctx context.Context, cli *clientv3.Client, key string) (string, error) {
+	resp, err := cli.Get(ctx, key, clientv3.WithFirstCreate()...)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	if len(resp.Kvs) == 0 {
+		return "", concurrency.ErrElectionNoLeader
+	}
+	return string(resp.Kvs[0].Value), nil
+}
+
 // RetireNotify implements Manager.RetireNotify
 func (m *ownerManager) RetireNotify() <-chan struct{} {
 	return m.retireCh
@@ -7,8 +7,10 @@ import (
 	"time"
 
 	"github.com/coreos/etcd/clientv3"
+	"github.com/coreos/etcd/clientv3/concurrency"
 	"github.com/coreos/etcd/embed"
 	"github.com/pingcap/check"
+	"github.com/pingcap/errors"
 	"github.com/pingcap/ticdc/pkg/etcd"
 	"github.com/pingcap/ticdc/pkg/util"
 	"golang.org/x/sync/errgroup"
@@ -90,6 +92,9 @@ func (s *managerSuite) TestManager(c *check.C) {
 	time.Sleep(time.Second)
 	c.Assert(m1.IsOwner(), check.IsTrue)
 	c.Assert(m2.IsOwner(), check.IsFalse)
+	ownerID, err := GetOwnerID(context.Background(), cli, "/test/owner")
+	c.Assert(err, check.IsNil)
+	c.Assert(ownerID, check.Equals, "m1")
 
 	// stop m1 and m2 become owner
 	m1cancel()
@@ -102,4 +107,10 @@ func (s *managerSuite) TestManager(c *check.C) {
 	time.Sleep(time.Second)
 	c.Assert(m1.IsOwner(), check.IsFalse)
 	c.Assert(m2.IsOwner(), check.IsTrue)
+	ownerID, err = GetOwnerID(context.Background(), cli, "/test/owner")
+	c.Assert(err, check.IsNil)
+	c.Assert(ownerID, check.Equals, "m2")
+
+	_, err = GetOwnerID(context.Background(), cli, "/test/no-owner")
+	c.Assert(errors.Cause(err), check.Equals, concurrency.ErrElectionNoLeader)
 }
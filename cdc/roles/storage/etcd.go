@@ -112,6 +112,164 @@ func (rw *ChangeFeedInfoRWriter) Write(ctx context.Context, infos map[model.Chan
 	return nil
 }
 
+// AdminJobEtcdRWriter reads the admin jobs from etcd and stores their results
+type AdminJobEtcdRWriter struct {
+	etcdClient *clientv3.Client
+}
+
+// NewAdminJobEtcdRWriter returns a new `*AdminJobEtcdRWriter` instance
+func NewAdminJobEtcdRWriter(cli *clientv3.Client) *AdminJobEtcdRWriter {
+	return &AdminJobEtcdRWriter{
+		etcdClient: cli,
+	}
+}
+
+// ReadAdminJobs returns the admin jobs which haven't been handled
+func (rw *AdminJobEtcdRWriter) ReadAdminJobs(ctx context.Context) ([]*model.AdminJob, error) {
+	jobs, err := kv.GetAdminJobs(ctx, rw.etcdClient)
+	return jobs, errors.Trace(err)
+}
+
+// ApplyAdminJob stores the result of the admin job and removes the job in one
+// etcd txn. info is the latest info of the changefeed, it's read from etcd if
+// it's nil. Stopping a changefeed removes its subchangefeed infos to stop the
+// processors, and removing a changefeed removes all of its keys.
+func (rw *AdminJobEtcdRWriter) ApplyAdminJob(ctx context.Context, job *model.AdminJob, info *model.ChangeFeedInfo) error {
+	// the trailing slash avoids removing the changefeeds with this id as a prefix
+	subChangeFeeds := kv.GetEtcdKeySubChangeFeedList(job.CfID) + "/"
+	var ops []clientv3.Op
+	switch job.Type {
+	case model.AdminStop, model.AdminResume:
+		if info == nil {
+			var err error
+			info, err = rw.ReadChangeFeedInfo(ctx, job.CfID)
+			if err != nil {
+				return errors.Trace(err)
+			}
+		}
+		if info == nil {
+			log.Warn("ignore the admin job of a non-existent changefeed", zap.String("changefeed", job.CfID))
+			break
+		}
+		newInfo := *info
+		newInfo.AdminJobType = job.Type
+		switch job.Type {
+		case model.AdminStop:
+			// the error is cleared if the changefeed is stopped by the user
+			newInfo.Error = job.Error
+			if job.Error != nil {
+				newInfo.ErrorCount++
+			}
+		case model.AdminResume:
+			// a manual resume resets the retry budget
+			newInfo.Finished = false
+			newInfo.Error = nil
+			newInfo.ErrorCount = 0
+		}
+		value, err := newInfo.Marshal()
+		if err != nil {
+			return errors.Trace(err)
+		}
+		ops = append(ops, clientv3.OpPut(kv.GetEtcdKeyChangeFeedStatus(job.CfID), value))
+		if job.Type == model.AdminStop {
+			ops = append(ops, clientv3.OpDelete(subChangeFeeds, clientv3.WithPrefix()))
+		}
+	case model.AdminRemove:
+		ops = append(ops,
+			clientv3.OpDelete(kv.GetEtcdKeyChangeFeedConfig(job.CfID)),
+			clientv3.OpDelete(kv.GetEtcdKeyChangeFeedStatus(job.CfID)),
+			clientv3.OpDelete(kv.GetEtcdKeyChangeFeedDDLState(job.CfID)),
+			clientv3.OpDelete(subChangeFeeds, clientv3.WithPrefix()),
+		)
+	default:
+		log.Warn("ignore unknown admin job", zap.String("changefeed", job.CfID), zap.Stringer("type", job.Type))
+	}
+
+	return rw.commitJob(ctx, job, ops)
+}
+
+// ApplyDDLOperation stores the info with the DDL operation of the job and the
+// audit record of the job, and removes the job in one etcd txn. info is nil if
+// the job is rejected.
+func (rw *AdminJobEtcdRWriter) ApplyDDLOperation(ctx context.Context, job *model.AdminJob, info *model.ChangeFeedInfo, record *model.DDLAuditRecord) error {
+	var ops []clientv3.Op
+	if info != nil {
+		value, err := info.Marshal()
+		if err != nil {
+			return errors.Trace(err)
+		}
+		ops = append(ops, clientv3.OpPut(kv.GetEtcdKeyChangeFeedStatus(job.CfID), value))
+	}
+	value, err := record.Marshal()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	ops = append(ops, clientv3.OpPut(kv.GetEtcdKeyDDLAudit(job.CfID, record.Time), value))
+	return rw.commitJob(ctx, job, ops)
+}
+
+// commitJob commits the ops with the removal of the job
+func (rw *AdminJobEtcdRWriter) commitJob(ctx context.Context, job *model.AdminJob, ops []clientv3.Op) error {
+	// a newer job of the changefeed is kept to be handled later
+	jobKey := kv.GetEtcdKeyAdminJob(job.CfID)
+	_, err := rw.etcdClient.KV.Txn(ctx).If(
+		clientv3.Compare(clientv3.ModRevision(jobKey), "=", job.ModRevision),
+	).Then(
+		append(ops, clientv3.OpDelete(jobKey))...,
+	).Else(
+		ops...,
+	).Commit()
+	return errors.Trace(err)
+}
+
+// ReadChangeFeedInfo reads the info of a changefeed which isn't running, it
+// returns nil if the changefeed doesn't exist.
+func (rw *AdminJobEtcdRWriter) ReadChangeFeedInfo(ctx context.Context, changefeedID string) (*model.ChangeFeedInfo, error) {
+	info, err := kv.GetChangeFeedInfo(ctx, rw.etcdClient, changefeedID)
+	if errors.Cause(err) != model.ErrChangeFeedNotExists {
+		return info, errors.Trace(err)
+	}
+	// the changefeed hasn't been started by the owner
+	detail, err := kv.GetChangeFeedDetail(ctx, rw.etcdClient, changefeedID)
+	switch errors.Cause(err) {
+	case nil:
+		return &model.ChangeFeedInfo{
+			SinkURI:      detail.SinkURI,
+			CheckpointTs: detail.GetCheckpointTs(),
+		}, nil
+	case model.ErrChangeFeedNotExists:
+		return nil, nil
+	default:
+		return nil, errors.Trace(err)
+	}
+}
+
+// DDLStateEtcdRWriter reads and writes the DDL states of the changefeeds in etcd
+type DDLStateEtcdRWriter struct {
+	etcdClient *clientv3.Client
+}
+
+// NewDDLStateEtcdRWriter returns a new `*DDLStateEtcdRWriter` instance
+func NewDDLStateEtcdRWriter(cli *clientv3.Client) *DDLStateEtcdRWriter {
+	return &DDLStateEtcdRWriter{
+		etcdClient: cli,
+	}
+}
+
+// ReadDDLState returns the DDL state of a changefeed, or nil if it hasn't been written
+func (rw *DDLStateEtcdRWriter) ReadDDLState(ctx context.Context, changefeedID string) (*model.ChangeFeedDDLState, error) {
+	state, err := kv.GetChangeFeedDDLState(ctx, rw.etcdClient, changefeedID)
+	if errors.Cause(err) == model.ErrChangeFeedNotExists {
+		return nil, nil
+	}
+	return state, errors.Trace(err)
+}
+
+// WriteDDLState writes the DDL state of a changefeed
+func (rw *DDLStateEtcdRWriter) WriteDDLState(ctx context.Context, changefeedID string, state *model.ChangeFeedDDLState) error {
+	return errors.Trace(kv.PutChangeFeedDDLState(ctx, rw.etcdClient, changefeedID, state))
+}
+
 // ProcessorTsRWriter reads or writes the resolvedTs and checkpointTs from the storage
 type ProcessorTsRWriter interface {
 	// ReadGlobalResolvedTs read the bloable resolved ts.
@@ -24,6 +24,7 @@ import (
 	"github.com/coreos/etcd/embed"
 	"github.com/pingcap/check"
 	"github.com/pingcap/errors"
+	timodel "github.com/pingcap/parser/model"
 	"github.com/pingcap/ticdc/cdc/kv"
 	"github.com/pingcap/ticdc/cdc/model"
 	"github.com/pingcap/ticdc/pkg/etcd"
@@ -365,3 +366,206 @@ func (s *etcdSuite) TestOwnerTableInfoWriter(c *check.C) {
 	c.Assert(err, check.IsNil)
 	c.Assert(info.TableInfos, check.HasLen, 1)
 }
+
+func (s *etcdSuite) TestAdminJob(c *check.C) {
+	var (
+		ctx          = context.Background()
+		changefeedID = "test-admin-job"
+		detail       = &model.ChangeFeedDetail{SinkURI: "blackhole://", StartTs: 100}
+		subInfo      = &model.SubChangeFeedInfo{CheckPointTs: 200}
+	)
+	err := kv.SaveChangeFeedDetail(ctx, s.client, detail, changefeedID)
+	c.Assert(err, check.IsNil)
+	err = kv.PutSubChangeFeedInfo(ctx, s.client, changefeedID, "capture1", subInfo)
+	c.Assert(err, check.IsNil)
+	// the changefeed with the id as a prefix isn't affected
+	err = kv.PutSubChangeFeedInfo(ctx, s.client, changefeedID+"-2", "capture1", subInfo)
+	c.Assert(err, check.IsNil)
+
+	rw := NewAdminJobEtcdRWriter(s.client)
+	readJob := func() *model.AdminJob {
+		jobs, err := rw.ReadAdminJobs(ctx)
+		c.Assert(err, check.IsNil)
+		c.Assert(jobs, check.HasLen, 1)
+		return jobs[0]
+	}
+
+	// stop the changefeed which hasn't been started
+	err = kv.PutAdminJob(ctx, s.client, &model.AdminJob{CfID: changefeedID, Type: model.AdminStop})
+	c.Assert(err, check.IsNil)
+	c.Assert(rw.ApplyAdminJob(ctx, readJob(), nil), check.IsNil)
+	info, err := kv.GetChangeFeedInfo(ctx, s.client, changefeedID)
+	c.Assert(err, check.IsNil)
+	c.Assert(info, check.DeepEquals, &model.ChangeFeedInfo{SinkURI: "blackhole://", CheckpointTs: 100, AdminJobType: model.AdminStop})
+	c.Assert(info.IsStopped(), check.IsTrue)
+	_, _, err = kv.GetSubChangeFeedInfo(ctx, s.client, changefeedID, "capture1")
+	c.Assert(errors.Cause(err), check.Equals, model.ErrSubChangeFeedInfoNotExists)
+	_, _, err = kv.GetSubChangeFeedInfo(ctx, s.client, changefeedID+"-2", "capture1")
+	c.Assert(err, check.IsNil)
+	jobs, err := rw.ReadAdminJobs(ctx)
+	c.Assert(err, check.IsNil)
+	c.Assert(jobs, check.HasLen, 0)
+
+	// the job replaced before it's applied is kept
+	err = kv.PutAdminJob(ctx, s.client, &model.AdminJob{CfID: changefeedID, Type: model.AdminResume})
+	c.Assert(err, check.IsNil)
+	job := readJob()
+	err = kv.PutAdminJob(ctx, s.client, &model.AdminJob{CfID: changefeedID, Type: model.AdminRemove})
+	c.Assert(err, check.IsNil)
+	// resuming a finished changefeed resets the finished flag
+	err = rw.ApplyAdminJob(ctx, job, &model.ChangeFeedInfo{SinkURI: "blackhole://", CheckpointTs: 300, AdminJobType: model.AdminStop, Finished: true})
+	c.Assert(err, check.IsNil)
+	info, err = kv.GetChangeFeedInfo(ctx, s.client, changefeedID)
+	c.Assert(err, check.IsNil)
+	c.Assert(info, check.DeepEquals, &model.ChangeFeedInfo{SinkURI: "blackhole://", CheckpointTs: 300, AdminJobType: model.AdminResume})
+
+	// remove the changefeed
+	job = readJob()
+	c.Assert(job.Type, check.Equals, model.AdminRemove)
+	c.Assert(rw.ApplyAdminJob(ctx, job, nil), check.IsNil)
+	_, err = kv.GetChangeFeedDetail(ctx, s.client, changefeedID)
+	c.Assert(errors.Cause(err), check.Equals, model.ErrChangeFeedNotExists)
+	_, err = kv.GetChangeFeedInfo(ctx, s.client, changefeedID)
+	c.Assert(errors.Cause(err), check.Equals, model.ErrChangeFeedNotExists)
+	jobs, err = rw.ReadAdminJobs(ctx)
+	c.Assert(err, check.IsNil)
+	c.Assert(jobs, check.HasLen, 0)
+
+	// the jobs of non-existent changefeeds are dropped
+	err = kv.PutAdminJob(ctx, s.client, &model.AdminJob{CfID: changefeedID, Type: model.AdminStop})
+	c.Assert(err, check.IsNil)
+	c.Assert(rw.ApplyAdminJob(ctx, readJob(), nil), check.IsNil)
+	_, err = kv.GetChangeFeedInfo(ctx, s.client, changefeedID)
+	c.Assert(errors.Cause(err), check.Equals, model.ErrChangeFeedNotExists)
+}
+
+func (s *etcdSuite) TestAdminJobWithError(c *check.C) {
+	var (
+		ctx          = context.Background()
+		changefeedID = "test-admin-job-error"
+		runningErr   = &model.RunningError{CaptureID: "capture1", Message: "test error", Time: time.Unix(100, 0)}
+	)
+	rw := NewAdminJobEtcdRWriter(s.client)
+	readInfo := func() *model.ChangeFeedInfo {
+		info, err := kv.GetChangeFeedInfo(ctx, s.client, changefeedID)
+		c.Assert(err, check.IsNil)
+		return info
+	}
+
+	// the errors are counted
+	info := &model.ChangeFeedInfo{SinkURI: "blackhole://", CheckpointTs: 100}
+	for i := 1; i <= 2; i++ {
+		err := rw.ApplyAdminJob(ctx, &model.AdminJob{CfID: changefeedID, Type: model.AdminStop, Error: runningErr}, info)
+		c.Assert(err, check.IsNil)
+		info = readInfo()
+		c.Assert(info.AdminJobType, check.Equals, model.AdminStop)
+		c.Assert(info.Error.Message, check.Equals, runningErr.Message)
+		c.Assert(info.Error.Time.Equal(runningErr.Time), check.IsTrue)
+		c.Assert(info.ErrorCount, check.Equals, i)
+	}
+
+	// stopping by the user clears the error but keeps the count
+	err := rw.ApplyAdminJob(ctx, &model.AdminJob{CfID: changefeedID, Type: model.AdminStop}, info)
+	c.Assert(err, check.IsNil)
+	info = readInfo()
+	c.Assert(info.Error, check.IsNil)
+	c.Assert(info.ErrorCount, check.Equals, 2)
+
+	// resuming resets the count
+	err = rw.ApplyAdminJob(ctx, &model.AdminJob{CfID: changefeedID, Type: model.AdminResume}, info)
+	c.Assert(err, check.IsNil)
+	c.Assert(readInfo(), check.DeepEquals, &model.ChangeFeedInfo{SinkURI: "blackhole://", CheckpointTs: 100, AdminJobType: model.AdminResume})
+}
+
+func (s *etcdSuite) TestApplyDDLOperation(c *check.C) {
+	var (
+		ctx          = context.Background()
+		changefeedID = "test-ddl-operation"
+		now          = time.Now()
+	)
+	rw := NewAdminJobEtcdRWriter(s.client)
+	info, err := rw.ReadChangeFeedInfo(ctx, changefeedID)
+	c.Assert(err, check.IsNil)
+	c.Assert(info, check.IsNil)
+
+	// the operation is applied
+	err = kv.PutAdminJob(ctx, s.client, &model.AdminJob{CfID: changefeedID, Type: model.AdminSkipDDL, DDLJobID: 10})
+	c.Assert(err, check.IsNil)
+	jobs, err := rw.ReadAdminJobs(ctx)
+	c.Assert(err, check.IsNil)
+	c.Assert(jobs, check.HasLen, 1)
+	info = &model.ChangeFeedInfo{
+		CheckpointTs:  100,
+		AdminJobType:  model.AdminSkipDDL,
+		DDLOperations: []*model.DDLOperation{{JobID: 10, FinishedTs: 100}},
+	}
+	applied := &model.DDLAuditRecord{ChangefeedID: changefeedID, Action: "skip-ddl", JobID: 10, FinishedTs: 100, OriginQuery: "drop table t", Time: now}
+	c.Assert(rw.ApplyDDLOperation(ctx, jobs[0], info, applied), check.IsNil)
+	info, err = rw.ReadChangeFeedInfo(ctx, changefeedID)
+	c.Assert(err, check.IsNil)
+	c.Assert(info.DDLOperations, check.DeepEquals, []*model.DDLOperation{{JobID: 10, FinishedTs: 100}})
+	jobs, err = rw.ReadAdminJobs(ctx)
+	c.Assert(err, check.IsNil)
+	c.Assert(jobs, check.HasLen, 0)
+
+	// the rejected operation is recorded only
+	job := &model.AdminJob{CfID: changefeedID, Type: model.AdminReplaceDDL, DDLJobID: 11, Query: "select 1"}
+	rejected := &model.DDLAuditRecord{ChangefeedID: changefeedID, Action: "replace-ddl", JobID: 11, NewQuery: "select 1", Time: now.Add(time.Second), Rejected: "test"}
+	c.Assert(rw.ApplyDDLOperation(ctx, job, nil, rejected), check.IsNil)
+	info, err = rw.ReadChangeFeedInfo(ctx, changefeedID)
+	c.Assert(err, check.IsNil)
+	c.Assert(info.AdminJobType, check.Equals, model.AdminSkipDDL)
+
+	records, err := kv.GetDDLAuditRecords(ctx, s.client, changefeedID)
+	c.Assert(err, check.IsNil)
+	c.Assert(records, check.HasLen, 2)
+	for i, expected := range []*model.DDLAuditRecord{applied, rejected} {
+		c.Assert(records[i].Time.Equal(expected.Time), check.IsTrue)
+		records[i].Time = expected.Time
+		c.Assert(records[i], check.DeepEquals, expected)
+	}
+}
+
+func (s *etcdSuite) TestDDLState(c *check.C) {
+	var (
+		ctx          = context.Background()
+		changefeedID = "test-ddl-state"
+		rw           = NewDDLStateEtcdRWriter(s.client)
+	)
+	state, err := rw.ReadDDLState(ctx, changefeedID)
+	c.Assert(err, check.IsNil)
+	c.Assert(state, check.IsNil)
+
+	expected := &model.ChangeFeedDDLState{
+		ResolvedTs: 200,
+		Jobs: []*model.DDL{{
+			Database: "test",
+			Table:    "t",
+			Job:      &timodel.Job{ID: 2, Query: "drop table t", BinlogInfo: &timodel.HistoryInfo{FinishedTS: 150}},
+		}},
+		LastHandled: &model.DDLJobRef{JobID: 1, FinishedTs: 100, Query: "create table t(id int)"},
+	}
+	c.Assert(rw.WriteDDLState(ctx, changefeedID, expected), check.IsNil)
+	state, err = rw.ReadDDLState(ctx, changefeedID)
+	c.Assert(err, check.IsNil)
+	c.Assert(state.ResolvedTs, check.Equals, expected.ResolvedTs)
+	c.Assert(state.LastHandled, check.DeepEquals, expected.LastHandled)
+	c.Assert(state.Jobs, check.HasLen, 1)
+	c.Assert(state.Jobs[0].Table, check.Equals, "t")
+	c.Assert(state.Jobs[0].Job.ID, check.Equals, int64(2))
+	c.Assert(state.Jobs[0].Job.BinlogInfo.FinishedTS, check.Equals, uint64(150))
+
+	// the state is removed with the changefeed
+	err = kv.SaveChangeFeedDetail(ctx, s.client, &model.ChangeFeedDetail{SinkURI: "blackhole://"}, changefeedID)
+	c.Assert(err, check.IsNil)
+	err = kv.PutAdminJob(ctx, s.client, &model.AdminJob{CfID: changefeedID, Type: model.AdminRemove})
+	c.Assert(err, check.IsNil)
+	jobRW := NewAdminJobEtcdRWriter(s.client)
+	jobs, err := jobRW.ReadAdminJobs(ctx)
+	c.Assert(err, check.IsNil)
+	c.Assert(jobs, check.HasLen, 1)
+	c.Assert(jobRW.ApplyAdminJob(ctx, jobs[0], nil), check.IsNil)
+	state, err = rw.ReadDDLState(ctx, changefeedID)
+	c.Assert(err, check.IsNil)
+	c.Assert(state, check.IsNil)
+}
@@ -25,6 +25,7 @@ import (
 	"github.com/pingcap/log"
 	"github.com/pingcap/ticdc/cdc/kv"
 	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/ticdc/cdc/schema"
 	"github.com/pingcap/ticdc/pkg/util"
 	"go.uber.org/zap"
 )
@@ -36,20 +37,32 @@ var (
 
 // ChangeFeedWatcher is a changefeed watcher
 type ChangeFeedWatcher struct {
-	lock        sync.RWMutex
-	captureID   string
-	pdEndpoints []string
-	etcdCli     *clientv3.Client
-	details     map[string]model.ChangeFeedDetail
+	lock          sync.RWMutex
+	captureID     string
+	pdEndpoints   []string
+	etcdCli       *clientv3.Client
+	schemaStorage *schema.MultiVersionStorage
+	details       map[string]model.ChangeFeedDetail
+	watchers      map[string]*runningProcessorWatcher
 }
 
-// NewChangeFeedWatcher creates a new changefeed watcher
-func NewChangeFeedWatcher(captureID string, pdEndpoints []string, cli *clientv3.Client) *ChangeFeedWatcher {
+// runningProcessorWatcher is the ProcessorWatcher of a changefeed, it's
+// canceled when the changefeed is removed.
+type runningProcessorWatcher struct {
+	watcher *ProcessorWatcher
+	cancel  context.CancelFunc
+}
+
+// NewChangeFeedWatcher creates a new changefeed watcher, the processors share
+// the schema storage of the capture.
+func NewChangeFeedWatcher(captureID string, pdEndpoints []string, cli *clientv3.Client, schemaStorage *schema.MultiVersionStorage) *ChangeFeedWatcher {
 	w := &ChangeFeedWatcher{
-		captureID:   captureID,
-		pdEndpoints: pdEndpoints,
-		etcdCli:     cli,
-		details:     make(map[string]model.ChangeFeedDetail),
+		captureID:     captureID,
+		pdEndpoints:   pdEndpoints,
+		etcdCli:       cli,
+		schemaStorage: schemaStorage,
+		details:       make(map[string]model.ChangeFeedDetail),
+		watchers:      make(map[string]*runningProcessorWatcher),
 	}
 	return w
 }
@@ -71,6 +84,10 @@ func (w *ChangeFeedWatcher) processPutKv(kv *mvccpb.KeyValue) (bool, string, mod
 		needRunWatcher = true
 	}
 	w.details[changefeedID] = detail
+	// the updated detail is used when the processor is restarted
+	if r, ok := w.watchers[changefeedID]; ok && r.watcher != nil {
+		r.watcher.updateDetail(detail)
+	}
 	w.lock.Unlock()
 	// TODO: this detail is not copied, should be readonly
 	return needRunWatcher, changefeedID, detail, nil
@@ -83,14 +100,25 @@ func (w *ChangeFeedWatcher) processDeleteKv(kv *mvccpb.KeyValue) error {
 	}
 	w.lock.Lock()
 	delete(w.details, changefeedID)
+	if r, ok := w.watchers[changefeedID]; ok {
+		r.cancel()
+		delete(w.watchers, changefeedID)
+	}
 	w.lock.Unlock()
 	return nil
 }
 
+// runProcessorWatcher runs the ProcessorWatcher of a changefeed
+func (w *ChangeFeedWatcher) runProcessorWatcher(ctx context.Context, changefeedID string, detail model.ChangeFeedDetail, cb processorCallback) {
+	cctx, cancel := context.WithCancel(ctx)
+	watcher := runProcessorWatcher(cctx, changefeedID, w.captureID, w.pdEndpoints, w.etcdCli, w.schemaStorage, detail, cb)
+	w.lock.Lock()
+	w.watchers[changefeedID] = &runningProcessorWatcher{watcher: watcher, cancel: cancel}
+	w.lock.Unlock()
+}
+
 // Watch watches changefeed key base
 func (w *ChangeFeedWatcher) Watch(ctx context.Context, cb processorCallback) error {
-	errCh := make(chan error, 1)
-
 	revision, details, err := kv.GetChangeFeeds(ctx, w.etcdCli)
 	if err != nil {
 		return err
@@ -101,7 +129,7 @@ func (w *ChangeFeedWatcher) Watch(ctx context.Context, cb processorCallback) err
 			return err
 		}
 		if needRunWatcher {
-			runProcessorWatcher(ctx, changefeedID, w.captureID, w.pdEndpoints, w.etcdCli, detail, errCh, cb)
+			w.runProcessorWatcher(ctx, changefeedID, detail, cb)
 		}
 	}
 
@@ -110,8 +138,6 @@ func (w *ChangeFeedWatcher) Watch(ctx context.Context, cb processorCallback) err
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
-		case err := <-errCh:
-			return err
 		case resp, ok := <-watchCh:
 			if !ok {
 				log.Info("watcher is closed")
@@ -129,7 +155,7 @@ func (w *ChangeFeedWatcher) Watch(ctx context.Context, cb processorCallback) err
 						return err
 					}
 					if needRunWatcher {
-						runProcessorWatcher(ctx, changefeedID, w.captureID, w.pdEndpoints, w.etcdCli, detail, errCh, cb)
+						w.runProcessorWatcher(ctx, changefeedID, detail, cb)
 					}
 				case mvccpb.DELETE:
 					err := w.processDeleteKv(ev.Kv)
@@ -144,13 +170,15 @@ func (w *ChangeFeedWatcher) Watch(ctx context.Context, cb processorCallback) err
 
 // ProcessorWatcher is a processor watcher
 type ProcessorWatcher struct {
-	pdEndpoints  []string
-	changefeedID string
-	captureID    string
-	etcdCli      *clientv3.Client
-	detail       model.ChangeFeedDetail
-	wg           sync.WaitGroup
-	closed       int32
+	pdEndpoints   []string
+	changefeedID  string
+	captureID     string
+	etcdCli       *clientv3.Client
+	schemaStorage *schema.MultiVersionStorage
+	detailMu      sync.Mutex
+	detail        model.ChangeFeedDetail
+	wg            sync.WaitGroup
+	closed        int32
 }
 
 // NewProcessorWatcher creates a new ProcessorWatcher instance
@@ -159,14 +187,16 @@ func NewProcessorWatcher(
 	captureID string,
 	pdEndpoints []string,
 	cli *clientv3.Client,
+	schemaStorage *schema.MultiVersionStorage,
 	detail model.ChangeFeedDetail,
 ) *ProcessorWatcher {
 	return &ProcessorWatcher{
-		changefeedID: changefeedID,
-		captureID:    captureID,
-		pdEndpoints:  pdEndpoints,
-		etcdCli:      cli,
-		detail:       detail,
+		changefeedID:  changefeedID,
+		captureID:     captureID,
+		pdEndpoints:   pdEndpoints,
+		etcdCli:       cli,
+		schemaStorage: schemaStorage,
+		detail:        detail,
 	}
 }
 
@@ -187,51 +217,205 @@ func (w *ProcessorWatcher) reopen() error {
 	return nil
 }
 
+func (w *ProcessorWatcher) updateDetail(detail model.ChangeFeedDetail) {
+	w.detailMu.Lock()
+	defer w.detailMu.Unlock()
+	w.detail = detail
+}
+
+func (w *ProcessorWatcher) getDetail() model.ChangeFeedDetail {
+	w.detailMu.Lock()
+	defer w.detailMu.Unlock()
+	return w.detail
+}
+
 // Watch wait for the key `/changefeed/subchangefeed/<fid>/cid>` appear and run the processor.
-func (w *ProcessorWatcher) Watch(ctx context.Context, errCh chan<- error, cb processorCallback) {
+// The processor is stopped when the key is removed, like when the changefeed
+// is stopped, and restarted with the latest detail when the key appears again.
+// The errors of the processor are reported in the subchangefeed info, and the
+// etcd errors are retried, so the other changefeeds on the capture aren't
+// affected. It returns when the ctx is done.
+func (w *ProcessorWatcher) Watch(ctx context.Context, cb processorCallback) {
 	defer w.wg.Done()
 	key := kv.GetEtcdKeySubChangeFeed(w.changefeedID, w.captureID)
 
+	for {
+		createRevision := w.waitKey(ctx, key)
+		if createRevision == 0 {
+			return
+		}
+
+		restart, err := w.runProcessor(ctx, key, createRevision, cb)
+		if err != nil {
+			// the error is handled by the owner, it stops the changefeed and
+			// resumes it later, other changefeeds on the capture aren't affected
+			log.Error("processor failed", zap.String("changefeed id", w.changefeedID), zap.Error(err))
+			if !w.reportError(ctx, key, createRevision, err) {
+				return
+			}
+			restart = w.waitKeyRemoved(ctx, key, createRevision)
+		}
+		if !restart {
+			return
+		}
+		log.Info("processor is stopped, wait for the changefeed to be resumed",
+			zap.String("changefeed id", w.changefeedID))
+	}
+}
+
+// reportError records the error of the processor in its subchangefeed info
+// created at createRevision, the owner stops the changefeed on it. It retries
+// until the error is recorded or the key is removed, and returns false if the
+// ctx is done.
+func (w *ProcessorWatcher) reportError(ctx context.Context, key string, createRevision int64, processorErr error) bool {
+	runningErr := model.NewRunningError(w.captureID, processorErr)
+	for {
+		reported, err := w.putError(ctx, key, createRevision, runningErr)
+		if err == nil && reported {
+			return true
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return false
+			}
+			log.Warn("report processor error failed, retry",
+				zap.String("changefeed id", w.changefeedID), zap.Error(err))
+		} else {
+			log.Info("subchangefeed info is updated when reporting the error, retry",
+				zap.String("changefeed id", w.changefeedID))
+		}
+		if !waitRetry(ctx) {
+			return false
+		}
+	}
+}
+
+// putError puts the error in the subchangefeed info created at createRevision,
+// it returns false if the info is updated at the same time.
+func (w *ProcessorWatcher) putError(ctx context.Context, key string, createRevision int64, runningErr *model.RunningError) (bool, error) {
+	resp, err := w.etcdCli.Get(ctx, key)
+	if err != nil {
+		return false, errors.Trace(err)
+	}
+	// the changefeed has been stopped
+	if resp.Count == 0 || resp.Kvs[0].CreateRevision != createRevision {
+		return true, nil
+	}
+	info := &model.SubChangeFeedInfo{}
+	if err := info.Unmarshal(resp.Kvs[0].Value); err != nil {
+		return false, errors.Trace(err)
+	}
+	info.Error = runningErr
+	value, err := info.Marshal()
+	if err != nil {
+		return false, errors.Trace(err)
+	}
+	txnResp, err := w.etcdCli.Txn(ctx).If(
+		clientv3.Compare(clientv3.ModRevision(key), "=", resp.Kvs[0].ModRevision),
+	).Then(
+		clientv3.OpPut(key, value),
+	).Commit()
+	if err != nil {
+		return false, errors.Trace(err)
+	}
+	return txnResp.Succeeded, nil
+}
+
+// waitRetry waits for a while before retrying, it returns false if the ctx is done.
+func waitRetry(ctx context.Context) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(time.Second):
+		return true
+	}
+}
+
+// waitKeyRemoved waits for the key created at createRevision to be removed, it
+// returns false if the ctx is done.
+func (w *ProcessorWatcher) waitKeyRemoved(ctx context.Context, key string, createRevision int64) bool {
+	for {
+		removed, err := w.keyRemoved(ctx, key, createRevision)
+		if err != nil {
+			if ctx.Err() != nil {
+				return false
+			}
+			log.Warn("check subchangefeed info failed, retry",
+				zap.String("changefeed id", w.changefeedID), zap.Error(err))
+		}
+		if removed {
+			return true
+		}
+		if !waitRetry(ctx) {
+			return false
+		}
+	}
+}
+
+// waitKey waits for the key to appear and returns its create revision, or 0
+// if the ctx is done. The etcd errors are retried.
+func (w *ProcessorWatcher) waitKey(ctx context.Context, key string) int64 {
+	for {
+		createRevision, err := w.watchKey(ctx, key)
+		if err == nil {
+			return createRevision
+		}
+		if ctx.Err() != nil {
+			return 0
+		}
+		log.Warn("watch subchangefeed info failed, retry",
+			zap.String("changefeed id", w.changefeedID), zap.Error(err))
+		if !waitRetry(ctx) {
+			return 0
+		}
+	}
+}
+
+func (w *ProcessorWatcher) watchKey(ctx context.Context, key string) (int64, error) {
 	getResp, err := w.etcdCli.Get(ctx, key)
 	if err != nil {
-		errCh <- errors.Trace(err)
-		return
+		return 0, errors.Trace(err)
+	}
+	if getResp.Count > 0 {
+		return getResp.Kvs[0].CreateRevision, nil
 	}
 	revision := getResp.Header.Revision
-	if getResp.Count == 0 {
-		// wait for key to appear
-		watchCh := w.etcdCli.Watch(ctx, key, clientv3.WithRev(revision))
-	waitKeyLoop:
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case resp, ok := <-watchCh:
-				if !ok {
-					log.Info("watcher is closed")
-					return
-				}
-				respErr := resp.Err()
-				if respErr != nil {
-					errCh <- errors.Trace(respErr)
-					return
+	watchCh := w.etcdCli.Watch(ctx, key, clientv3.WithRev(revision))
+	for {
+		select {
+		case <-ctx.Done():
+			return 0, nil
+		case resp, ok := <-watchCh:
+			if !ok {
+				if ctx.Err() != nil {
+					return 0, nil
 				}
-				for _, ev := range resp.Events {
-					switch ev.Type {
-					case mvccpb.PUT:
-						break waitKeyLoop
-					}
+				return 0, errors.New("watcher is closed")
+			}
+			respErr := resp.Err()
+			if respErr != nil {
+				return 0, errors.Trace(respErr)
+			}
+			for _, ev := range resp.Events {
+				switch ev.Type {
+				case mvccpb.PUT:
+					return ev.Kv.CreateRevision, nil
 				}
 			}
 		}
 	}
+}
 
+// runProcessor runs the processor until the key created at createRevision is
+// removed, it returns true if the processor should be restarted, or false if the
+// ctx is done. A processor that exits without an error while its key still exists
+// is reported as an error.
+func (w *ProcessorWatcher) runProcessor(ctx context.Context, key string, createRevision int64, cb processorCallback) (bool, error) {
 	cctx, cancel := context.WithCancel(ctx)
 	defer cancel()
-	feedErrCh, err := runProcessor(cctx, w.pdEndpoints, w.detail, w.changefeedID, w.captureID, cb)
+	feedErrCh, err := runProcessor(cctx, w.pdEndpoints, w.schemaStorage, w.getDetail(), w.changefeedID, w.captureID, cb)
 	if err != nil {
-		errCh <- err
-		return
+		return false, err
 	}
 
 	for {
@@ -239,26 +423,53 @@ func (w *ProcessorWatcher) Watch(ctx context.Context, errCh chan<- error, cb pro
 		case <-ctx.Done():
 			err := ctx.Err()
 			if err != context.Canceled {
-				errCh <- err
+				return false, err
 			}
-			return
+			return false, nil
 		case err := <-feedErrCh:
-			errCh <- err
-			return
+			if ctx.Err() == context.Canceled {
+				return false, nil
+			}
+			// the processor fails to update its info after the key is removed
+			if removed, getErr := w.keyRemoved(ctx, key, createRevision); getErr == nil && removed {
+				return true, nil
+			}
+			// the processor must not stop by itself while the key exists,
+			// report it so that the owner stops the changefeed
+			if err == nil {
+				err = errors.New("processor exited unexpectedly")
+			}
+			return false, err
 		case <-time.After(time.Second):
-			resp, err := w.etcdCli.Get(ctx, key)
+			removed, err := w.keyRemoved(ctx, key, createRevision)
 			if err != nil {
-				errCh <- errors.Trace(err)
-				return
+				if ctx.Err() != nil {
+					return false, nil
+				}
+				// the processor keeps running, check the key again later
+				log.Warn("check subchangefeed info failed",
+					zap.String("changefeed id", w.changefeedID), zap.Error(err))
+				continue
 			}
 			// processor has been removed from this capture
-			if resp.Count == 0 {
-				return
+			if removed {
+				cancel()
+				<-feedErrCh
+				return true, nil
 			}
 		}
 	}
 }
 
+// keyRemoved returns true if the key created at createRevision doesn't exist
+func (w *ProcessorWatcher) keyRemoved(ctx context.Context, key string, createRevision int64) (bool, error) {
+	resp, err := w.etcdCli.Get(ctx, key)
+	if err != nil {
+		return false, errors.Trace(err)
+	}
+	return resp.Count == 0 || resp.Kvs[0].CreateRevision != createRevision, nil
+}
+
 type processorCallback interface {
 	// OnRunProcessor is called when the processor is started.
 	OnRunProcessor(p *processor)
@@ -273,13 +484,13 @@ func realRunProcessorWatcher(
 	captureID string,
 	pdEndpoints []string,
 	etcdCli *clientv3.Client,
+	schemaStorage *schema.MultiVersionStorage,
 	detail model.ChangeFeedDetail,
-	errCh chan error,
 	cb processorCallback,
 ) *ProcessorWatcher {
-	sw := NewProcessorWatcher(changefeedID, captureID, pdEndpoints, etcdCli, detail)
+	sw := NewProcessorWatcher(changefeedID, captureID, pdEndpoints, etcdCli, schemaStorage, detail)
 	sw.wg.Add(1)
-	go sw.Watch(ctx, errCh, cb)
+	go sw.Watch(ctx, cb)
 	return sw
 }
 
@@ -287,12 +498,13 @@ func realRunProcessorWatcher(
 func realRunProcessor(
 	ctx context.Context,
 	pdEndpoints []string,
+	schemaStorage *schema.MultiVersionStorage,
 	detail model.ChangeFeedDetail,
 	changefeedID string,
 	captureID string,
 	cb processorCallback,
 ) (chan error, error) {
-	processor, err := NewProcessor(pdEndpoints, detail, changefeedID, captureID)
+	processor, err := NewProcessor(pdEndpoints, schemaStorage, detail, changefeedID, captureID)
 	if err != nil {
 		return nil, err
 	}
@@ -26,6 +26,7 @@ import (
 	"github.com/pingcap/errors"
 	"github.com/pingcap/ticdc/cdc/kv"
 	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/ticdc/cdc/schema"
 	"github.com/pingcap/ticdc/pkg/etcd"
 	"github.com/pingcap/ticdc/pkg/util"
 	"golang.org/x/sync/errgroup"
@@ -68,6 +69,7 @@ func (s *schedulerSuite) TearDownTest(c *check.C) {
 func mockRunProcessor(
 	ctx context.Context,
 	pdEndpoints []string,
+	schemaStorage *schema.MultiVersionStorage,
 	detail model.ChangeFeedDetail,
 	changefeedID string,
 	captureID string,
@@ -75,12 +77,17 @@ func mockRunProcessor(
 ) (chan error, error) {
 	errCh := make(chan error, 1)
 	atomic.AddInt32(&runProcessorCount, 1)
+	go func() {
+		<-ctx.Done()
+		errCh <- ctx.Err()
+	}()
 	return errCh, nil
 }
 
 func mockRunProcessorError(
 	ctx context.Context,
 	pdEndpoints []string,
+	schemaStorage *schema.MultiVersionStorage,
 	detail model.ChangeFeedDetail,
 	changefeedID string,
 	captureID string,
@@ -93,14 +100,28 @@ func mockRunProcessorError(
 	return errCh, nil
 }
 
+func mockRunProcessorExit(
+	ctx context.Context,
+	pdEndpoints []string,
+	schemaStorage *schema.MultiVersionStorage,
+	detail model.ChangeFeedDetail,
+	changefeedID string,
+	captureID string,
+	_ processorCallback,
+) (chan error, error) {
+	errCh := make(chan error, 1)
+	errCh <- nil
+	return errCh, nil
+}
+
 func mockRunProcessorWatcher(
 	tx context.Context,
 	changefeedID string,
 	captureID string,
 	pdEndpoints []string,
 	etcdCli *clientv3.Client,
+	schemaStorage *schema.MultiVersionStorage,
 	detail model.ChangeFeedDetail,
-	errCh chan error,
 	_ processorCallback,
 ) *ProcessorWatcher {
 	atomic.AddInt32(&runChangeFeedWatcherCount, 1)
@@ -135,37 +156,45 @@ func (s *schedulerSuite) TestProcessorWatcher(c *check.C) {
 	c.Assert(err, check.IsNil)
 
 	// subchangefeed exists before watch starts
-	errCh := make(chan error, 1)
-	sw := runProcessorWatcher(context.Background(), changefeedID, captureID, pdEndpoints, cli, detail, errCh, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	sw := runProcessorWatcher(ctx, changefeedID, captureID, pdEndpoints, cli, nil, detail, nil)
 	c.Assert(util.WaitSomething(10, time.Millisecond*50, func() bool {
 		return atomic.LoadInt32(&runProcessorCount) == 1
 	}), check.IsTrue)
 
-	// delete the subchangefeed
+	// delete the subchangefeed, the processor is restarted when it appears again
 	_, err = cli.Delete(context.Background(), key)
 	c.Assert(err, check.IsNil)
-	time.Sleep(time.Second)
+	time.Sleep(time.Second * 2)
+	c.Assert(atomic.LoadInt32(&runProcessorCount), check.Equals, int32(1))
+	_, err = cli.Put(context.Background(), key, "{}")
+	c.Assert(err, check.IsNil)
+	c.Assert(util.WaitSomething(10, time.Millisecond*50, func() bool {
+		return atomic.LoadInt32(&runProcessorCount) == 2
+	}), check.IsTrue)
+	cancel()
 	sw.close()
 	c.Assert(sw.isClosed(), check.IsTrue)
+	_, err = cli.Delete(context.Background(), key)
+	c.Assert(err, check.IsNil)
 
 	// check ProcessorWatcher watch subchangefeed key can ben canceled
 	err = sw.reopen()
 	c.Assert(err, check.IsNil)
 	c.Assert(sw.isClosed(), check.IsFalse)
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel = context.WithCancel(context.Background())
 	sw.wg.Add(1)
-	go sw.Watch(ctx, errCh, nil)
+	go sw.Watch(ctx, nil)
 	cancel()
 	sw.close()
 	c.Assert(sw.isClosed(), check.IsTrue)
 
 	// check watcher can find new subchangefeed in watch loop
-	errCh2 := make(chan error, 1)
-	runProcessorWatcher(context.Background(), changefeedID, captureID, pdEndpoints, cli, detail, errCh2, nil)
+	runProcessorWatcher(context.Background(), changefeedID, captureID, pdEndpoints, cli, nil, detail, nil)
 	_, err = cli.Put(context.Background(), key, "{}")
 	c.Assert(err, check.IsNil)
 	c.Assert(util.WaitSomething(10, time.Millisecond*50, func() bool {
-		return atomic.LoadInt32(&runProcessorCount) == 2
+		return atomic.LoadInt32(&runProcessorCount) == 3
 	}), check.IsTrue)
 }
 
@@ -196,11 +225,76 @@ func (s *schedulerSuite) TestProcessorWatcherError(c *check.C) {
 	_, err = cli.Put(context.Background(), key, "{}")
 	c.Assert(err, check.IsNil)
 
-	errCh := make(chan error, 1)
-	sw := runProcessorWatcher(context.Background(), changefeedID, captureID, pdEndpoints, cli, detail, errCh, nil)
+	// the error is reported in the subchangefeed info
+	ctx, cancel := context.WithCancel(context.Background())
+	sw := runProcessorWatcher(ctx, changefeedID, captureID, pdEndpoints, cli, nil, detail, nil)
+	readError := func() *model.RunningError {
+		_, info, err := kv.GetSubChangeFeedInfo(context.Background(), cli, changefeedID, captureID)
+		c.Assert(err, check.IsNil)
+		return info.Error
+	}
+	c.Assert(util.WaitSomething(10, time.Millisecond*50, func() bool {
+		return readError() != nil
+	}), check.IsTrue)
+	runningErr := readError()
+	c.Assert(runningErr.CaptureID, check.Equals, captureID)
+	c.Assert(runningErr.Message, check.Equals, "mock run error")
+
+	// the processor is restarted after the changefeed is stopped and resumed
+	_, err = cli.Delete(context.Background(), key)
+	c.Assert(err, check.IsNil)
+	_, err = cli.Put(context.Background(), key, "{}")
+	c.Assert(err, check.IsNil)
+	c.Assert(util.WaitSomething(40, time.Millisecond*50, func() bool {
+		return readError() != nil
+	}), check.IsTrue)
+
+	cancel()
+	sw.close()
+	c.Assert(sw.isClosed(), check.IsTrue)
+}
+
+func (s *schedulerSuite) TestProcessorWatcherUnexpectedExit(c *check.C) {
+	var (
+		changefeedID = "test-changefeed-exit"
+		captureID    = "test-capture-exit"
+		pdEndpoints  = []string{}
+		detail       = model.ChangeFeedDetail{}
+		key          = kv.GetEtcdKeySubChangeFeed(changefeedID, captureID)
+	)
+
+	oriRunProcessor := runProcessor
+	runProcessor = mockRunProcessorExit
+	defer func() {
+		runProcessor = oriRunProcessor
+	}()
+
+	curl := s.clientURL.String()
+	cli, err := clientv3.New(clientv3.Config{
+		Endpoints:   []string{curl},
+		DialTimeout: 3 * time.Second,
+	})
+	c.Assert(err, check.IsNil)
+	defer cli.Close()
+
+	_, err = cli.Put(context.Background(), key, "{}")
+	c.Assert(err, check.IsNil)
+
+	// the processor exits without an error, it is reported rather than
+	// stopping the watcher silently
+	ctx, cancel := context.WithCancel(context.Background())
+	sw := runProcessorWatcher(ctx, changefeedID, captureID, pdEndpoints, cli, nil, detail, nil)
+	readError := func() *model.RunningError {
+		_, info, err := kv.GetSubChangeFeedInfo(context.Background(), cli, changefeedID, captureID)
+		c.Assert(err, check.IsNil)
+		return info.Error
+	}
 	c.Assert(util.WaitSomething(10, time.Millisecond*50, func() bool {
-		return len(errCh) == 1
+		return readError() != nil
 	}), check.IsTrue)
+	c.Assert(readError().Message, check.Equals, "processor exited unexpectedly")
+
+	cancel()
 	sw.close()
 	c.Assert(sw.isClosed(), check.IsTrue)
 }
@@ -230,7 +324,7 @@ func (s *schedulerSuite) TestChangeFeedWatcher(c *check.C) {
 	defer cli.Close()
 
 	ctx, cancel := context.WithCancel(context.Background())
-	w := NewChangeFeedWatcher(captureID, pdEndpoints, cli)
+	w := NewChangeFeedWatcher(captureID, pdEndpoints, cli, nil)
 
 	var wg sync.WaitGroup
 	wg.Add(1)
@@ -0,0 +1,468 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schema
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"github.com/pingcap/parser/model"
+	"go.uber.org/zap"
+)
+
+// MultiVersionStorage stores the versions of the schemas and tables keyed by
+// the finished ts of the DDL jobs which change them, so that the schemas and
+// tables as of any ts can be queried. It's safe for concurrent use, and it's
+// shared by the processors of a capture.
+type MultiVersionStorage struct {
+	mu sync.RWMutex
+
+	schemas       map[int64][]schemaVersion
+	tables        map[int64][]tableVersion
+	tableNameToID map[TableName][]tableIDVersion
+
+	hasImplicitCol bool
+
+	// lastJobTs is the finished ts of the last DDL job handled
+	lastJobTs  uint64
+	resolvedTs uint64
+	// gcTs is the ts before which the versions are removed
+	gcTs uint64
+}
+
+type schemaVersion struct {
+	ts uint64
+	// info is nil if the schema is dropped
+	info *model.DBInfo
+}
+
+type tableVersion struct {
+	ts       uint64
+	schemaID int64
+	name     TableName
+	// info is nil if the table is dropped
+	info *model.TableInfo
+}
+
+type tableIDVersion struct {
+	ts uint64
+	// id is 0 if there is no table with the name
+	id int64
+}
+
+// NewMultiVersionStorage returns a MultiVersionStorage with the history DDL jobs handled
+func NewMultiVersionStorage(jobs []*model.Job, hasImplicitCol bool) (*MultiVersionStorage, error) {
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].BinlogInfo.FinishedTS < jobs[j].BinlogInfo.FinishedTS
+	})
+
+	s := &MultiVersionStorage{
+		schemas:        make(map[int64][]schemaVersion),
+		tables:         make(map[int64][]tableVersion),
+		tableNameToID:  make(map[TableName][]tableIDVersion),
+		hasImplicitCol: hasImplicitCol,
+	}
+	for _, job := range jobs {
+		if err := s.HandleDDLJob(job); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	return s, nil
+}
+
+// ResolvedTs returns the ts before which all the DDL jobs are handled, the
+// schemas and tables as of a greater ts may be changed later.
+func (s *MultiVersionStorage) ResolvedTs() uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.resolvedTs
+}
+
+// AdvanceResolvedTs advances the resolved ts if ts is greater than it
+func (s *MultiVersionStorage) AdvanceResolvedTs(ts uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if ts > s.resolvedTs {
+		s.resolvedTs = ts
+	}
+}
+
+// DoGC removes the versions of the schemas and tables which aren't visible as
+// of ts or later, the versions as of ts are kept, so the storage mustn't be
+// queried as of a ts before the GC ts.
+func (s *MultiVersionStorage) DoGC(ts uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if ts <= s.gcTs {
+		return
+	}
+	s.gcTs = ts
+
+	for id, versions := range s.schemas {
+		i := sort.Search(len(versions), func(i int) bool { return versions[i].ts > ts }) - 1
+		switch {
+		case i < 0:
+		case i == len(versions)-1 && versions[i].info == nil:
+			delete(s.schemas, id)
+		case i > 0:
+			s.schemas[id] = append([]schemaVersion(nil), versions[i:]...)
+		}
+	}
+	for id, versions := range s.tables {
+		i := sort.Search(len(versions), func(i int) bool { return versions[i].ts > ts }) - 1
+		switch {
+		case i < 0:
+		case i == len(versions)-1 && versions[i].info == nil:
+			delete(s.tables, id)
+		case i > 0:
+			s.tables[id] = append([]tableVersion(nil), versions[i:]...)
+		}
+	}
+	for name, versions := range s.tableNameToID {
+		i := sort.Search(len(versions), func(i int) bool { return versions[i].ts > ts }) - 1
+		switch {
+		case i < 0:
+		case i == len(versions)-1 && versions[i].id == 0:
+			delete(s.tableNameToID, name)
+		case i > 0:
+			s.tableNameToID[name] = append([]tableIDVersion(nil), versions[i:]...)
+		}
+	}
+	log.Debug("schema storage gc", zap.Uint64("ts", ts))
+}
+
+// HandleDDLJob adds the versions of the schemas and tables changed by the job,
+// the jobs must be handled in the order of their finished ts, the jobs finished
+// before the last handled one are ignored.
+func (s *MultiVersionStorage) HandleDDLJob(job *model.Job) error {
+	if skipJob(job) {
+		log.Debug("skip ddl job", zap.Stringer("job", job))
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ts := job.BinlogInfo.FinishedTS
+	if ts <= s.lastJobTs {
+		log.Debug("skip handled ddl job", zap.Stringer("job", job))
+		return nil
+	}
+	if err := s.handleDDLJob(job); err != nil {
+		return errors.Annotatef(err, "handle ddl job %v failed", job)
+	}
+	s.lastJobTs = ts
+	if ts > s.resolvedTs {
+		s.resolvedTs = ts
+	}
+	return nil
+}
+
+func (s *MultiVersionStorage) handleDDLJob(job *model.Job) error {
+	ts := job.BinlogInfo.FinishedTS
+	switch job.Type {
+	case model.ActionCreateSchema:
+		db := job.BinlogInfo.DBInfo
+		if s.latestSchema(db.ID) != nil {
+			return errors.AlreadyExistsf("schema %s(%d)", db.Name, db.ID)
+		}
+		s.putSchema(ts, db.ID, db)
+
+	case model.ActionModifySchemaCharsetAndCollate:
+		db := job.BinlogInfo.DBInfo
+		if s.latestSchema(db.ID) == nil {
+			return errors.NotFoundf("schema %s(%d)", db.Name, db.ID)
+		}
+		s.putSchema(ts, db.ID, db)
+
+	case model.ActionDropSchema:
+		if s.latestSchema(job.SchemaID) == nil {
+			return errors.NotFoundf("schema %d", job.SchemaID)
+		}
+		for id, versions := range s.tables {
+			latest := versions[len(versions)-1]
+			if latest.info != nil && latest.schemaID == job.SchemaID {
+				s.dropTable(ts, id)
+			}
+		}
+		s.putSchema(ts, job.SchemaID, nil)
+
+	case model.ActionRenameTable:
+		if s.latestTable(job.TableID) == nil {
+			return errors.NotFoundf("table %d", job.TableID)
+		}
+		db := s.latestSchema(job.SchemaID)
+		if db == nil {
+			return errors.NotFoundf("schema %d", job.SchemaID)
+		}
+		s.dropTable(ts, job.TableID)
+		s.createTable(ts, db, job.BinlogInfo.TableInfo)
+
+	case model.ActionCreateTable, model.ActionCreateView, model.ActionRecoverTable:
+		table := job.BinlogInfo.TableInfo
+		if table == nil {
+			return errors.NotFoundf("table %d", job.TableID)
+		}
+		db := s.latestSchema(job.SchemaID)
+		if db == nil {
+			return errors.NotFoundf("schema %d", job.SchemaID)
+		}
+		if s.latestTable(table.ID) != nil {
+			return errors.AlreadyExistsf("table %s.%s", db.Name, table.Name)
+		}
+		s.createTable(ts, db, table)
+
+	case model.ActionDropTable, model.ActionDropView:
+		if s.latestSchema(job.SchemaID) == nil {
+			return errors.NotFoundf("schema %d", job.SchemaID)
+		}
+		if s.latestTable(job.TableID) == nil {
+			return errors.NotFoundf("table %d", job.TableID)
+		}
+		s.dropTable(ts, job.TableID)
+
+	case model.ActionTruncateTable:
+		db := s.latestSchema(job.SchemaID)
+		if db == nil {
+			return errors.NotFoundf("schema %d", job.SchemaID)
+		}
+		// job.TableID is the old table id, different from table.ID
+		if s.latestTable(job.TableID) == nil {
+			return errors.NotFoundf("table %d", job.TableID)
+		}
+		table := job.BinlogInfo.TableInfo
+		if table == nil {
+			return errors.NotFoundf("table %d", job.TableID)
+		}
+		s.dropTable(ts, job.TableID)
+		s.createTable(ts, db, table)
+
+	default:
+		if job.BinlogInfo.TableInfo == nil {
+			return errors.NotFoundf("table %d", job.TableID)
+		}
+		table := job.BinlogInfo.TableInfo
+		if s.latestSchema(job.SchemaID) == nil {
+			return errors.NotFoundf("schema %d", job.SchemaID)
+		}
+		latest := s.latestTable(table.ID)
+		if latest == nil {
+			return errors.NotFoundf("table %s(%d)", table.Name, table.ID)
+		}
+		if s.hasImplicitCol && !table.PKIsHandle {
+			addImplicitColumn(table)
+		}
+		s.putTable(ts, table.ID, tableVersion{schemaID: latest.schemaID, name: latest.name, info: table})
+	}
+	return nil
+}
+
+func (s *MultiVersionStorage) latestSchema(id int64) *model.DBInfo {
+	versions := s.schemas[id]
+	if len(versions) == 0 {
+		return nil
+	}
+	return versions[len(versions)-1].info
+}
+
+func (s *MultiVersionStorage) latestTable(id int64) *tableVersion {
+	versions := s.tables[id]
+	if len(versions) == 0 || versions[len(versions)-1].info == nil {
+		return nil
+	}
+	return &versions[len(versions)-1]
+}
+
+// putSchema adds a version of the schema, the version added by the same job is replaced
+func (s *MultiVersionStorage) putSchema(ts uint64, id int64, info *model.DBInfo) {
+	versions := s.schemas[id]
+	if n := len(versions); n > 0 && versions[n-1].ts == ts {
+		versions = versions[:n-1]
+	}
+	s.schemas[id] = append(versions, schemaVersion{ts: ts, info: info})
+}
+
+// putTable adds a version of the table, the version added by the same job is replaced
+func (s *MultiVersionStorage) putTable(ts uint64, id int64, version tableVersion) {
+	version.ts = ts
+	versions := s.tables[id]
+	if n := len(versions); n > 0 && versions[n-1].ts == ts {
+		versions = versions[:n-1]
+	}
+	s.tables[id] = append(versions, version)
+}
+
+// putTableID adds a version of the table id with the name
+func (s *MultiVersionStorage) putTableID(ts uint64, name TableName, id int64) {
+	versions := s.tableNameToID[name]
+	if n := len(versions); n > 0 && versions[n-1].ts == ts {
+		versions = versions[:n-1]
+	}
+	s.tableNameToID[name] = append(versions, tableIDVersion{ts: ts, id: id})
+}
+
+func (s *MultiVersionStorage) createTable(ts uint64, db *model.DBInfo, table *model.TableInfo) {
+	if s.hasImplicitCol && !table.PKIsHandle {
+		addImplicitColumn(table)
+	}
+	name := TableName{Schema: db.Name.O, Table: table.Name.O}
+	s.putTable(ts, table.ID, tableVersion{schemaID: db.ID, name: name, info: table})
+	s.putTableID(ts, name, table.ID)
+}
+
+func (s *MultiVersionStorage) dropTable(ts uint64, id int64) {
+	latest := s.latestTable(id)
+	if latest == nil {
+		return
+	}
+	name := latest.name
+	s.putTable(ts, id, tableVersion{schemaID: latest.schemaID, name: name})
+	s.putTableID(ts, name, 0)
+}
+
+// tableAt returns the version of the table as of ts
+func (s *MultiVersionStorage) tableAt(id int64, ts uint64) (tableVersion, bool) {
+	versions := s.tables[id]
+	i := sort.Search(len(versions), func(i int) bool { return versions[i].ts > ts }) - 1
+	if i < 0 || versions[i].info == nil {
+		return tableVersion{}, false
+	}
+	return versions[i], true
+}
+
+// TableByID returns the TableInfo of the table as of ts
+func (s *MultiVersionStorage) TableByID(id int64, ts uint64) (*model.TableInfo, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	version, ok := s.tableAt(id, ts)
+	return version.info, ok
+}
+
+// SchemaAndTableName returns the schema name and table name of the table as of ts
+func (s *MultiVersionStorage) SchemaAndTableName(id int64, ts uint64) (string, string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	version, ok := s.tableAt(id, ts)
+	return version.name.Schema, version.name.Table, ok
+}
+
+// GetTableIDByName returns the id of the table with the name as of ts
+func (s *MultiVersionStorage) GetTableIDByName(schemaName string, tableName string, ts uint64) (int64, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	versions := s.tableNameToID[TableName{Schema: schemaName, Table: tableName}]
+	i := sort.Search(len(versions), func(i int) bool { return versions[i].ts > ts }) - 1
+	if i < 0 || versions[i].id == 0 {
+		return 0, false
+	}
+	return versions[i].id, true
+}
+
+// SchemaByID returns the DBInfo of the schema as of ts
+func (s *MultiVersionStorage) SchemaByID(id int64, ts uint64) (*model.DBInfo, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	versions := s.schemas[id]
+	i := sort.Search(len(versions), func(i int) bool { return versions[i].ts > ts }) - 1
+	if i < 0 || versions[i].info == nil {
+		return nil, false
+	}
+	return versions[i].info, true
+}
+
+// Snapshot returns a Storage with the schemas and tables as of ts, the DDL
+// jobs finished after ts can be applied to it by HandleDDL.
+func (s *MultiVersionStorage) Snapshot(ts uint64) (*Storage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if ts < s.gcTs {
+		return nil, errors.Errorf("snapshot ts %d is before the gc ts %d", ts, s.gcTs)
+	}
+	if ts > s.resolvedTs {
+		return nil, errors.Errorf("snapshot ts %d is after the resolved ts %d", ts, s.resolvedTs)
+	}
+
+	snap, err := NewStorage(nil, s.hasImplicitCol)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	for id, versions := range s.schemas {
+		i := sort.Search(len(versions), func(i int) bool { return versions[i].ts > ts }) - 1
+		if i < 0 || versions[i].info == nil {
+			continue
+		}
+		// the DBInfo is copied since the Storage changes its tables
+		db := *versions[i].info
+		db.Tables = nil
+		snap.schemas[id] = &db
+		snap.schemaNameToID[db.Name.O] = id
+	}
+	for id := range s.tables {
+		version, ok := s.tableAt(id, ts)
+		if !ok {
+			continue
+		}
+		db, ok := snap.schemas[version.schemaID]
+		if !ok {
+			return nil, errors.NotFoundf("schema %d", version.schemaID)
+		}
+		db.Tables = append(db.Tables, version.info)
+		snap.tables[id] = version.info
+		snap.tableIDToName[id] = version.name
+		snap.tableNameToID[version.name] = id
+	}
+	snap.lastHandledTs = ts
+	return snap, nil
+}
+
+// NewView returns a view of the storage as of ts
+func (s *MultiVersionStorage) NewView(ts uint64) *StorageView {
+	return &StorageView{storage: s, ts: ts}
+}
+
+// StorageView is a view of a MultiVersionStorage as of a ts, the ts is
+// advanced by its user as the changes are handled in the order of their
+// commit ts.
+type StorageView struct {
+	storage *MultiVersionStorage
+	ts      uint64
+}
+
+// Ts returns the ts of the view
+func (v *StorageView) Ts() uint64 {
+	return atomic.LoadUint64(&v.ts)
+}
+
+// SetTs sets the ts of the view, the DDL jobs finished at or before ts are
+// visible in the view.
+func (v *StorageView) SetTs(ts uint64) {
+	atomic.StoreUint64(&v.ts, ts)
+}
+
+// TableByID returns the TableInfo by table id
+func (v *StorageView) TableByID(id int64) (*model.TableInfo, bool) {
+	return v.storage.TableByID(id, v.Ts())
+}
+
+// SchemaAndTableName returns the schema name and table name by table id
+func (v *StorageView) SchemaAndTableName(id int64) (string, string, bool) {
+	return v.storage.SchemaAndTableName(id, v.Ts())
+}
+
+// GetTableIDByName returns the table id by schema name and table name
+func (v *StorageView) GetTableIDByName(schemaName string, tableName string) (int64, bool) {
+	return v.storage.GetTableIDByName(schemaName, tableName, v.Ts())
+}
@@ -0,0 +1,274 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schema
+
+import (
+	. "github.com/pingcap/check"
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/model"
+)
+
+type multiVersionSuite struct{}
+
+var _ = Suite(&multiVersionSuite{})
+
+func newTableJob(id int64, tp model.ActionType, schemaID int64, tableID int64, table *model.TableInfo, ts uint64) *model.Job {
+	return &model.Job{
+		ID:         id,
+		State:      model.JobStateSynced,
+		SchemaID:   schemaID,
+		TableID:    tableID,
+		Type:       tp,
+		BinlogInfo: &model.HistoryInfo{SchemaVersion: id, TableInfo: table, FinishedTS: ts},
+		Query:      "ddl",
+	}
+}
+
+func (s *multiVersionSuite) TestTableVersions(c *C) {
+	dbInfo := &model.DBInfo{ID: 1, Name: model.NewCIStr("test"), State: model.StatePublic}
+	t1 := &model.TableInfo{ID: 2, Name: model.NewCIStr("t1")}
+	t1AddColumn := &model.TableInfo{ID: 2, Name: model.NewCIStr("t1"), Columns: []*model.ColumnInfo{{ID: 1, Name: model.NewCIStr("a")}}}
+	t2 := &model.TableInfo{ID: 2, Name: model.NewCIStr("t2")}
+	t2Truncated := &model.TableInfo{ID: 3, Name: model.NewCIStr("t2")}
+
+	jobs := []*model.Job{
+		{
+			ID:         1,
+			State:      model.JobStateSynced,
+			SchemaID:   1,
+			Type:       model.ActionCreateSchema,
+			BinlogInfo: &model.HistoryInfo{SchemaVersion: 1, DBInfo: dbInfo, FinishedTS: 10},
+			Query:      "create database test",
+		},
+		newTableJob(2, model.ActionCreateTable, 1, 2, t1, 20),
+		newTableJob(3, model.ActionAddColumn, 1, 2, t1AddColumn, 30),
+		// the rollback job is skipped
+		{ID: 4, State: model.JobStateRollbackDone, BinlogInfo: &model.HistoryInfo{FinishedTS: 35}},
+	}
+	storage, err := NewMultiVersionStorage(jobs, false)
+	c.Assert(err, IsNil)
+	c.Assert(storage.ResolvedTs(), Equals, uint64(30))
+
+	c.Assert(storage.HandleDDLJob(newTableJob(5, model.ActionRenameTable, 1, 2, t2, 40)), IsNil)
+	c.Assert(storage.HandleDDLJob(newTableJob(6, model.ActionTruncateTable, 1, 2, t2Truncated, 50)), IsNil)
+	c.Assert(storage.HandleDDLJob(&model.Job{
+		ID:         7,
+		State:      model.JobStateSynced,
+		SchemaID:   1,
+		Type:       model.ActionDropSchema,
+		BinlogInfo: &model.HistoryInfo{SchemaVersion: 7, FinishedTS: 60},
+		Query:      "drop database test",
+	}), IsNil)
+	// the jobs finished before the last one are ignored
+	c.Assert(storage.HandleDDLJob(newTableJob(2, model.ActionCreateTable, 1, 2, t1, 20)), IsNil)
+	storage.AdvanceResolvedTs(70)
+	c.Assert(storage.ResolvedTs(), Equals, uint64(70))
+	storage.AdvanceResolvedTs(65)
+	c.Assert(storage.ResolvedTs(), Equals, uint64(70))
+
+	_, ok := storage.SchemaByID(1, 9)
+	c.Assert(ok, IsFalse)
+	db, ok := storage.SchemaByID(1, 10)
+	c.Assert(ok, IsTrue)
+	c.Assert(db, Equals, dbInfo)
+	_, ok = storage.SchemaByID(1, 60)
+	c.Assert(ok, IsFalse)
+
+	_, ok = storage.TableByID(2, 19)
+	c.Assert(ok, IsFalse)
+	table, ok := storage.TableByID(2, 29)
+	c.Assert(ok, IsTrue)
+	c.Assert(table, Equals, t1)
+	table, ok = storage.TableByID(2, 30)
+	c.Assert(ok, IsTrue)
+	c.Assert(table, Equals, t1AddColumn)
+	_, ok = storage.TableByID(2, 50)
+	c.Assert(ok, IsFalse)
+	table, ok = storage.TableByID(3, 50)
+	c.Assert(ok, IsTrue)
+	c.Assert(table, Equals, t2Truncated)
+	_, ok = storage.TableByID(3, 60)
+	c.Assert(ok, IsFalse)
+
+	schemaName, tableName, ok := storage.SchemaAndTableName(2, 39)
+	c.Assert(ok, IsTrue)
+	c.Assert(TableName{Schema: schemaName, Table: tableName}, Equals, TableName{Schema: "test", Table: "t1"})
+	schemaName, tableName, ok = storage.SchemaAndTableName(2, 40)
+	c.Assert(ok, IsTrue)
+	c.Assert(TableName{Schema: schemaName, Table: tableName}, Equals, TableName{Schema: "test", Table: "t2"})
+
+	id, ok := storage.GetTableIDByName("test", "t1", 30)
+	c.Assert(ok, IsTrue)
+	c.Assert(id, Equals, int64(2))
+	_, ok = storage.GetTableIDByName("test", "t1", 40)
+	c.Assert(ok, IsFalse)
+	id, ok = storage.GetTableIDByName("test", "t2", 40)
+	c.Assert(ok, IsTrue)
+	c.Assert(id, Equals, int64(2))
+	id, ok = storage.GetTableIDByName("test", "t2", 55)
+	c.Assert(ok, IsTrue)
+	c.Assert(id, Equals, int64(3))
+	_, ok = storage.GetTableIDByName("test", "t2", 60)
+	c.Assert(ok, IsFalse)
+
+	// the views of the storage advance independently
+	view1, view2 := storage.NewView(25), storage.NewView(45)
+	table, ok = view1.TableByID(2)
+	c.Assert(ok, IsTrue)
+	c.Assert(table, Equals, t1)
+	id, ok = view2.GetTableIDByName("test", "t2")
+	c.Assert(ok, IsTrue)
+	c.Assert(id, Equals, int64(2))
+	view1.SetTs(50)
+	c.Assert(view1.Ts(), Equals, uint64(50))
+	_, _, ok = view1.SchemaAndTableName(2)
+	c.Assert(ok, IsFalse)
+	_, _, ok = view2.SchemaAndTableName(2)
+	c.Assert(ok, IsTrue)
+}
+
+func (s *multiVersionSuite) TestHandleDDLJobError(c *C) {
+	storage, err := NewMultiVersionStorage(nil, false)
+	c.Assert(err, IsNil)
+	table := &model.TableInfo{ID: 2, Name: model.NewCIStr("t1")}
+	err = storage.HandleDDLJob(newTableJob(1, model.ActionCreateTable, 1, 2, table, 10))
+	c.Assert(errors.IsNotFound(err), IsTrue)
+	err = storage.HandleDDLJob(newTableJob(2, model.ActionDropTable, 1, 2, nil, 20))
+	c.Assert(errors.IsNotFound(err), IsTrue)
+
+	dbInfo := &model.DBInfo{ID: 1, Name: model.NewCIStr("test"), State: model.StatePublic}
+	createSchema := &model.Job{
+		ID:         3,
+		State:      model.JobStateSynced,
+		SchemaID:   1,
+		Type:       model.ActionCreateSchema,
+		BinlogInfo: &model.HistoryInfo{SchemaVersion: 3, DBInfo: dbInfo, FinishedTS: 30},
+		Query:      "create database test",
+	}
+	c.Assert(storage.HandleDDLJob(createSchema), IsNil)
+	c.Assert(storage.HandleDDLJob(newTableJob(4, model.ActionCreateTable, 1, 2, table, 40)), IsNil)
+	err = storage.HandleDDLJob(newTableJob(5, model.ActionCreateTable, 1, 2, table, 50))
+	c.Assert(errors.IsAlreadyExists(err), IsTrue)
+}
+
+func (s *multiVersionSuite) TestDoGC(c *C) {
+	dbInfo := &model.DBInfo{ID: 1, Name: model.NewCIStr("test"), State: model.StatePublic}
+	t1 := &model.TableInfo{ID: 2, Name: model.NewCIStr("t1")}
+	t1AddColumn := &model.TableInfo{ID: 2, Name: model.NewCIStr("t1"), Columns: []*model.ColumnInfo{{ID: 1, Name: model.NewCIStr("a")}}}
+	t2 := &model.TableInfo{ID: 3, Name: model.NewCIStr("t2")}
+
+	jobs := []*model.Job{
+		{
+			ID:         1,
+			State:      model.JobStateSynced,
+			SchemaID:   1,
+			Type:       model.ActionCreateSchema,
+			BinlogInfo: &model.HistoryInfo{SchemaVersion: 1, DBInfo: dbInfo, FinishedTS: 10},
+			Query:      "create database test",
+		},
+		newTableJob(2, model.ActionCreateTable, 1, 2, t1, 20),
+		newTableJob(3, model.ActionCreateTable, 1, 3, t2, 25),
+		newTableJob(4, model.ActionAddColumn, 1, 2, t1AddColumn, 30),
+		newTableJob(5, model.ActionDropTable, 1, 3, nil, 35),
+		newTableJob(6, model.ActionDropTable, 1, 2, nil, 50),
+	}
+	storage, err := NewMultiVersionStorage(jobs, false)
+	c.Assert(err, IsNil)
+
+	storage.DoGC(40)
+	// the versions as of the gc ts are kept
+	table, ok := storage.TableByID(2, 40)
+	c.Assert(ok, IsTrue)
+	c.Assert(table, Equals, t1AddColumn)
+	_, ok = storage.TableByID(2, 50)
+	c.Assert(ok, IsFalse)
+	// the older versions are removed
+	_, ok = storage.TableByID(2, 25)
+	c.Assert(ok, IsFalse)
+	c.Assert(storage.tables[2], HasLen, 2)
+	// the dropped table is removed with its name
+	c.Assert(storage.tables, Not(HasKey), int64(3))
+	c.Assert(storage.tableNameToID, Not(HasKey), TableName{Schema: "test", Table: "t2"})
+	id, ok := storage.GetTableIDByName("test", "t1", 45)
+	c.Assert(ok, IsTrue)
+	c.Assert(id, Equals, int64(2))
+	db, ok := storage.SchemaByID(1, 40)
+	c.Assert(ok, IsTrue)
+	c.Assert(db, Equals, dbInfo)
+
+	// the gc ts doesn't go back
+	storage.DoGC(30)
+	storage.DoGC(60)
+	c.Assert(storage.tables, HasLen, 0)
+	c.Assert(storage.tableNameToID, HasLen, 0)
+	c.Assert(storage.schemas, HasLen, 1)
+	c.Assert(storage.HandleDDLJob(newTableJob(7, model.ActionCreateTable, 1, 2, t1, 70)), IsNil)
+	table, ok = storage.TableByID(2, 70)
+	c.Assert(ok, IsTrue)
+	c.Assert(table, Equals, t1)
+}
+
+func (s *multiVersionSuite) TestSnapshot(c *C) {
+	dbInfo := &model.DBInfo{ID: 1, Name: model.NewCIStr("test"), State: model.StatePublic}
+	t1 := &model.TableInfo{ID: 2, Name: model.NewCIStr("t1")}
+	t2 := &model.TableInfo{ID: 3, Name: model.NewCIStr("t2")}
+	t3 := &model.TableInfo{ID: 4, Name: model.NewCIStr("t3")}
+
+	jobs := []*model.Job{
+		{
+			ID:         1,
+			State:      model.JobStateSynced,
+			SchemaID:   1,
+			Type:       model.ActionCreateSchema,
+			BinlogInfo: &model.HistoryInfo{SchemaVersion: 1, DBInfo: dbInfo, FinishedTS: 10},
+			Query:      "create database test",
+		},
+		newTableJob(2, model.ActionCreateTable, 1, 2, t1, 20),
+		newTableJob(3, model.ActionCreateTable, 1, 3, t2, 25),
+		newTableJob(4, model.ActionDropTable, 1, 2, nil, 30),
+	}
+	storage, err := NewMultiVersionStorage(jobs, false)
+	c.Assert(err, IsNil)
+
+	snap, err := storage.Snapshot(25)
+	c.Assert(err, IsNil)
+	c.Assert(snap.CloneTables(), DeepEquals, map[uint64]TableName{
+		2: {Schema: "test", Table: "t1"},
+		3: {Schema: "test", Table: "t2"},
+	})
+	db, ok := snap.SchemaByTableID(3)
+	c.Assert(ok, IsTrue)
+	c.Assert(db.Tables, HasLen, 2)
+
+	// the later jobs are applied to the snapshot
+	c.Assert(snap.HandlePreviousDDLJobIfNeed(25), IsNil)
+	_, _, _, err = snap.HandleDDL(jobs[3])
+	c.Assert(err, IsNil)
+	_, _, _, err = snap.HandleDDL(newTableJob(5, model.ActionCreateTable, 1, 4, t3, 40))
+	c.Assert(err, IsNil)
+	c.Assert(snap.CloneTables(), DeepEquals, map[uint64]TableName{
+		3: {Schema: "test", Table: "t2"},
+		4: {Schema: "test", Table: "t3"},
+	})
+	// the shared storage isn't changed by the snapshot
+	_, ok = storage.TableByID(4, 40)
+	c.Assert(ok, IsFalse)
+	c.Assert(dbInfo.Tables, HasLen, 0)
+
+	_, err = storage.Snapshot(40)
+	c.Assert(err, ErrorMatches, ".*after the resolved ts.*")
+	storage.DoGC(30)
+	_, err = storage.Snapshot(25)
+	c.Assert(err, ErrorMatches, ".*before the gc ts.*")
+}
@@ -15,6 +15,7 @@ package cdc
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"strings"
 
@@ -28,6 +29,8 @@ type options struct {
 	pdEndpoints string
 	statusHost  string
 	statusPort  int
+	// advertiseAddr is the status address advertised to other captures
+	advertiseAddr string
 }
 
 var defaultServerOptions = options{
@@ -57,6 +60,14 @@ func StatusPort(p int) ServerOption {
 	}
 }
 
+// AdvertiseAddr returns a ServerOption that sets the status server address
+// advertised to other captures, the status host and port are used if it's empty.
+func AdvertiseAddr(s string) ServerOption {
+	return func(o *options) {
+		o.advertiseAddr = s
+	}
+}
+
 // A ServerOption sets options such as the addr of PD.
 type ServerOption func(*options)
 
@@ -78,7 +89,11 @@ func NewServer(opt ...ServerOption) (*Server, error) {
 		zap.String("status-host", opts.statusHost),
 		zap.Int("status-port", opts.statusPort))
 
-	capture, err := NewCapture(strings.Split(opts.pdEndpoints, ","))
+	advertiseAddr := opts.advertiseAddr
+	if advertiseAddr == "" {
+		advertiseAddr = fmt.Sprintf("%s:%d", opts.statusHost, opts.statusPort)
+	}
+	capture, err := NewCapture(strings.Split(opts.pdEndpoints, ","), advertiseAddr)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,530 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codec
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/linkedin/goavro/v2"
+	"github.com/pingcap/errors"
+	timodel "github.com/pingcap/parser/model"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/ticdc/cdc/model"
+)
+
+// The Avro protocol encodes every message in the wire format of the Confluent
+// schema registry: a zero magic byte, the 4 bytes big endian schema id and the
+// Avro binary encoded record.
+//
+// The value of a row event is a record named after the table, with a nullable
+// field for every column and two extra fields `_tidb_op` and `_tidb_commit_ts`.
+// The key of a row event is a record of the handle columns, it's nil if the
+// table has no handle. Every field carries the original column name, the MySQL
+// type and flags as custom attributes so the record can be decoded into columns.
+//
+// DDL and resolved events use the fixed records `ticdc.DDL` and `ticdc.Resolved`
+// and have no key.
+
+const (
+	avroMagicByte     = 0
+	avroHeaderSize    = 5
+	avroOpField       = "_tidb_op"
+	avroCommitTsField = "_tidb_commit_ts"
+
+	avroDDLSubject      = "ticdc-ddl"
+	avroResolvedSubject = "ticdc-resolved"
+	avroDDLName         = "ticdc.DDL"
+	avroResolvedName    = "ticdc.Resolved"
+)
+
+const avroDDLSchema = `{"type":"record","name":"DDL","namespace":"ticdc","fields":[` +
+	`{"name":"commit_ts","type":"long"},{"name":"schema","type":"string"},` +
+	`{"name":"table","type":"string"},{"name":"query","type":"string"},{"name":"type","type":"int"}]}`
+
+const avroResolvedSchema = `{"type":"record","name":"Resolved","namespace":"ticdc","fields":[` +
+	`{"name":"commit_ts","type":"long"}]}`
+
+type avroField struct {
+	Name     string      `json:"name"`
+	Type     interface{} `json:"type"`
+	Default  interface{} `json:"default"`
+	Column   string      `json:"tidbColumn,omitempty"`
+	TiDBType byte        `json:"tidbType"`
+	Unsigned bool        `json:"tidbUnsigned,omitempty"`
+	Handle   bool        `json:"tidbHandle,omitempty"`
+}
+
+type avroRecord struct {
+	Type      string       `json:"type"`
+	Name      string       `json:"name"`
+	Namespace string       `json:"namespace,omitempty"`
+	Schema    string       `json:"tidbSchema,omitempty"`
+	Table     string       `json:"tidbTable,omitempty"`
+	Fields    []*avroField `json:"fields"`
+}
+
+// avroSchema is a registered schema
+type avroSchema struct {
+	id     int
+	codec  *goavro.Codec
+	record *avroRecord
+}
+
+type avroTableSchemas struct {
+	updateTS uint64
+	key      *avroSchema
+	value    *avroSchema
+}
+
+// AvroEncoder encodes events with the Avro protocol, schemas are derived from
+// the table definitions and registered when a table is seen for the first time
+// or its definition is changed by a DDL.
+type AvroEncoder struct {
+	registry SchemaRegistry
+
+	mu       sync.Mutex
+	tables   map[int64]*avroTableSchemas
+	ddl      *avroSchema
+	resolved *avroSchema
+}
+
+var _ Encoder = &AvroEncoder{}
+
+// NewAvroEncoder creates a new AvroEncoder.
+func NewAvroEncoder(registry SchemaRegistry) *AvroEncoder {
+	return &AvroEncoder{
+		registry: registry,
+		tables:   make(map[int64]*avroTableSchemas),
+	}
+}
+
+// EncodeRow implements the Encoder interface
+func (e *AvroEncoder) EncodeRow(ts uint64, dml *model.DML, table *timodel.TableInfo) (*Message, error) {
+	if table == nil {
+		return nil, errors.Errorf("no table definition found for %s.%s", dml.Database, dml.Table)
+	}
+	op, ok := dmlTypeToOp[dml.Tp]
+	if !ok {
+		return nil, errors.Errorf("unknown dml type %d", dml.Tp)
+	}
+	schemas, err := e.tableSchemas(dml.Database, table)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	cols, err := rowColumns(dml.Values, table)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	native, err := avroNative(schemas.value.record, cols)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	native[avroOpField] = op
+	native[avroCommitTsField] = int64(ts)
+	value, err := avroEncode(schemas.value, native)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	msg := &Message{Value: value}
+	if schemas.key != nil {
+		native, err := avroNative(schemas.key.record, cols)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		if msg.Key, err = avroEncode(schemas.key, native); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	return msg, nil
+}
+
+// EncodeDDL implements the Encoder interface
+func (e *AvroEncoder) EncodeDDL(ts uint64, ddl *model.DDL) (*Message, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.ddl == nil {
+		s, err := e.register(avroDDLSubject, avroDDLSchema, nil)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		e.ddl = s
+	}
+	value, err := avroEncode(e.ddl, map[string]interface{}{
+		"commit_ts": int64(ts),
+		"schema":    ddl.Database,
+		"table":     ddl.Table,
+		"query":     ddl.Job.Query,
+		"type":      int32(ddl.Job.Type),
+	})
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return &Message{Value: value}, nil
+}
+
+// EncodeResolvedTs implements the Encoder interface
+func (e *AvroEncoder) EncodeResolvedTs(ts uint64) (*Message, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.resolved == nil {
+		s, err := e.register(avroResolvedSubject, avroResolvedSchema, nil)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		e.resolved = s
+	}
+	value, err := avroEncode(e.resolved, map[string]interface{}{"commit_ts": int64(ts)})
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return &Message{Value: value}, nil
+}
+
+// tableSchemas returns the registered schemas of the table, the schemas are
+// derived again if the table has been changed since they were registered.
+func (e *AvroEncoder) tableSchemas(schemaName string, table *timodel.TableInfo) (*avroTableSchemas, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if s, ok := e.tables[table.ID]; ok && s.updateTS == table.UpdateTS {
+		return s, nil
+	}
+	value, key := tableAvroRecords(schemaName, table)
+	subject := schemaName + "." + table.Name.O
+	schemas := &avroTableSchemas{updateTS: table.UpdateTS}
+	var err error
+	if schemas.value, err = e.registerRecord(subject+"-value", value); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if key != nil {
+		if schemas.key, err = e.registerRecord(subject+"-key", key); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	e.tables[table.ID] = schemas
+	return schemas, nil
+}
+
+func (e *AvroEncoder) registerRecord(subject string, record *avroRecord) (*avroSchema, error) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return e.register(subject, string(data), record)
+}
+
+func (e *AvroEncoder) register(subject string, schema string, record *avroRecord) (*avroSchema, error) {
+	codec, err := goavro.NewCodec(schema)
+	if err != nil {
+		return nil, errors.Annotatef(err, "invalid avro schema %s", schema)
+	}
+	id, err := e.registry.Register(context.Background(), subject, schema)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return &avroSchema{id: id, codec: codec, record: record}, nil
+}
+
+// tableAvroRecords derives the value and key records of a table.
+func tableAvroRecords(schemaName string, table *timodel.TableInfo) (value *avroRecord, key *avroRecord) {
+	handles := handleColumns(table)
+	value = &avroRecord{
+		Type:      "record",
+		Name:      avroName(table.Name.O),
+		Namespace: avroName(schemaName),
+		Schema:    schemaName,
+		Table:     table.Name.O,
+	}
+	var keyFields []*avroField
+	for _, col := range table.Columns {
+		if col.State != timodel.StatePublic {
+			continue
+		}
+		field := &avroField{
+			Name:     avroName(col.Name.O),
+			Type:     []string{"null", avroType(col)},
+			Column:   col.Name.O,
+			TiDBType: col.Tp,
+			Unsigned: mysql.HasUnsignedFlag(col.Flag),
+		}
+		if _, ok := handles[col.Name.O]; ok {
+			field.Handle = true
+			keyFields = append(keyFields, field)
+		}
+		value.Fields = append(value.Fields, field)
+	}
+	value.Fields = append(value.Fields,
+		&avroField{Name: avroOpField, Type: "string", Default: ""},
+		&avroField{Name: avroCommitTsField, Type: "long", Default: 0},
+	)
+	if len(keyFields) > 0 {
+		key = &avroRecord{
+			Type:      "record",
+			Name:      value.Name + "_key",
+			Namespace: value.Namespace,
+			Schema:    schemaName,
+			Table:     table.Name.O,
+			Fields:    keyFields,
+		}
+	}
+	return value, key
+}
+
+func avroType(col *timodel.ColumnInfo) string {
+	switch col.Tp {
+	case mysql.TypeTiny, mysql.TypeShort, mysql.TypeInt24, mysql.TypeLong, mysql.TypeYear,
+		mysql.TypeEnum, mysql.TypeSet, mysql.TypeBit:
+		return "long"
+	case mysql.TypeLonglong:
+		if mysql.HasUnsignedFlag(col.Flag) {
+			// unsigned bigint may overflow a long
+			return "string"
+		}
+		return "long"
+	case mysql.TypeFloat:
+		return "float"
+	case mysql.TypeDouble:
+		return "double"
+	}
+	if isBinary(&col.FieldType) {
+		return "bytes"
+	}
+	return "string"
+}
+
+// avroName converts a MySQL identifier to a valid Avro name.
+func avroName(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		switch {
+		case r == '_', r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+		case r >= '0' && r <= '9':
+			if i == 0 {
+				b.WriteByte('_')
+			}
+		default:
+			r = '_'
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func avroNative(record *avroRecord, cols map[string]*Column) (map[string]interface{}, error) {
+	native := make(map[string]interface{}, len(record.Fields))
+	for _, field := range record.Fields {
+		if field.Column == "" {
+			continue
+		}
+		col, ok := cols[field.Column]
+		if !ok || col.Value == nil {
+			native[field.Name] = nil
+			continue
+		}
+		tp := field.Type.([]string)[1]
+		var v interface{}
+		switch val := col.Value.(type) {
+		case int64:
+			v = val
+		case uint64:
+			if tp == "string" {
+				v = strconv.FormatUint(val, 10)
+			} else {
+				v = int64(val)
+			}
+		case float64:
+			if tp == "float" {
+				v = float32(val)
+			} else {
+				v = val
+			}
+		case string:
+			if tp == "bytes" {
+				v = []byte(val)
+			} else {
+				v = val
+			}
+		case []byte:
+			if tp == "bytes" {
+				v = val
+			} else {
+				v = string(val)
+			}
+		default:
+			return nil, errors.Errorf("unexpected value %v of column %s", val, field.Column)
+		}
+		native[field.Name] = goavro.Union(tp, v)
+	}
+	return native, nil
+}
+
+func avroEncode(schema *avroSchema, native map[string]interface{}) ([]byte, error) {
+	buf := make([]byte, avroHeaderSize, 64)
+	buf[0] = avroMagicByte
+	binary.BigEndian.PutUint32(buf[1:], uint32(schema.id))
+	buf, err := schema.codec.BinaryFromNative(buf, native)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return buf, nil
+}
+
+// AvroDecoder decodes messages encoded by AvroEncoder.
+type AvroDecoder struct {
+	registry SchemaRegistry
+
+	mu      sync.Mutex
+	schemas map[int]*avroSchema
+}
+
+var _ Decoder = &AvroDecoder{}
+
+// NewAvroDecoder creates a new AvroDecoder.
+func NewAvroDecoder(registry SchemaRegistry) *AvroDecoder {
+	return &AvroDecoder{
+		registry: registry,
+		schemas:  make(map[int]*avroSchema),
+	}
+}
+
+// Decode implements the Decoder interface
+func (d *AvroDecoder) Decode(msg *Message) (*Event, error) {
+	schema, native, err := d.decode(msg.Value)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	switch schema.record.Namespace + "." + schema.record.Name {
+	case avroDDLName:
+		return &Event{
+			Type:   EventTypeDDL,
+			Ts:     uint64(native["commit_ts"].(int64)),
+			Schema: native["schema"].(string),
+			Table:  native["table"].(string),
+			DDL: &DDLEvent{
+				Query: native["query"].(string),
+				Type:  timodel.ActionType(native["type"].(int32)),
+			},
+		}, nil
+	case avroResolvedName:
+		return &Event{
+			Type: EventTypeResolved,
+			Ts:   uint64(native["commit_ts"].(int64)),
+		}, nil
+	}
+
+	tp, ok := opToDMLType[native[avroOpField].(string)]
+	if !ok {
+		return nil, errors.Errorf("unknown row op %v", native[avroOpField])
+	}
+	row := &RowEvent{Tp: tp, Columns: make(map[string]*Column, len(schema.record.Fields))}
+	for _, field := range schema.record.Fields {
+		if field.Column == "" {
+			continue
+		}
+		col := &Column{
+			Type:        field.TiDBType,
+			Unsigned:    field.Unsigned,
+			WhereHandle: field.Handle,
+		}
+		if v, ok := native[field.Name].(map[string]interface{}); ok {
+			for avroType, val := range v {
+				col.Binary = avroType == "bytes"
+				if col.Value, err = fromAvroValue(field, val); err != nil {
+					return nil, errors.Annotatef(err, "column %s", field.Column)
+				}
+			}
+		}
+		row.Columns[field.Column] = col
+	}
+	return &Event{
+		Type:   EventTypeRow,
+		Ts:     uint64(native[avroCommitTsField].(int64)),
+		Schema: schema.record.Schema,
+		Table:  schema.record.Table,
+		Row:    row,
+	}, nil
+}
+
+func fromAvroValue(field *avroField, val interface{}) (interface{}, error) {
+	switch v := val.(type) {
+	case int64:
+		switch field.TiDBType {
+		case mysql.TypeEnum, mysql.TypeSet, mysql.TypeBit:
+			return uint64(v), nil
+		}
+		if field.Unsigned {
+			return uint64(v), nil
+		}
+		return v, nil
+	case string:
+		if field.TiDBType == mysql.TypeLonglong && field.Unsigned {
+			return strconv.ParseUint(v, 10, 64)
+		}
+		return v, nil
+	case float32:
+		return float64(v), nil
+	case float64, []byte:
+		return v, nil
+	default:
+		return nil, errors.Errorf("unexpected value %v", v)
+	}
+}
+
+func (d *AvroDecoder) decode(data []byte) (*avroSchema, map[string]interface{}, error) {
+	if len(data) < avroHeaderSize || data[0] != avroMagicByte {
+		return nil, nil, errors.New("invalid avro message header")
+	}
+	id := int(binary.BigEndian.Uint32(data[1:avroHeaderSize]))
+	schema, err := d.lookup(id)
+	if err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+	native, _, err := schema.codec.NativeFromBinary(data[avroHeaderSize:])
+	if err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+	record, ok := native.(map[string]interface{})
+	if !ok {
+		return nil, nil, errors.Errorf("unexpected avro value %v", native)
+	}
+	return schema, record, nil
+}
+
+func (d *AvroDecoder) lookup(id int) (*avroSchema, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if s, ok := d.schemas[id]; ok {
+		return s, nil
+	}
+	schema, err := d.registry.Lookup(context.Background(), id)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	codec, err := goavro.NewCodec(schema)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	record := &avroRecord{}
+	if err := json.Unmarshal([]byte(schema), record); err != nil {
+		return nil, errors.Trace(err)
+	}
+	s := &avroSchema{id: id, codec: codec, record: record}
+	d.schemas[id] = s
+	return s, nil
+}
@@ -0,0 +1,189 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codec
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/pingcap/check"
+	timodel "github.com/pingcap/parser/model"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/tidb/types"
+)
+
+type avroSuite struct{}
+
+var _ = check.Suite(&avroSuite{})
+
+func (s avroSuite) TestMemorySchemaRegistry(c *check.C) {
+	ctx := context.Background()
+	r := NewMemorySchemaRegistry()
+	id1, err := r.Register(ctx, "a", `"long"`)
+	c.Assert(err, check.IsNil)
+	id2, err := r.Register(ctx, "a", `"string"`)
+	c.Assert(err, check.IsNil)
+	c.Assert(id2, check.Not(check.Equals), id1)
+	id, err := r.Register(ctx, "b", `"long"`)
+	c.Assert(err, check.IsNil)
+	c.Assert(id, check.Equals, id1)
+	id, err = r.Register(ctx, "a", `"long"`)
+	c.Assert(err, check.IsNil)
+	c.Assert(id, check.Equals, id1)
+	c.Assert(r.Versions("a"), check.Equals, 2)
+	c.Assert(r.Versions("b"), check.Equals, 1)
+
+	schema, err := r.Lookup(ctx, id2)
+	c.Assert(err, check.IsNil)
+	c.Assert(schema, check.Equals, `"string"`)
+	_, err = r.Lookup(ctx, 100)
+	c.Assert(err, check.NotNil)
+}
+
+func (s avroSuite) TestConfluentSchemaRegistry(c *check.C) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/subjects/test.t1-value/versions", func(w http.ResponseWriter, r *http.Request) {
+		c.Assert(r.Method, check.Equals, http.MethodPost)
+		var req map[string]string
+		c.Assert(json.NewDecoder(r.Body).Decode(&req), check.IsNil)
+		c.Assert(req["schema"], check.Equals, `"long"`)
+		w.Write([]byte(`{"id":7}`))
+	})
+	mux.HandleFunc("/schemas/ids/7", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"schema":"\"long\""}`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	ctx := context.Background()
+	r, err := NewConfluentSchemaRegistry(server.URL + "/")
+	c.Assert(err, check.IsNil)
+	id, err := r.Register(ctx, "test.t1-value", `"long"`)
+	c.Assert(err, check.IsNil)
+	c.Assert(id, check.Equals, 7)
+	schema, err := r.Lookup(ctx, 7)
+	c.Assert(err, check.IsNil)
+	c.Assert(schema, check.Equals, `"long"`)
+	_, err = r.Lookup(ctx, 8)
+	c.Assert(err, check.ErrorMatches, "(?s).*404.*")
+}
+
+func (s avroSuite) TestAvroName(c *check.C) {
+	c.Assert(avroName("t1"), check.Equals, "t1")
+	c.Assert(avroName("1t"), check.Equals, "_1t")
+	c.Assert(avroName("a-b.c"), check.Equals, "a_b_c")
+}
+
+func (s avroSuite) TestRowRoundTrip(c *check.C) {
+	registry := NewMemorySchemaRegistry()
+	enc := NewAvroEncoder(registry)
+	dec := NewAvroDecoder(registry)
+
+	table := testTableInfo()
+	table.ID = 10
+	table.UpdateTS = 1
+	dml := &model.DML{
+		Database: "test",
+		Table:    "t1",
+		Tp:       model.InsertDMLType,
+		Values: map[string]types.Datum{
+			"id":    types.NewIntDatum(1),
+			"name":  types.NewBytesDatum([]byte("name")),
+			"data":  types.NewBytesDatum([]byte{0, 1, 255}),
+			"price": types.NewDecimalDatum(types.NewDecFromStringForTest("1.23")),
+			"cnt":   types.NewUintDatum(18446744073709551615),
+		},
+	}
+	msg, err := enc.EncodeRow(20, dml, table)
+	c.Assert(err, check.IsNil)
+	c.Assert(msg.Key, check.NotNil)
+	c.Assert(msg.Value[0], check.Equals, byte(avroMagicByte))
+
+	event, err := dec.Decode(msg)
+	c.Assert(err, check.IsNil)
+	c.Assert(event.Type, check.Equals, EventTypeRow)
+	c.Assert(event.Ts, check.Equals, uint64(20))
+	c.Assert(event.Schema, check.Equals, "test")
+	c.Assert(event.Table, check.Equals, "t1")
+	c.Assert(event.Row.Tp, check.Equals, model.InsertDMLType)
+	c.Assert(event.Row.Columns, check.DeepEquals, map[string]*Column{
+		"id":    {Type: mysql.TypeLong, WhereHandle: true, Value: int64(1)},
+		"name":  {Type: mysql.TypeVarchar, Value: "name"},
+		"data":  {Type: mysql.TypeBlob, Binary: true, Value: []byte{0, 1, 255}},
+		"price": {Type: mysql.TypeNewDecimal, Value: "1.23"},
+		"cnt":   {Type: mysql.TypeLonglong, Unsigned: true, Value: uint64(18446744073709551615)},
+		"ratio": {Type: mysql.TypeDouble},
+	})
+	c.Assert(registry.Versions("test.t1-value"), check.Equals, 1)
+	c.Assert(registry.Versions("test.t1-key"), check.Equals, 1)
+
+	// the same table definition doesn't register a new version
+	_, err = enc.EncodeRow(21, dml, table)
+	c.Assert(err, check.IsNil)
+	c.Assert(registry.Versions("test.t1-value"), check.Equals, 1)
+
+	// a DDL adds a column
+	table = testTableInfo()
+	table.ID = 10
+	table.UpdateTS = 2
+	table.Columns = append(table.Columns, newColumnInfo(7, "extra", mysql.TypeLong, 0))
+	dml.Tp = model.DeleteDMLType
+	dml.Values["extra"] = types.NewIntDatum(7)
+	msg, err = enc.EncodeRow(22, dml, table)
+	c.Assert(err, check.IsNil)
+	c.Assert(registry.Versions("test.t1-value"), check.Equals, 2)
+	c.Assert(registry.Versions("test.t1-key"), check.Equals, 1)
+	event, err = dec.Decode(msg)
+	c.Assert(err, check.IsNil)
+	c.Assert(event.Row.Tp, check.Equals, model.DeleteDMLType)
+	c.Assert(event.Row.Columns["extra"], check.DeepEquals, &Column{Type: mysql.TypeLong, Value: int64(7)})
+
+	_, err = enc.EncodeRow(23, dml, nil)
+	c.Assert(err, check.NotNil)
+}
+
+func (s avroSuite) TestDDLAndResolved(c *check.C) {
+	registry := NewMemorySchemaRegistry()
+	enc := NewAvroEncoder(registry)
+	dec := NewAvroDecoder(registry)
+
+	msg, err := enc.EncodeDDL(5, &model.DDL{
+		Database: "test",
+		Table:    "t1",
+		Job:      &timodel.Job{Query: "create table t1(id int primary key)", Type: timodel.ActionCreateTable},
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(msg.Key, check.IsNil)
+	event, err := dec.Decode(msg)
+	c.Assert(err, check.IsNil)
+	c.Assert(event, check.DeepEquals, &Event{
+		Type:   EventTypeDDL,
+		Ts:     5,
+		Schema: "test",
+		Table:  "t1",
+		DDL:    &DDLEvent{Query: "create table t1(id int primary key)", Type: timodel.ActionCreateTable},
+	})
+
+	msg, err = enc.EncodeResolvedTs(6)
+	c.Assert(err, check.IsNil)
+	event, err = dec.Decode(msg)
+	c.Assert(err, check.IsNil)
+	c.Assert(event, check.DeepEquals, &Event{Type: EventTypeResolved, Ts: 6})
+
+	_, err = dec.Decode(&Message{Value: []byte{1, 0, 0, 0, 1}})
+	c.Assert(err, check.ErrorMatches, "invalid avro message header")
+}
@@ -0,0 +1,504 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codec
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/pingcap/errors"
+	timodel "github.com/pingcap/parser/model"
+	"github.com/pingcap/parser/mysql"
+	parsertypes "github.com/pingcap/parser/types"
+	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/tidb/store/tikv/oracle"
+	"github.com/pingcap/tidb/types"
+)
+
+// The Canal protocol encodes every event into a Canal Packet of type MESSAGES,
+// just like the Kafka producer of Canal does when flatMessage is false, so that
+// the consumers can decode it by CanalMessageDeserializer. The Packet carries
+// exactly one Entry:
+//   a row event is an Entry of ROWDATA with a RowChange of one RowData,
+//   a DDL event is an Entry of ROWDATA with a RowChange whose isDdl is true,
+//   a resolved event is an Entry of HEARTBEAT, which is ignored by most consumers.
+// The commit ts is stored in the `commitTs` property of the Header.
+
+const (
+	canalProtocolVersion = 1
+	canalServerEncode    = "UTF-8"
+	canalCommitTsKey     = "commitTs"
+)
+
+// Values of java.sql.Types
+const (
+	javaSQLTypeBIT       = -7
+	javaSQLTypeTINYINT   = -6
+	javaSQLTypeSMALLINT  = 5
+	javaSQLTypeINTEGER   = 4
+	javaSQLTypeBIGINT    = -5
+	javaSQLTypeREAL      = 7
+	javaSQLTypeDOUBLE    = 8
+	javaSQLTypeDECIMAL   = 3
+	javaSQLTypeCHAR      = 1
+	javaSQLTypeVARCHAR   = 12
+	javaSQLTypeDATE      = 91
+	javaSQLTypeTIME      = 92
+	javaSQLTypeTIMESTAMP = 93
+	javaSQLTypeBINARY    = -2
+	javaSQLTypeVARBINARY = -3
+	javaSQLTypeBLOB      = 2004
+	javaSQLTypeCLOB      = 2005
+	javaSQLTypeNULL      = 0
+)
+
+// CanalEncoder encodes events with the Canal protocol.
+type CanalEncoder struct{}
+
+var _ Encoder = &CanalEncoder{}
+
+// NewCanalEncoder creates a new CanalEncoder.
+func NewCanalEncoder() *CanalEncoder {
+	return &CanalEncoder{}
+}
+
+// EncodeRow implements the Encoder interface
+func (e *CanalEncoder) EncodeRow(ts uint64, dml *model.DML, table *timodel.TableInfo) (*Message, error) {
+	var eventType CanalEventType
+	rowData := &CanalRowData{}
+	var err error
+	switch dml.Tp {
+	case model.InsertDMLType:
+		eventType = CanalEventTypeInsert
+		rowData.AfterColumns, err = canalColumns(dml.Values, table, nil, true)
+	case model.UpdateDMLType:
+		eventType = CanalEventTypeUpdate
+		if rowData.BeforeColumns, err = canalColumns(dml.OldValues, table, nil, false); err != nil {
+			return nil, errors.Trace(err)
+		}
+		rowData.AfterColumns, err = canalColumns(dml.Values, table, rowData.BeforeColumns, true)
+	case model.DeleteDMLType:
+		eventType = CanalEventTypeDelete
+		rowData.BeforeColumns, err = canalColumns(dml.Values, table, nil, false)
+	default:
+		return nil, errors.Errorf("unknown dml type %d", dml.Tp)
+	}
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	isDDL := false
+	rowChange := &CanalRowChange{
+		EventType: &eventType,
+		IsDDL:     &isDDL,
+		RowDatas:  []*CanalRowData{rowData},
+	}
+	if table != nil {
+		rowChange.TableID = table.ID
+	}
+	header := canalHeader(ts, dml.Database, dml.Table, eventType)
+	return canalMessage(ts, header, CanalEntryTypeRowData, rowChange)
+}
+
+// EncodeDDL implements the Encoder interface
+func (e *CanalEncoder) EncodeDDL(ts uint64, ddl *model.DDL) (*Message, error) {
+	eventType := canalDDLEventType(ddl.Job.Type)
+	isDDL := true
+	rowChange := &CanalRowChange{
+		TableID:       ddl.Job.TableID,
+		EventType:     &eventType,
+		IsDDL:         &isDDL,
+		SQL:           ddl.Job.Query,
+		DDLSchemaName: ddl.Database,
+	}
+	header := canalHeader(ts, ddl.Database, ddl.Table, eventType)
+	return canalMessage(ts, header, CanalEntryTypeRowData, rowChange)
+}
+
+// EncodeResolvedTs implements the Encoder interface
+func (e *CanalEncoder) EncodeResolvedTs(ts uint64) (*Message, error) {
+	header := canalHeader(ts, "", "", CanalEventTypeMHeartbeat)
+	return canalMessage(ts, header, CanalEntryTypeHeartbeat, nil)
+}
+
+func canalHeader(ts uint64, schema, table string, eventType CanalEventType) *CanalHeader {
+	version := int32(canalProtocolVersion)
+	sourceType := CanalSourceTypeMySQL
+	return &CanalHeader{
+		Version:      &version,
+		ServerenCode: canalServerEncode,
+		ExecuteTime:  oracle.ExtractPhysical(ts),
+		SourceType:   &sourceType,
+		SchemaName:   schema,
+		TableName:    table,
+		EventType:    &eventType,
+		Props:        []*CanalPair{{Key: canalCommitTsKey, Value: strconv.FormatUint(ts, 10)}},
+	}
+}
+
+func canalMessage(ts uint64, header *CanalHeader, entryType CanalEntryType, rowChange *CanalRowChange) (*Message, error) {
+	entry := &CanalEntry{
+		Header:    header,
+		EntryType: &entryType,
+	}
+	if rowChange != nil {
+		storeValue, err := proto.Marshal(rowChange)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		entry.StoreValue = storeValue
+	}
+	entryData, err := proto.Marshal(entry)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	body, err := proto.Marshal(&CanalMessages{
+		BatchID:  int64(ts),
+		Messages: [][]byte{entryData},
+	})
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	packet, err := proto.Marshal(&CanalPacket{
+		Type: CanalPacketTypeMessages,
+		Body: body,
+	})
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return &Message{Value: packet}, nil
+}
+
+// canalColumns converts a row to Canal columns ordered by their positions in the table.
+// If updated is true, the columns are marked as updated, except the ones that are
+// the same as in the before columns.
+func canalColumns(
+	values map[string]types.Datum,
+	table *timodel.TableInfo,
+	before []*CanalColumn,
+	updated bool,
+) ([]*CanalColumn, error) {
+	type namedColumn struct {
+		name  string
+		index int
+		ft    *types.FieldType
+	}
+	var ordered []namedColumn
+	if table != nil {
+		for i, col := range table.Columns {
+			if _, ok := values[col.Name.O]; ok {
+				ordered = append(ordered, namedColumn{name: col.Name.O, index: i, ft: &col.FieldType})
+			}
+		}
+	}
+	if len(ordered) != len(values) {
+		// some columns are unknown, fall back to the order of names
+		names := make([]string, 0, len(values))
+		for name := range values {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		known := make(map[string]namedColumn, len(ordered))
+		for _, col := range ordered {
+			known[col.name] = col
+		}
+		ordered = ordered[:0]
+		for i, name := range names {
+			col, ok := known[name]
+			if !ok {
+				col = namedColumn{name: name, index: i, ft: datumFieldType(values[name])}
+			}
+			ordered = append(ordered, col)
+		}
+	}
+
+	handles := handleColumns(table)
+	beforeValues := make(map[string]*CanalColumn, len(before))
+	for _, col := range before {
+		beforeValues[col.Name] = col
+	}
+	cols := make([]*CanalColumn, 0, len(ordered))
+	for _, c := range ordered {
+		d := values[c.name]
+		col, err := datumToColumn(d, c.ft)
+		if err != nil {
+			return nil, errors.Annotatef(err, "column %s", c.name)
+		}
+		isNull := col.Value == nil
+		canalCol := &CanalColumn{
+			Index:     int32(c.index),
+			SQLType:   javaSQLType(c.ft),
+			Name:      c.name,
+			IsNull:    &isNull,
+			MysqlType: c.ft.InfoSchemaStr(),
+		}
+		_, canalCol.IsKey = handles[c.name]
+		if !isNull {
+			canalCol.Value = canalValue(d, col)
+		}
+		canalCol.Updated = updated
+		if prev, ok := beforeValues[c.name]; ok && updated {
+			canalCol.Updated = *prev.IsNull != isNull || prev.Value != canalCol.Value
+		}
+		cols = append(cols, canalCol)
+	}
+	return cols, nil
+}
+
+// canalValue formats a value like Canal does, enum and set values are their names
+// and binary values are decoded as ISO-8859-1.
+func canalValue(d types.Datum, col *Column) string {
+	switch col.Type {
+	case mysql.TypeEnum:
+		if d.Kind() != types.KindMysqlEnum {
+			return d.GetString()
+		}
+		return d.GetMysqlEnum().Name
+	case mysql.TypeSet:
+		if d.Kind() != types.KindMysqlSet {
+			return d.GetString()
+		}
+		return d.GetMysqlSet().Name
+	}
+	switch v := col.Value.(type) {
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case uint64:
+		return strconv.FormatUint(v, 10)
+	case float64:
+		if col.Type == mysql.TypeFloat {
+			return strconv.FormatFloat(v, 'f', -1, 32)
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case string:
+		return v
+	case []byte:
+		var b strings.Builder
+		for _, c := range v {
+			b.WriteRune(rune(c))
+		}
+		return b.String()
+	}
+	return ""
+}
+
+func javaSQLType(ft *types.FieldType) int32 {
+	switch ft.Tp {
+	case mysql.TypeBit:
+		return javaSQLTypeBIT
+	case mysql.TypeTiny:
+		return javaSQLTypeTINYINT
+	case mysql.TypeShort:
+		return javaSQLTypeSMALLINT
+	case mysql.TypeInt24, mysql.TypeLong:
+		return javaSQLTypeINTEGER
+	case mysql.TypeLonglong:
+		return javaSQLTypeBIGINT
+	case mysql.TypeFloat:
+		return javaSQLTypeREAL
+	case mysql.TypeDouble:
+		return javaSQLTypeDOUBLE
+	case mysql.TypeNewDecimal:
+		return javaSQLTypeDECIMAL
+	case mysql.TypeDate, mysql.TypeNewDate, mysql.TypeYear:
+		return javaSQLTypeDATE
+	case mysql.TypeDuration:
+		return javaSQLTypeTIME
+	case mysql.TypeDatetime, mysql.TypeTimestamp:
+		return javaSQLTypeTIMESTAMP
+	case mysql.TypeEnum, mysql.TypeSet:
+		return javaSQLTypeCHAR
+	case mysql.TypeString:
+		if isBinary(ft) {
+			return javaSQLTypeBINARY
+		}
+		return javaSQLTypeCHAR
+	case mysql.TypeVarchar, mysql.TypeVarString:
+		if isBinary(ft) {
+			return javaSQLTypeVARBINARY
+		}
+		return javaSQLTypeVARCHAR
+	case mysql.TypeTinyBlob, mysql.TypeMediumBlob, mysql.TypeLongBlob, mysql.TypeBlob:
+		if isBinary(ft) {
+			return javaSQLTypeBLOB
+		}
+		return javaSQLTypeCLOB
+	case mysql.TypeJSON:
+		return javaSQLTypeVARCHAR
+	case mysql.TypeNull:
+		return javaSQLTypeNULL
+	}
+	return javaSQLTypeVARCHAR
+}
+
+func canalDDLEventType(tp timodel.ActionType) CanalEventType {
+	switch tp {
+	case timodel.ActionCreateSchema, timodel.ActionCreateTable, timodel.ActionCreateView:
+		return CanalEventTypeCreate
+	case timodel.ActionDropSchema, timodel.ActionDropTable, timodel.ActionDropView:
+		return CanalEventTypeErase
+	case timodel.ActionTruncateTable:
+		return CanalEventTypeTruncate
+	case timodel.ActionRenameTable:
+		return CanalEventTypeRename
+	case timodel.ActionAddIndex:
+		return CanalEventTypeCIndex
+	case timodel.ActionDropIndex:
+		return CanalEventTypeDIndex
+	case timodel.ActionAddColumn, timodel.ActionDropColumn, timodel.ActionModifyColumn,
+		timodel.ActionSetDefaultValue, timodel.ActionModifyTableComment, timodel.ActionRenameIndex,
+		timodel.ActionAddTablePartition, timodel.ActionDropTablePartition,
+		timodel.ActionModifyTableCharsetAndCollate, timodel.ActionTruncateTablePartition,
+		timodel.ActionAddForeignKey, timodel.ActionDropForeignKey:
+		return CanalEventTypeAlter
+	}
+	return CanalEventTypeQuery
+}
+
+// CanalDecoder decodes messages encoded by CanalEncoder.
+type CanalDecoder struct{}
+
+var _ Decoder = &CanalDecoder{}
+
+// NewCanalDecoder creates a new CanalDecoder.
+func NewCanalDecoder() *CanalDecoder {
+	return &CanalDecoder{}
+}
+
+// Decode implements the Decoder interface
+func (d *CanalDecoder) Decode(msg *Message) (*Event, error) {
+	packet := &CanalPacket{}
+	if err := proto.Unmarshal(msg.Value, packet); err != nil {
+		return nil, errors.Annotate(err, "decode packet")
+	}
+	if packet.Type != CanalPacketTypeMessages {
+		return nil, errors.Errorf("unexpected packet type %d", packet.Type)
+	}
+	messages := &CanalMessages{}
+	if err := proto.Unmarshal(packet.Body, messages); err != nil {
+		return nil, errors.Annotate(err, "decode messages")
+	}
+	if len(messages.Messages) != 1 {
+		return nil, errors.Errorf("expect one entry in a message, got %d", len(messages.Messages))
+	}
+	entry := &CanalEntry{}
+	if err := proto.Unmarshal(messages.Messages[0], entry); err != nil {
+		return nil, errors.Annotate(err, "decode entry")
+	}
+	if entry.Header == nil || entry.EntryType == nil {
+		return nil, errors.New("incomplete entry")
+	}
+
+	event := &Event{
+		Schema: entry.Header.SchemaName,
+		Table:  entry.Header.TableName,
+	}
+	for _, p := range entry.Header.Props {
+		if p.Key == canalCommitTsKey {
+			ts, err := strconv.ParseUint(p.Value, 10, 64)
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+			event.Ts = ts
+		}
+	}
+	if *entry.EntryType == CanalEntryTypeHeartbeat {
+		event.Type = EventTypeResolved
+		event.Schema, event.Table = "", ""
+		return event, nil
+	}
+	if *entry.EntryType != CanalEntryTypeRowData {
+		return nil, errors.Errorf("unexpected entry type %d", *entry.EntryType)
+	}
+
+	rowChange := &CanalRowChange{}
+	if err := proto.Unmarshal(entry.StoreValue, rowChange); err != nil {
+		return nil, errors.Annotate(err, "decode row change")
+	}
+	if rowChange.IsDDL != nil && *rowChange.IsDDL {
+		event.Type = EventTypeDDL
+		event.DDL = &DDLEvent{Query: rowChange.SQL}
+		return event, nil
+	}
+	if len(rowChange.RowDatas) != 1 || rowChange.EventType == nil {
+		return nil, errors.New("invalid row change")
+	}
+	rowData := rowChange.RowDatas[0]
+	row := &RowEvent{}
+	switch *rowChange.EventType {
+	case CanalEventTypeInsert:
+		row.Tp = model.InsertDMLType
+		row.Columns = fromCanalColumns(rowData.AfterColumns)
+	case CanalEventTypeUpdate:
+		row.Tp = model.UpdateDMLType
+		row.Columns = fromCanalColumns(rowData.AfterColumns)
+		row.PreColumns = fromCanalColumns(rowData.BeforeColumns)
+	case CanalEventTypeDelete:
+		row.Tp = model.DeleteDMLType
+		row.Columns = fromCanalColumns(rowData.BeforeColumns)
+	default:
+		return nil, errors.Errorf("unexpected event type %d", *rowChange.EventType)
+	}
+	event.Type = EventTypeRow
+	event.Row = row
+	return event, nil
+}
+
+var strToMySQLType = func() map[string]byte {
+	m := make(map[string]byte)
+	for tp := 0; tp <= 0xff; tp++ {
+		for _, cs := range []string{"", "binary"} {
+			if s := parsertypes.TypeToStr(byte(tp), cs); s != "" {
+				if _, ok := m[s]; !ok {
+					m[s] = byte(tp)
+				}
+			}
+		}
+	}
+	return m
+}()
+
+func fromCanalColumns(canalCols []*CanalColumn) map[string]*Column {
+	cols := make(map[string]*Column, len(canalCols))
+	for _, c := range canalCols {
+		name := c.MysqlType
+		if i := strings.IndexAny(name, "( "); i >= 0 {
+			name = name[:i]
+		}
+		col := &Column{
+			Type:        strToMySQLType[name],
+			Unsigned:    strings.HasSuffix(c.MysqlType, " unsigned"),
+			WhereHandle: c.IsKey,
+		}
+		switch c.SQLType {
+		case javaSQLTypeBINARY, javaSQLTypeVARBINARY, javaSQLTypeBLOB:
+			col.Binary = true
+		}
+		if c.IsNull == nil || !*c.IsNull {
+			if col.Binary {
+				b := make([]byte, 0, len(c.Value))
+				for _, r := range c.Value {
+					b = append(b, byte(r))
+				}
+				col.Value = b
+			} else {
+				col.Value = c.Value
+			}
+		}
+		cols[c.Name] = col
+	}
+	return cols
+}
@@ -0,0 +1,226 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codec
+
+import (
+	"github.com/golang/protobuf/proto"
+)
+
+// The messages below mirror EntryProtocol.proto and CanalProtocol.proto of
+// Alibaba Canal (package com.alibaba.otter.canal.protocol), the field numbers
+// must be kept identical to them. Canal declares the fields with non-zero
+// defaults in single field oneofs to tell whether they are present, which is
+// the same as the optional fields on the wire, so they're modeled as pointers.
+
+// CanalEntryType is the EntryType of Canal
+type CanalEntryType int32
+
+// CanalEntryType types
+const (
+	CanalEntryTypeCompatibleProto2 CanalEntryType = 0
+	CanalEntryTypeTransactionBegin CanalEntryType = 1
+	CanalEntryTypeRowData          CanalEntryType = 2
+	CanalEntryTypeTransactionEnd   CanalEntryType = 3
+	CanalEntryTypeHeartbeat        CanalEntryType = 4
+	CanalEntryTypeGTIDLog          CanalEntryType = 5
+)
+
+// CanalEventType is the EventType of Canal
+type CanalEventType int32
+
+// CanalEventType types
+const (
+	CanalEventTypeCompatibleProto2 CanalEventType = 0
+	CanalEventTypeInsert           CanalEventType = 1
+	CanalEventTypeUpdate           CanalEventType = 2
+	CanalEventTypeDelete           CanalEventType = 3
+	CanalEventTypeCreate           CanalEventType = 4
+	CanalEventTypeAlter            CanalEventType = 5
+	CanalEventTypeErase            CanalEventType = 6
+	CanalEventTypeQuery            CanalEventType = 7
+	CanalEventTypeTruncate         CanalEventType = 8
+	CanalEventTypeRename           CanalEventType = 9
+	CanalEventTypeCIndex           CanalEventType = 10
+	CanalEventTypeDIndex           CanalEventType = 11
+	CanalEventTypeGTID             CanalEventType = 12
+	CanalEventTypeXACommit         CanalEventType = 13
+	CanalEventTypeXARollback       CanalEventType = 14
+	CanalEventTypeMHeartbeat       CanalEventType = 15
+)
+
+// CanalSourceType is the Type of Canal, it's the type of the source database
+type CanalSourceType int32
+
+// CanalSourceType types
+const (
+	CanalSourceTypeCompatibleProto2 CanalSourceType = 0
+	CanalSourceTypeOracle           CanalSourceType = 1
+	CanalSourceTypeMySQL            CanalSourceType = 2
+	CanalSourceTypePgSQL            CanalSourceType = 3
+)
+
+// CanalPacketType is the PacketType of Canal
+type CanalPacketType int32
+
+// CanalPacketTypeMessages is the only packet type we produce
+const CanalPacketTypeMessages CanalPacketType = 7
+
+// CanalEntry is the Entry of Canal
+type CanalEntry struct {
+	Header     *CanalHeader    `protobuf:"bytes,1,opt,name=header,proto3" json:"header,omitempty"`
+	EntryType  *CanalEntryType `protobuf:"varint,2,opt,name=entryType" json:"entryType,omitempty"`
+	StoreValue []byte          `protobuf:"bytes,3,opt,name=storeValue,proto3" json:"storeValue,omitempty"`
+}
+
+// CanalHeader is the Header of Canal
+type CanalHeader struct {
+	Version       *int32           `protobuf:"varint,1,opt,name=version" json:"version,omitempty"`
+	LogfileName   string           `protobuf:"bytes,2,opt,name=logfileName,proto3" json:"logfileName,omitempty"`
+	LogfileOffset int64            `protobuf:"varint,3,opt,name=logfileOffset,proto3" json:"logfileOffset,omitempty"`
+	ServerID      int64            `protobuf:"varint,4,opt,name=serverId,proto3" json:"serverId,omitempty"`
+	ServerenCode  string           `protobuf:"bytes,5,opt,name=serverenCode,proto3" json:"serverenCode,omitempty"`
+	ExecuteTime   int64            `protobuf:"varint,6,opt,name=executeTime,proto3" json:"executeTime,omitempty"`
+	SourceType    *CanalSourceType `protobuf:"varint,7,opt,name=sourceType" json:"sourceType,omitempty"`
+	SchemaName    string           `protobuf:"bytes,8,opt,name=schemaName,proto3" json:"schemaName,omitempty"`
+	TableName     string           `protobuf:"bytes,9,opt,name=tableName,proto3" json:"tableName,omitempty"`
+	EventLength   int64            `protobuf:"varint,10,opt,name=eventLength,proto3" json:"eventLength,omitempty"`
+	EventType     *CanalEventType  `protobuf:"varint,11,opt,name=eventType" json:"eventType,omitempty"`
+	Props         []*CanalPair     `protobuf:"bytes,12,rep,name=props,proto3" json:"props,omitempty"`
+	GTID          string           `protobuf:"bytes,13,opt,name=gtid,proto3" json:"gtid,omitempty"`
+}
+
+// CanalColumn is the Column of Canal
+type CanalColumn struct {
+	Index     int32        `protobuf:"varint,1,opt,name=index,proto3" json:"index,omitempty"`
+	SQLType   int32        `protobuf:"varint,2,opt,name=sqlType,proto3" json:"sqlType,omitempty"`
+	Name      string       `protobuf:"bytes,3,opt,name=name,proto3" json:"name,omitempty"`
+	IsKey     bool         `protobuf:"varint,4,opt,name=isKey,proto3" json:"isKey,omitempty"`
+	Updated   bool         `protobuf:"varint,5,opt,name=updated,proto3" json:"updated,omitempty"`
+	IsNull    *bool        `protobuf:"varint,6,opt,name=isNull" json:"isNull,omitempty"`
+	Props     []*CanalPair `protobuf:"bytes,7,rep,name=props,proto3" json:"props,omitempty"`
+	Value     string       `protobuf:"bytes,8,opt,name=value,proto3" json:"value,omitempty"`
+	Length    int32        `protobuf:"varint,9,opt,name=length,proto3" json:"length,omitempty"`
+	MysqlType string       `protobuf:"bytes,10,opt,name=mysqlType,proto3" json:"mysqlType,omitempty"`
+}
+
+// CanalRowData is the RowData of Canal
+type CanalRowData struct {
+	BeforeColumns []*CanalColumn `protobuf:"bytes,1,rep,name=beforeColumns,proto3" json:"beforeColumns,omitempty"`
+	AfterColumns  []*CanalColumn `protobuf:"bytes,2,rep,name=afterColumns,proto3" json:"afterColumns,omitempty"`
+	Props         []*CanalPair   `protobuf:"bytes,3,rep,name=props,proto3" json:"props,omitempty"`
+}
+
+// CanalRowChange is the RowChange of Canal
+type CanalRowChange struct {
+	TableID       int64           `protobuf:"varint,1,opt,name=tableId,proto3" json:"tableId,omitempty"`
+	EventType     *CanalEventType `protobuf:"varint,2,opt,name=eventType" json:"eventType,omitempty"`
+	IsDDL         *bool           `protobuf:"varint,10,opt,name=isDdl" json:"isDdl,omitempty"`
+	SQL           string          `protobuf:"bytes,11,opt,name=sql,proto3" json:"sql,omitempty"`
+	RowDatas      []*CanalRowData `protobuf:"bytes,12,rep,name=rowDatas,proto3" json:"rowDatas,omitempty"`
+	Props         []*CanalPair    `protobuf:"bytes,13,rep,name=props,proto3" json:"props,omitempty"`
+	DDLSchemaName string          `protobuf:"bytes,14,opt,name=ddlSchemaName,proto3" json:"ddlSchemaName,omitempty"`
+}
+
+// CanalPair is the Pair of Canal
+type CanalPair struct {
+	Key   string `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Value string `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+}
+
+// CanalPacket is the Packet of Canal
+type CanalPacket struct {
+	MagicNumber *int32          `protobuf:"varint,1,opt,name=magic_number" json:"magic_number,omitempty"`
+	Version     *int32          `protobuf:"varint,2,opt,name=version" json:"version,omitempty"`
+	Type        CanalPacketType `protobuf:"varint,3,opt,name=type,proto3" json:"type,omitempty"`
+	Compression *int32          `protobuf:"varint,4,opt,name=compression" json:"compression,omitempty"`
+	Body        []byte          `protobuf:"bytes,5,opt,name=body,proto3" json:"body,omitempty"`
+}
+
+// CanalMessages is the Messages of Canal
+type CanalMessages struct {
+	BatchID  int64    `protobuf:"varint,1,opt,name=batch_id,proto3" json:"batch_id,omitempty"`
+	Messages [][]byte `protobuf:"bytes,2,rep,name=messages,proto3" json:"messages,omitempty"`
+}
+
+// Reset implements proto.Message
+func (m *CanalEntry) Reset() { *m = CanalEntry{} }
+
+// String implements proto.Message
+func (m *CanalEntry) String() string { return proto.CompactTextString(m) }
+
+// ProtoMessage implements proto.Message
+func (*CanalEntry) ProtoMessage() {}
+
+// Reset implements proto.Message
+func (m *CanalHeader) Reset() { *m = CanalHeader{} }
+
+// String implements proto.Message
+func (m *CanalHeader) String() string { return proto.CompactTextString(m) }
+
+// ProtoMessage implements proto.Message
+func (*CanalHeader) ProtoMessage() {}
+
+// Reset implements proto.Message
+func (m *CanalColumn) Reset() { *m = CanalColumn{} }
+
+// String implements proto.Message
+func (m *CanalColumn) String() string { return proto.CompactTextString(m) }
+
+// ProtoMessage implements proto.Message
+func (*CanalColumn) ProtoMessage() {}
+
+// Reset implements proto.Message
+func (m *CanalRowData) Reset() { *m = CanalRowData{} }
+
+// String implements proto.Message
+func (m *CanalRowData) String() string { return proto.CompactTextString(m) }
+
+// ProtoMessage implements proto.Message
+func (*CanalRowData) ProtoMessage() {}
+
+// Reset implements proto.Message
+func (m *CanalRowChange) Reset() { *m = CanalRowChange{} }
+
+// String implements proto.Message
+func (m *CanalRowChange) String() string { return proto.CompactTextString(m) }
+
+// ProtoMessage implements proto.Message
+func (*CanalRowChange) ProtoMessage() {}
+
+// Reset implements proto.Message
+func (m *CanalPair) Reset() { *m = CanalPair{} }
+
+// String implements proto.Message
+func (m *CanalPair) String() string { return proto.CompactTextString(m) }
+
+// ProtoMessage implements proto.Message
+func (*CanalPair) ProtoMessage() {}
+
+// Reset implements proto.Message
+func (m *CanalPacket) Reset() { *m = CanalPacket{} }
+
+// String implements proto.Message
+func (m *CanalPacket) String() string { return proto.CompactTextString(m) }
+
+// ProtoMessage implements proto.Message
+func (*CanalPacket) ProtoMessage() {}
+
+// Reset implements proto.Message
+func (m *CanalMessages) Reset() { *m = CanalMessages{} }
+
+// String implements proto.Message
+func (m *CanalMessages) String() string { return proto.CompactTextString(m) }
+
+// ProtoMessage implements proto.Message
+func (*CanalMessages) ProtoMessage() {}
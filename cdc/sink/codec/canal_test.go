@@ -0,0 +1,174 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codec
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/pingcap/check"
+	timodel "github.com/pingcap/parser/model"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/tidb/types"
+)
+
+type canalSuite struct{}
+
+var _ = check.Suite(&canalSuite{})
+
+func (s canalSuite) decodeRowChange(c *check.C, msg *Message) (*CanalEntry, *CanalRowChange) {
+	packet := &CanalPacket{}
+	c.Assert(proto.Unmarshal(msg.Value, packet), check.IsNil)
+	c.Assert(packet.Type, check.Equals, CanalPacketTypeMessages)
+	messages := &CanalMessages{}
+	c.Assert(proto.Unmarshal(packet.Body, messages), check.IsNil)
+	c.Assert(messages.Messages, check.HasLen, 1)
+	entry := &CanalEntry{}
+	c.Assert(proto.Unmarshal(messages.Messages[0], entry), check.IsNil)
+	rowChange := &CanalRowChange{}
+	c.Assert(proto.Unmarshal(entry.StoreValue, rowChange), check.IsNil)
+	return entry, rowChange
+}
+
+func (s canalSuite) TestUpdate(c *check.C) {
+	dml := &model.DML{
+		Database: "test",
+		Table:    "t1",
+		Tp:       model.UpdateDMLType,
+		Values: map[string]types.Datum{
+			"id":   types.NewIntDatum(1),
+			"name": types.NewBytesDatum([]byte("new")),
+			"data": types.NewBytesDatum([]byte{0, 1, 255}),
+		},
+		OldValues: map[string]types.Datum{
+			"id":   types.NewIntDatum(1),
+			"name": types.NewDatum(nil),
+			"data": types.NewBytesDatum([]byte{0, 1, 255}),
+		},
+	}
+	table := testTableInfo()
+	table.ID = 10
+	msg, err := NewCanalEncoder().EncodeRow(417318403368288260, dml, table)
+	c.Assert(err, check.IsNil)
+
+	entry, rowChange := s.decodeRowChange(c, msg)
+	c.Assert(*entry.EntryType, check.Equals, CanalEntryTypeRowData)
+	c.Assert(*entry.Header.Version, check.Equals, int32(1))
+	c.Assert(*entry.Header.SourceType, check.Equals, CanalSourceTypeMySQL)
+	c.Assert(*entry.Header.EventType, check.Equals, CanalEventTypeUpdate)
+	c.Assert(entry.Header.SchemaName, check.Equals, "test")
+	c.Assert(entry.Header.TableName, check.Equals, "t1")
+	c.Assert(entry.Header.ExecuteTime, check.Equals, int64(1591943372224))
+	c.Assert(rowChange.TableID, check.Equals, int64(10))
+	c.Assert(*rowChange.IsDDL, check.IsFalse)
+	c.Assert(rowChange.RowDatas, check.HasLen, 1)
+
+	isNull, notNull := true, false
+	c.Assert(rowChange.RowDatas[0].BeforeColumns, check.DeepEquals, []*CanalColumn{
+		{Index: 0, SQLType: javaSQLTypeINTEGER, Name: "id", IsKey: true, IsNull: &notNull, Value: "1", MysqlType: "int(11)"},
+		{Index: 1, SQLType: javaSQLTypeVARCHAR, Name: "name", IsNull: &isNull, MysqlType: table.Columns[1].InfoSchemaStr()},
+		{Index: 2, SQLType: javaSQLTypeBLOB, Name: "data", IsNull: &notNull, Value: "\x00\x01ÿ", MysqlType: "blob"},
+	})
+	after := rowChange.RowDatas[0].AfterColumns
+	c.Assert(after, check.HasLen, 3)
+	c.Assert(after[0].Updated, check.IsFalse)
+	c.Assert(after[1].Updated, check.IsTrue)
+	c.Assert(after[1].Value, check.Equals, "new")
+	c.Assert(after[2].Updated, check.IsFalse)
+
+	event, err := NewCanalDecoder().Decode(msg)
+	c.Assert(err, check.IsNil)
+	c.Assert(event.Type, check.Equals, EventTypeRow)
+	c.Assert(event.Ts, check.Equals, uint64(417318403368288260))
+	c.Assert(event.Row.Tp, check.Equals, model.UpdateDMLType)
+	c.Assert(event.Row.Columns, check.DeepEquals, map[string]*Column{
+		"id":   {Type: mysql.TypeLong, WhereHandle: true, Value: "1"},
+		"name": {Type: mysql.TypeVarchar, Value: "new"},
+		"data": {Type: mysql.TypeBlob, Binary: true, Value: []byte{0, 1, 255}},
+	})
+	c.Assert(event.Row.PreColumns["name"], check.DeepEquals, &Column{Type: mysql.TypeVarchar})
+}
+
+func (s canalSuite) TestInsertAndDelete(c *check.C) {
+	dml := &model.DML{
+		Database: "test",
+		Table:    "t1",
+		Tp:       model.InsertDMLType,
+		Values:   map[string]types.Datum{"b": types.NewUintDatum(2), "a": types.NewFloat64Datum(0.5)},
+	}
+	enc := NewCanalEncoder()
+	msg, err := enc.EncodeRow(1, dml, nil)
+	c.Assert(err, check.IsNil)
+	_, rowChange := s.decodeRowChange(c, msg)
+	c.Assert(*rowChange.EventType, check.Equals, CanalEventTypeInsert)
+	cols := rowChange.RowDatas[0].AfterColumns
+	c.Assert(cols, check.HasLen, 2)
+	c.Assert(cols[0].Name, check.Equals, "a")
+	c.Assert(cols[0].Value, check.Equals, "0.5")
+	c.Assert(cols[0].Updated, check.IsTrue)
+	c.Assert(cols[1].Name, check.Equals, "b")
+	c.Assert(cols[1].Value, check.Equals, "2")
+	c.Assert(cols[1].MysqlType, check.Equals, "bigint(20) unsigned")
+	c.Assert(rowChange.RowDatas[0].BeforeColumns, check.HasLen, 0)
+
+	dml.Tp = model.DeleteDMLType
+	msg, err = enc.EncodeRow(2, dml, nil)
+	c.Assert(err, check.IsNil)
+	_, rowChange = s.decodeRowChange(c, msg)
+	c.Assert(*rowChange.EventType, check.Equals, CanalEventTypeDelete)
+	c.Assert(rowChange.RowDatas[0].BeforeColumns, check.HasLen, 2)
+	c.Assert(rowChange.RowDatas[0].BeforeColumns[0].Updated, check.IsFalse)
+	c.Assert(rowChange.RowDatas[0].AfterColumns, check.HasLen, 0)
+
+	event, err := NewCanalDecoder().Decode(msg)
+	c.Assert(err, check.IsNil)
+	c.Assert(event.Row.Tp, check.Equals, model.DeleteDMLType)
+	c.Assert(event.Row.Columns["b"], check.DeepEquals, &Column{Type: mysql.TypeLonglong, Unsigned: true, Value: "2"})
+}
+
+func (s canalSuite) TestDDLAndResolved(c *check.C) {
+	enc := NewCanalEncoder()
+	msg, err := enc.EncodeDDL(5, &model.DDL{
+		Database: "test",
+		Table:    "t1",
+		Job:      &timodel.Job{TableID: 10, Query: "alter table t1 add column c int", Type: timodel.ActionAddColumn},
+	})
+	c.Assert(err, check.IsNil)
+	entry, rowChange := s.decodeRowChange(c, msg)
+	c.Assert(*entry.Header.EventType, check.Equals, CanalEventTypeAlter)
+	c.Assert(*rowChange.IsDDL, check.IsTrue)
+	c.Assert(rowChange.SQL, check.Equals, "alter table t1 add column c int")
+	c.Assert(rowChange.DDLSchemaName, check.Equals, "test")
+	c.Assert(rowChange.TableID, check.Equals, int64(10))
+
+	event, err := NewCanalDecoder().Decode(msg)
+	c.Assert(err, check.IsNil)
+	c.Assert(event, check.DeepEquals, &Event{
+		Type:   EventTypeDDL,
+		Ts:     5,
+		Schema: "test",
+		Table:  "t1",
+		DDL:    &DDLEvent{Query: "alter table t1 add column c int"},
+	})
+
+	msg, err = enc.EncodeResolvedTs(6)
+	c.Assert(err, check.IsNil)
+	event, err = NewCanalDecoder().Decode(msg)
+	c.Assert(err, check.IsNil)
+	c.Assert(event, check.DeepEquals, &Event{Type: EventTypeResolved, Ts: 6})
+
+	c.Assert(canalDDLEventType(timodel.ActionCreateTable), check.Equals, CanalEventTypeCreate)
+	c.Assert(canalDDLEventType(timodel.ActionDropSchema), check.Equals, CanalEventTypeErase)
+	c.Assert(canalDDLEventType(timodel.ActionRenameTable), check.Equals, CanalEventTypeRename)
+	c.Assert(canalDDLEventType(timodel.ActionLockTable), check.Equals, CanalEventTypeQuery)
+}
@@ -0,0 +1,94 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codec
+
+import (
+	timodel "github.com/pingcap/parser/model"
+	"github.com/pingcap/ticdc/cdc/model"
+)
+
+// EventType is the type of an encoded event
+type EventType int
+
+// EventType types
+const (
+	EventTypeUnknown EventType = iota
+	EventTypeRow
+	EventTypeDDL
+	EventTypeResolved
+)
+
+// Message is an encoded event, sinks which have no notion of key,
+// like a file, are free to concatenate the key and the value.
+type Message struct {
+	Key   []byte
+	Value []byte
+}
+
+// Encoder encodes the changes of a changefeed into messages.
+type Encoder interface {
+	// EncodeRow encodes a row changed event, table is used to determine the types
+	// and the handle of the columns and may be nil if it's unknown.
+	EncodeRow(ts uint64, dml *model.DML, table *timodel.TableInfo) (*Message, error)
+	// EncodeDDL encodes a DDL event.
+	EncodeDDL(ts uint64, ddl *model.DDL) (*Message, error)
+	// EncodeResolvedTs encodes a resolved ts event, which means all events
+	// with commit ts less than or equal to ts have been encoded.
+	EncodeResolvedTs(ts uint64) (*Message, error)
+}
+
+// Decoder decodes messages produced by the Encoder of the same protocol.
+type Decoder interface {
+	Decode(msg *Message) (*Event, error)
+}
+
+// Event is a decoded event.
+type Event struct {
+	Type   EventType
+	Ts     uint64
+	Schema string
+	Table  string
+	// Row is set when Type is EventTypeRow
+	Row *RowEvent
+	// DDL is set when Type is EventTypeDDL
+	DDL *DDLEvent
+}
+
+// RowEvent is a decoded row changed event.
+type RowEvent struct {
+	Tp model.DMLType
+	// Columns is the row after the change, it's the deleted row for a delete event.
+	Columns map[string]*Column
+	// PreColumns is the row before the change, it's only set for an update event
+	// if the old values are known.
+	PreColumns map[string]*Column
+}
+
+// DDLEvent is a decoded DDL event.
+type DDLEvent struct {
+	Query string
+	Type  timodel.ActionType
+}
+
+// Column is the value of a column with its type.
+type Column struct {
+	// Type is the MySQL type of the column, see `github.com/pingcap/parser/mysql`.
+	Type     byte
+	Unsigned bool
+	Binary   bool
+	// WhereHandle is true if the column is a part of the key which identifies the row.
+	WhereHandle bool
+	// Value is one of nil, int64, uint64, float64, string and []byte.
+	Value interface{}
+}
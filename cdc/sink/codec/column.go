@@ -0,0 +1,219 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codec
+
+import (
+	"fmt"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/charset"
+	timodel "github.com/pingcap/parser/model"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/tidb/sessionctx/stmtctx"
+	"github.com/pingcap/tidb/types"
+)
+
+// handleColumns returns the names of the columns which identify a row,
+// the primary key is preferred, otherwise a unique key of not null columns is chosen.
+func handleColumns(table *timodel.TableInfo) map[string]struct{} {
+	if table == nil {
+		return nil
+	}
+	if table.PKIsHandle {
+		if pk := table.GetPkColInfo(); pk != nil {
+			return map[string]struct{}{pk.Name.O: {}}
+		}
+	}
+	var chosen *timodel.IndexInfo
+	for _, idx := range table.Indices {
+		if idx.Primary {
+			chosen = idx
+			break
+		}
+		if !idx.Unique || chosen != nil {
+			continue
+		}
+		notNull := true
+		for _, col := range idx.Columns {
+			if !mysql.HasNotNullFlag(table.Columns[col.Offset].Flag) {
+				notNull = false
+				break
+			}
+		}
+		if notNull {
+			chosen = idx
+		}
+	}
+	if chosen == nil {
+		return nil
+	}
+	cols := make(map[string]struct{}, len(chosen.Columns))
+	for _, col := range chosen.Columns {
+		cols[col.Name.O] = struct{}{}
+	}
+	return cols
+}
+
+// rowColumns converts the datums of a row to columns, the datums of
+// the columns not in the table definition are converted by their kinds.
+func rowColumns(values map[string]types.Datum, table *timodel.TableInfo) (map[string]*Column, error) {
+	var columnInfos map[string]*timodel.ColumnInfo
+	if table != nil {
+		columnInfos = make(map[string]*timodel.ColumnInfo, len(table.Columns))
+		for _, col := range table.Columns {
+			columnInfos[col.Name.O] = col
+		}
+	}
+	handles := handleColumns(table)
+	cols := make(map[string]*Column, len(values))
+	for name, datum := range values {
+		var ft *types.FieldType
+		if info, ok := columnInfos[name]; ok {
+			ft = &info.FieldType
+		} else {
+			ft = datumFieldType(datum)
+		}
+		col, err := datumToColumn(datum, ft)
+		if err != nil {
+			return nil, errors.Annotatef(err, "column %s", name)
+		}
+		_, col.WhereHandle = handles[name]
+		cols[name] = col
+	}
+	return cols, nil
+}
+
+func isBinary(ft *types.FieldType) bool {
+	switch ft.Tp {
+	case mysql.TypeVarchar, mysql.TypeVarString, mysql.TypeString,
+		mysql.TypeTinyBlob, mysql.TypeMediumBlob, mysql.TypeLongBlob, mysql.TypeBlob:
+		return ft.Charset == charset.CharsetBin
+	}
+	return false
+}
+
+// datumFieldType guesses the field type of a datum whose column definition is unknown.
+func datumFieldType(d types.Datum) *types.FieldType {
+	var ft *types.FieldType
+	switch d.Kind() {
+	case types.KindInt64:
+		ft = types.NewFieldType(mysql.TypeLonglong)
+	case types.KindUint64:
+		ft = types.NewFieldType(mysql.TypeLonglong)
+		ft.Flag |= mysql.UnsignedFlag
+	case types.KindFloat32:
+		ft = types.NewFieldType(mysql.TypeFloat)
+	case types.KindFloat64:
+		ft = types.NewFieldType(mysql.TypeDouble)
+	case types.KindString:
+		ft = types.NewFieldType(mysql.TypeVarchar)
+	case types.KindBytes:
+		ft = types.NewFieldType(mysql.TypeBlob)
+		ft.Charset = charset.CharsetBin
+	case types.KindMysqlDecimal:
+		ft = types.NewFieldType(mysql.TypeNewDecimal)
+	case types.KindMysqlDuration:
+		ft = types.NewFieldType(mysql.TypeDuration)
+	case types.KindMysqlTime:
+		ft = types.NewFieldType(d.GetMysqlTime().Type)
+	case types.KindMysqlEnum:
+		ft = types.NewFieldType(mysql.TypeEnum)
+	case types.KindMysqlSet:
+		ft = types.NewFieldType(mysql.TypeSet)
+	case types.KindMysqlBit, types.KindBinaryLiteral:
+		ft = types.NewFieldType(mysql.TypeBit)
+	case types.KindMysqlJSON:
+		ft = types.NewFieldType(mysql.TypeJSON)
+	default:
+		ft = types.NewFieldType(mysql.TypeNull)
+	}
+	return ft
+}
+
+// datumToColumn formats a datum like the MySQL sink does, temporal, decimal
+// and JSON values are formatted as strings, enum, set and bit values are
+// formatted as their integer values.
+func datumToColumn(d types.Datum, ft *types.FieldType) (*Column, error) {
+	col := &Column{
+		Type:     ft.Tp,
+		Unsigned: mysql.HasUnsignedFlag(ft.Flag),
+		Binary:   isBinary(ft),
+	}
+	if d.IsNull() {
+		return col, nil
+	}
+	switch ft.Tp {
+	case mysql.TypeDate, mysql.TypeDatetime, mysql.TypeNewDate, mysql.TypeTimestamp,
+		mysql.TypeDuration, mysql.TypeNewDecimal, mysql.TypeJSON:
+		col.Value = fmt.Sprintf("%v", d.GetValue())
+		return col, nil
+	case mysql.TypeEnum:
+		// the default values filled in by the mounter are the names
+		if d.Kind() != types.KindMysqlEnum {
+			enum, err := types.ParseEnumName(ft.Elems, d.GetString())
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+			col.Value = enum.Value
+			return col, nil
+		}
+		col.Value = d.GetMysqlEnum().Value
+		return col, nil
+	case mysql.TypeSet:
+		if d.Kind() != types.KindMysqlSet {
+			set, err := types.ParseSetName(ft.Elems, d.GetString())
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+			col.Value = set.Value
+			return col, nil
+		}
+		col.Value = d.GetMysqlSet().Value
+		return col, nil
+	case mysql.TypeBit:
+		val, err := d.GetBinaryLiteral().ToInt(nil)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		col.Value = val
+		return col, nil
+	case mysql.TypeFloat, mysql.TypeDouble:
+		// the default values filled in by the mounter are strings
+		val, err := d.ToFloat64(&stmtctx.StatementContext{})
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		col.Value = val
+		return col, nil
+	}
+	switch v := d.GetValue().(type) {
+	case int64, uint64, float64:
+		col.Value = v
+	case string:
+		if col.Binary {
+			col.Value = []byte(v)
+		} else {
+			col.Value = v
+		}
+	case []byte:
+		if col.Binary {
+			col.Value = v
+		} else {
+			col.Value = string(v)
+		}
+	default:
+		col.Value = fmt.Sprintf("%v", v)
+	}
+	return col, nil
+}
@@ -0,0 +1,298 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codec
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+
+	"github.com/pingcap/errors"
+	timodel "github.com/pingcap/parser/model"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/ticdc/cdc/model"
+)
+
+// JSONProtocolVersion is the version of the JSON protocol, it's bumped
+// whenever an incompatible change is made to the format below.
+//
+// Every event is encoded into a key and a value. The key is like
+//
+//	{"v":1,"ts":415508856908021766,"scm":"test","tbl":"t1","t":1}
+//
+// where `t` is the EventType, `scm` and `tbl` are omitted for resolved events.
+//
+// The value of a row event is like
+//
+//	{"op":"u","c":{"id":{"t":3,"h":true,"v":1}},"p":{"id":{"t":3,"h":true,"v":2}}}
+//
+// where `op` is one of "i"(insert), "u"(update) and "d"(delete), `c` is the row
+// after the change (the deleted row for a delete) and `p` is the row before an update.
+// Every column is encoded as
+//
+//	`t`: the MySQL type, see github.com/pingcap/parser/mysql
+//	`u`: true if the column is unsigned
+//	`b`: true if the column is binary, the value is base64 encoded
+//	`h`: true if the column is a part of the handle key of the row
+//	`v`: the value, temporal, decimal and JSON values are strings,
+//	     enum, set and bit values are integers
+//
+// The value of a DDL event is like
+//
+//	{"q":"create table t1(id int primary key)","t":3}
+//
+// where `q` is the query and `t` is the ActionType of the DDL job.
+//
+// The value of a resolved event is empty.
+const JSONProtocolVersion = 1
+
+type jsonMessageKey struct {
+	Version int       `json:"v"`
+	Ts      uint64    `json:"ts"`
+	Schema  string    `json:"scm,omitempty"`
+	Table   string    `json:"tbl,omitempty"`
+	Type    EventType `json:"t"`
+}
+
+type jsonColumn struct {
+	Type        byte        `json:"t"`
+	Unsigned    bool        `json:"u,omitempty"`
+	Binary      bool        `json:"b,omitempty"`
+	WhereHandle bool        `json:"h,omitempty"`
+	Value       interface{} `json:"v"`
+}
+
+type jsonRowValue struct {
+	Op         string                 `json:"op"`
+	Columns    map[string]*jsonColumn `json:"c"`
+	PreColumns map[string]*jsonColumn `json:"p,omitempty"`
+}
+
+type jsonDDLValue struct {
+	Query string             `json:"q"`
+	Type  timodel.ActionType `json:"t"`
+}
+
+var (
+	dmlTypeToOp = map[model.DMLType]string{
+		model.InsertDMLType: "i",
+		model.UpdateDMLType: "u",
+		model.DeleteDMLType: "d",
+	}
+	opToDMLType = map[string]model.DMLType{
+		"i": model.InsertDMLType,
+		"u": model.UpdateDMLType,
+		"d": model.DeleteDMLType,
+	}
+)
+
+// JSONEncoder encodes events with the JSON protocol.
+type JSONEncoder struct{}
+
+var _ Encoder = &JSONEncoder{}
+
+// NewJSONEncoder creates a new JSONEncoder.
+func NewJSONEncoder() *JSONEncoder {
+	return &JSONEncoder{}
+}
+
+// EncodeRow implements the Encoder interface
+func (e *JSONEncoder) EncodeRow(ts uint64, dml *model.DML, table *timodel.TableInfo) (*Message, error) {
+	op, ok := dmlTypeToOp[dml.Tp]
+	if !ok {
+		return nil, errors.Errorf("unknown dml type %d", dml.Tp)
+	}
+	key, err := json.Marshal(&jsonMessageKey{
+		Version: JSONProtocolVersion,
+		Ts:      ts,
+		Schema:  dml.Database,
+		Table:   dml.Table,
+		Type:    EventTypeRow,
+	})
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	row := &jsonRowValue{Op: op}
+	cols, err := rowColumns(dml.Values, table)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	row.Columns = toJSONColumns(cols)
+	if dml.Tp == model.UpdateDMLType && len(dml.OldValues) > 0 {
+		preCols, err := rowColumns(dml.OldValues, table)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		row.PreColumns = toJSONColumns(preCols)
+	}
+	value, err := json.Marshal(row)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return &Message{Key: key, Value: value}, nil
+}
+
+// EncodeDDL implements the Encoder interface
+func (e *JSONEncoder) EncodeDDL(ts uint64, ddl *model.DDL) (*Message, error) {
+	key, err := json.Marshal(&jsonMessageKey{
+		Version: JSONProtocolVersion,
+		Ts:      ts,
+		Schema:  ddl.Database,
+		Table:   ddl.Table,
+		Type:    EventTypeDDL,
+	})
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	value, err := json.Marshal(&jsonDDLValue{
+		Query: ddl.Job.Query,
+		Type:  ddl.Job.Type,
+	})
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return &Message{Key: key, Value: value}, nil
+}
+
+// EncodeResolvedTs implements the Encoder interface
+func (e *JSONEncoder) EncodeResolvedTs(ts uint64) (*Message, error) {
+	key, err := json.Marshal(&jsonMessageKey{
+		Version: JSONProtocolVersion,
+		Ts:      ts,
+		Type:    EventTypeResolved,
+	})
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return &Message{Key: key}, nil
+}
+
+func toJSONColumns(cols map[string]*Column) map[string]*jsonColumn {
+	jsonCols := make(map[string]*jsonColumn, len(cols))
+	for name, col := range cols {
+		jsonCols[name] = &jsonColumn{
+			Type:        col.Type,
+			Unsigned:    col.Unsigned,
+			Binary:      col.Binary,
+			WhereHandle: col.WhereHandle,
+			Value:       col.Value,
+		}
+	}
+	return jsonCols
+}
+
+// JSONDecoder decodes messages encoded by JSONEncoder.
+type JSONDecoder struct{}
+
+var _ Decoder = &JSONDecoder{}
+
+// NewJSONDecoder creates a new JSONDecoder.
+func NewJSONDecoder() *JSONDecoder {
+	return &JSONDecoder{}
+}
+
+// Decode implements the Decoder interface
+func (d *JSONDecoder) Decode(msg *Message) (*Event, error) {
+	var key jsonMessageKey
+	if err := json.Unmarshal(msg.Key, &key); err != nil {
+		return nil, errors.Annotate(err, "decode key")
+	}
+	if key.Version != JSONProtocolVersion {
+		return nil, errors.Errorf("unsupported protocol version %d", key.Version)
+	}
+	event := &Event{
+		Type:   key.Type,
+		Ts:     key.Ts,
+		Schema: key.Schema,
+		Table:  key.Table,
+	}
+	switch key.Type {
+	case EventTypeRow:
+		var value jsonRowValue
+		dec := json.NewDecoder(bytes.NewReader(msg.Value))
+		dec.UseNumber()
+		if err := dec.Decode(&value); err != nil {
+			return nil, errors.Annotate(err, "decode row")
+		}
+		tp, ok := opToDMLType[value.Op]
+		if !ok {
+			return nil, errors.Errorf("unknown row op %s", value.Op)
+		}
+		row := &RowEvent{Tp: tp}
+		var err error
+		if row.Columns, err = fromJSONColumns(value.Columns); err != nil {
+			return nil, errors.Trace(err)
+		}
+		if value.PreColumns != nil {
+			if row.PreColumns, err = fromJSONColumns(value.PreColumns); err != nil {
+				return nil, errors.Trace(err)
+			}
+		}
+		event.Row = row
+	case EventTypeDDL:
+		var value jsonDDLValue
+		if err := json.Unmarshal(msg.Value, &value); err != nil {
+			return nil, errors.Annotate(err, "decode ddl")
+		}
+		event.DDL = &DDLEvent{Query: value.Query, Type: value.Type}
+	case EventTypeResolved:
+	default:
+		return nil, errors.Errorf("unknown event type %d", key.Type)
+	}
+	return event, nil
+}
+
+func fromJSONColumns(jsonCols map[string]*jsonColumn) (map[string]*Column, error) {
+	cols := make(map[string]*Column, len(jsonCols))
+	for name, jsonCol := range jsonCols {
+		value, err := decodeJSONValue(jsonCol)
+		if err != nil {
+			return nil, errors.Annotatef(err, "column %s", name)
+		}
+		cols[name] = &Column{
+			Type:        jsonCol.Type,
+			Unsigned:    jsonCol.Unsigned,
+			Binary:      jsonCol.Binary,
+			WhereHandle: jsonCol.WhereHandle,
+			Value:       value,
+		}
+	}
+	return cols, nil
+}
+
+func decodeJSONValue(col *jsonColumn) (interface{}, error) {
+	switch v := col.Value.(type) {
+	case nil:
+		return nil, nil
+	case json.Number:
+		switch col.Type {
+		case mysql.TypeFloat, mysql.TypeDouble:
+			return v.Float64()
+		case mysql.TypeEnum, mysql.TypeSet, mysql.TypeBit:
+			return strconv.ParseUint(v.String(), 10, 64)
+		}
+		if col.Unsigned {
+			return strconv.ParseUint(v.String(), 10, 64)
+		}
+		return v.Int64()
+	case string:
+		if col.Binary {
+			return base64.StdEncoding.DecodeString(v)
+		}
+		return v, nil
+	default:
+		return nil, errors.Errorf("unexpected value %v", v)
+	}
+}
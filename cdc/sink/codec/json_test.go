@@ -0,0 +1,214 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codec
+
+import (
+	"testing"
+
+	"github.com/pingcap/check"
+	"github.com/pingcap/parser/charset"
+	timodel "github.com/pingcap/parser/model"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/tidb/types"
+)
+
+func Test(t *testing.T) { check.TestingT(t) }
+
+type jsonSuite struct{}
+
+var _ = check.Suite(&jsonSuite{})
+
+func newColumnInfo(id int64, name string, tp byte, flag uint) *timodel.ColumnInfo {
+	ft := types.NewFieldType(tp)
+	ft.Flag = flag
+	return &timodel.ColumnInfo{
+		ID:        id,
+		Name:      timodel.NewCIStr(name),
+		Offset:    int(id - 1),
+		FieldType: *ft,
+		State:     timodel.StatePublic,
+	}
+}
+
+func testTableInfo() *timodel.TableInfo {
+	data := newColumnInfo(3, "data", mysql.TypeBlob, mysql.BinaryFlag)
+	data.Charset = charset.CharsetBin
+	name := newColumnInfo(2, "name", mysql.TypeVarchar, 0)
+	name.Charset = charset.CharsetUTF8MB4
+	return &timodel.TableInfo{
+		Name:       timodel.NewCIStr("t1"),
+		PKIsHandle: true,
+		Columns: []*timodel.ColumnInfo{
+			newColumnInfo(1, "id", mysql.TypeLong, mysql.PriKeyFlag|mysql.NotNullFlag),
+			name,
+			data,
+			newColumnInfo(4, "price", mysql.TypeNewDecimal, 0),
+			newColumnInfo(5, "cnt", mysql.TypeLonglong, mysql.UnsignedFlag),
+			newColumnInfo(6, "ratio", mysql.TypeDouble, 0),
+		},
+	}
+}
+
+func (s jsonSuite) TestHandleColumns(c *check.C) {
+	c.Assert(handleColumns(nil), check.IsNil)
+	c.Assert(handleColumns(testTableInfo()), check.DeepEquals, map[string]struct{}{"id": {}})
+
+	table := testTableInfo()
+	table.PKIsHandle = false
+	table.Columns[1].Flag |= mysql.NotNullFlag
+	table.Indices = []*timodel.IndexInfo{
+		{Name: timodel.NewCIStr("idx_price"), Unique: true, Columns: []*timodel.IndexColumn{{Name: timodel.NewCIStr("price"), Offset: 3}}},
+		{Name: timodel.NewCIStr("idx_name"), Unique: true, Columns: []*timodel.IndexColumn{{Name: timodel.NewCIStr("name"), Offset: 1}}},
+	}
+	c.Assert(handleColumns(table), check.DeepEquals, map[string]struct{}{"name": {}})
+}
+
+func (s jsonSuite) TestRowRoundTrip(c *check.C) {
+	tm, err := types.ParseTime(nil, "2019-11-01 00:00:00", mysql.TypeDatetime, 0)
+	c.Assert(err, check.IsNil)
+	dml := &model.DML{
+		Database: "test",
+		Table:    "t1",
+		Tp:       model.UpdateDMLType,
+		Values: map[string]types.Datum{
+			"id":    types.NewIntDatum(1),
+			"name":  types.NewBytesDatum([]byte("name")),
+			"data":  types.NewBytesDatum([]byte{0, 1, 255}),
+			"price": types.NewDecimalDatum(types.NewDecFromStringForTest("1.23")),
+			"cnt":   types.NewUintDatum(18446744073709551615),
+			"ratio": types.NewFloat64Datum(0.5),
+			"extra": types.NewTimeDatum(tm),
+		},
+		OldValues: map[string]types.Datum{
+			"id":   types.NewIntDatum(2),
+			"name": types.NewDatum(nil),
+		},
+	}
+	enc := NewJSONEncoder()
+	msg, err := enc.EncodeRow(10, dml, testTableInfo())
+	c.Assert(err, check.IsNil)
+
+	event, err := NewJSONDecoder().Decode(msg)
+	c.Assert(err, check.IsNil)
+	c.Assert(event.Type, check.Equals, EventTypeRow)
+	c.Assert(event.Ts, check.Equals, uint64(10))
+	c.Assert(event.Schema, check.Equals, "test")
+	c.Assert(event.Table, check.Equals, "t1")
+	c.Assert(event.Row.Tp, check.Equals, model.UpdateDMLType)
+	c.Assert(event.Row.Columns, check.DeepEquals, map[string]*Column{
+		"id":    {Type: mysql.TypeLong, WhereHandle: true, Value: int64(1)},
+		"name":  {Type: mysql.TypeVarchar, Value: "name"},
+		"data":  {Type: mysql.TypeBlob, Binary: true, Value: []byte{0, 1, 255}},
+		"price": {Type: mysql.TypeNewDecimal, Value: "1.23"},
+		"cnt":   {Type: mysql.TypeLonglong, Unsigned: true, Value: uint64(18446744073709551615)},
+		"ratio": {Type: mysql.TypeDouble, Value: 0.5},
+		"extra": {Type: mysql.TypeDatetime, Value: "2019-11-01 00:00:00"},
+	})
+	c.Assert(event.Row.PreColumns, check.DeepEquals, map[string]*Column{
+		"id":   {Type: mysql.TypeLong, WhereHandle: true, Value: int64(2)},
+		"name": {Type: mysql.TypeVarchar},
+	})
+
+	dml = &model.DML{
+		Database: "test",
+		Table:    "t1",
+		Tp:       model.DeleteDMLType,
+		Values:   map[string]types.Datum{"id": types.NewIntDatum(1)},
+	}
+	msg, err = enc.EncodeRow(11, dml, nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(string(msg.Value), check.Equals, `{"op":"d","c":{"id":{"t":8,"v":1}}}`)
+	event, err = NewJSONDecoder().Decode(msg)
+	c.Assert(err, check.IsNil)
+	c.Assert(event.Row.Tp, check.Equals, model.DeleteDMLType)
+	c.Assert(event.Row.PreColumns, check.IsNil)
+}
+
+func (s jsonSuite) TestDefaultValues(c *check.C) {
+	// the mounter fills in the missing columns with their default values,
+	// which are strings whatever the column types are
+	enum := newColumnInfo(2, "e", mysql.TypeEnum, mysql.NotNullFlag)
+	enum.Elems = []string{"a", "b", "c"}
+	enum.DefaultValue = "b"
+	firstEnum := newColumnInfo(3, "fe", mysql.TypeEnum, mysql.NotNullFlag)
+	firstEnum.Elems = []string{"x", "y"}
+	set := newColumnInfo(4, "s", mysql.TypeSet, mysql.NotNullFlag)
+	set.Elems = []string{"a", "b", "c"}
+	set.DefaultValue = "a,c"
+	float := newColumnInfo(5, "f", mysql.TypeFloat, mysql.NotNullFlag)
+	float.DefaultValue = "1.5"
+	table := &timodel.TableInfo{
+		Name:       timodel.NewCIStr("t2"),
+		PKIsHandle: true,
+		Columns: []*timodel.ColumnInfo{
+			newColumnInfo(1, "id", mysql.TypeLong, mysql.PriKeyFlag|mysql.NotNullFlag),
+			enum, firstEnum, set, float,
+		},
+	}
+	dml := &model.DML{
+		Database: "test",
+		Table:    "t2",
+		Tp:       model.InsertDMLType,
+		Values: map[string]types.Datum{
+			"id": types.NewIntDatum(1),
+			"e":  types.NewDatum("b"),
+			"fe": types.NewDatum("x"),
+			"s":  types.NewDatum("a,c"),
+			"f":  types.NewDatum("1.5"),
+		},
+	}
+	msg, err := NewJSONEncoder().EncodeRow(10, dml, table)
+	c.Assert(err, check.IsNil)
+	event, err := NewJSONDecoder().Decode(msg)
+	c.Assert(err, check.IsNil)
+	c.Assert(event.Row.Columns, check.DeepEquals, map[string]*Column{
+		"id": {Type: mysql.TypeLong, WhereHandle: true, Value: int64(1)},
+		"e":  {Type: mysql.TypeEnum, Value: uint64(2)},
+		"fe": {Type: mysql.TypeEnum, Value: uint64(1)},
+		"s":  {Type: mysql.TypeSet, Value: uint64(5)},
+		"f":  {Type: mysql.TypeFloat, Value: 1.5},
+	})
+}
+
+func (s jsonSuite) TestDDLAndResolved(c *check.C) {
+	enc := NewJSONEncoder()
+	msg, err := enc.EncodeDDL(5, &model.DDL{
+		Database: "test",
+		Table:    "t1",
+		Job:      &timodel.Job{Query: "create table t1(id int primary key)", Type: timodel.ActionCreateTable},
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(string(msg.Key), check.Equals, `{"v":1,"ts":5,"scm":"test","tbl":"t1","t":2}`)
+	event, err := NewJSONDecoder().Decode(msg)
+	c.Assert(err, check.IsNil)
+	c.Assert(event, check.DeepEquals, &Event{
+		Type:   EventTypeDDL,
+		Ts:     5,
+		Schema: "test",
+		Table:  "t1",
+		DDL:    &DDLEvent{Query: "create table t1(id int primary key)", Type: timodel.ActionCreateTable},
+	})
+
+	msg, err = enc.EncodeResolvedTs(6)
+	c.Assert(err, check.IsNil)
+	c.Assert(string(msg.Key), check.Equals, `{"v":1,"ts":6,"t":3}`)
+	c.Assert(msg.Value, check.IsNil)
+	event, err = NewJSONDecoder().Decode(msg)
+	c.Assert(err, check.IsNil)
+	c.Assert(event, check.DeepEquals, &Event{Type: EventTypeResolved, Ts: 6})
+
+	_, err = NewJSONDecoder().Decode(&Message{Key: []byte(`{"v":2,"ts":6,"t":3}`)})
+	c.Assert(err, check.ErrorMatches, "unsupported protocol version 2")
+}
@@ -0,0 +1,172 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codec
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/pingcap/errors"
+)
+
+// SchemaRegistry stores the schemas used to encode messages, every message
+// carries the id of its schema instead of the schema itself.
+type SchemaRegistry interface {
+	// Register registers a schema under the subject and returns the id of the schema,
+	// registering a schema which is already registered returns the same id.
+	Register(ctx context.Context, subject string, schema string) (int, error)
+	// Lookup returns the schema of the id.
+	Lookup(ctx context.Context, id int) (string, error)
+}
+
+type memorySubject struct {
+	// ids of all versions of the subject, versions start from 1
+	ids []int
+}
+
+// MemorySchemaRegistry is a SchemaRegistry which keeps everything in memory,
+// it's used by tests and tools that don't need to share schemas.
+type MemorySchemaRegistry struct {
+	mu       sync.Mutex
+	schemas  []string
+	ids      map[string]int
+	subjects map[string]*memorySubject
+}
+
+var _ SchemaRegistry = &MemorySchemaRegistry{}
+
+// NewMemorySchemaRegistry creates a new MemorySchemaRegistry.
+func NewMemorySchemaRegistry() *MemorySchemaRegistry {
+	return &MemorySchemaRegistry{
+		ids:      make(map[string]int),
+		subjects: make(map[string]*memorySubject),
+	}
+}
+
+// Register implements the SchemaRegistry interface
+func (r *MemorySchemaRegistry) Register(ctx context.Context, subject string, schema string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	id, ok := r.ids[schema]
+	if !ok {
+		r.schemas = append(r.schemas, schema)
+		id = len(r.schemas)
+		r.ids[schema] = id
+	}
+	sub, ok := r.subjects[subject]
+	if !ok {
+		sub = &memorySubject{}
+		r.subjects[subject] = sub
+	}
+	for _, existing := range sub.ids {
+		if existing == id {
+			return id, nil
+		}
+	}
+	sub.ids = append(sub.ids, id)
+	return id, nil
+}
+
+// Lookup implements the SchemaRegistry interface
+func (r *MemorySchemaRegistry) Lookup(ctx context.Context, id int) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if id <= 0 || id > len(r.schemas) {
+		return "", errors.Errorf("schema %d not found", id)
+	}
+	return r.schemas[id-1], nil
+}
+
+// Versions returns the number of versions registered under the subject.
+func (r *MemorySchemaRegistry) Versions(subject string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if sub, ok := r.subjects[subject]; ok {
+		return len(sub.ids)
+	}
+	return 0
+}
+
+// confluentSchemaRegistry talks to a Confluent compatible schema registry.
+type confluentSchemaRegistry struct {
+	baseURL string
+	client  *http.Client
+}
+
+// NewConfluentSchemaRegistry creates a SchemaRegistry using the REST API of
+// the Confluent schema registry at the url.
+func NewConfluentSchemaRegistry(registryURL string) (SchemaRegistry, error) {
+	if _, err := url.Parse(registryURL); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return &confluentSchemaRegistry{
+		baseURL: strings.TrimRight(registryURL, "/"),
+		client:  &http.Client{},
+	}, nil
+}
+
+func (r *confluentSchemaRegistry) Register(ctx context.Context, subject string, schema string) (int, error) {
+	body, err := json.Marshal(map[string]string{"schema": schema})
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+	uri := fmt.Sprintf("%s/subjects/%s/versions", r.baseURL, url.PathEscape(subject))
+	var resp struct {
+		ID int `json:"id"`
+	}
+	if err := r.do(ctx, http.MethodPost, uri, body, &resp); err != nil {
+		return 0, errors.Annotatef(err, "register schema of subject %s", subject)
+	}
+	return resp.ID, nil
+}
+
+func (r *confluentSchemaRegistry) Lookup(ctx context.Context, id int) (string, error) {
+	uri := fmt.Sprintf("%s/schemas/ids/%d", r.baseURL, id)
+	var resp struct {
+		Schema string `json:"schema"`
+	}
+	if err := r.do(ctx, http.MethodGet, uri, nil, &resp); err != nil {
+		return "", errors.Annotatef(err, "lookup schema %d", id)
+	}
+	return resp.Schema, nil
+}
+
+func (r *confluentSchemaRegistry) do(ctx context.Context, method, uri string, body []byte, result interface{}) error {
+	req, err := http.NewRequest(method, uri, bytes.NewReader(body))
+	if err != nil {
+		return errors.Trace(err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/vnd.schemaregistry.v1+json")
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("unexpected status %s: %s", resp.Status, data)
+	}
+	return errors.Trace(json.Unmarshal(data, result))
+}
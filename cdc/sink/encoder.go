@@ -0,0 +1,46 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sink
+
+import (
+	"github.com/pingcap/errors"
+	"github.com/pingcap/ticdc/cdc/sink/codec"
+)
+
+const (
+	protocolDefault = "default"
+	protocolAvro    = "avro"
+	protocolCanal   = "canal"
+)
+
+// newEncoder creates the encoder of the protocol specified in the sink uri.
+func newEncoder(protocol string, schemaRegistry string) (codec.Encoder, error) {
+	switch protocol {
+	case protocolDefault:
+		return codec.NewJSONEncoder(), nil
+	case protocolAvro:
+		if len(schemaRegistry) == 0 {
+			return nil, errors.New("schema-registry is required by the avro protocol")
+		}
+		registry, err := codec.NewConfluentSchemaRegistry(schemaRegistry)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		return codec.NewAvroEncoder(registry), nil
+	case protocolCanal:
+		return codec.NewCanalEncoder(), nil
+	default:
+		return nil, errors.Errorf("unknown protocol %s", protocol)
+	}
+}
@@ -0,0 +1,418 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sink
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	timodel "github.com/pingcap/parser/model"
+	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/ticdc/cdc/sink/codec"
+	"github.com/pingcap/ticdc/pkg/filter"
+	"github.com/pingcap/ticdc/pkg/router"
+	"go.uber.org/zap"
+)
+
+const (
+	fileManifestName   = "manifest.json"
+	fileSuffix         = ".log"
+	defaultMaxFileSize = 64 * 1024 * 1024
+
+	filePartitionTable      = "table"
+	filePartitionChangefeed = "changefeed"
+
+	// fileDDLStream is the stream of DDLs when the files are partitioned by table
+	fileDDLStream = "ddl"
+	// fileChangefeedStream is the only stream when the files are partitioned by changefeed
+	fileChangefeedStream = "changefeed"
+)
+
+// fileManifest is written every time a resolved ts is emitted, all events with
+// commit ts less than or equal to ResolvedTs are in the files of the Streams.
+// The files or the parts of files not listed may be incomplete and should be ignored.
+type fileManifest struct {
+	ResolvedTs uint64            `json:"resolved-ts"`
+	Streams    []*fileStreamInfo `json:"streams"`
+}
+
+// fileStreamInfo lists the files 000001.log to Seq of a stream, all of them
+// are complete except the last one, whose first Size bytes are complete.
+type fileStreamInfo struct {
+	// Dir is relative to the root directory of the sink
+	Dir  string `json:"dir"`
+	Seq  int    `json:"seq"`
+	Size int64  `json:"size"`
+}
+
+// fileStream is a sequence of rotating files in the same directory.
+type fileStream struct {
+	dir string
+	// seq is the sequence number of the last file, which is the one being
+	// written if file is not nil
+	seq  int
+	size int64
+	file *os.File
+}
+
+func (s *fileStream) path(seq int) string {
+	return fmt.Sprintf("%s/%06d%s", s.dir, seq, fileSuffix)
+}
+
+// fileSink writes the encoded events into files under a directory, every record
+// in a file is the big endian uint64 length of the key, the key, the big endian
+// uint64 length of the value and the value.
+type fileSink struct {
+	root        string
+	encoder     codec.Encoder
+	filter      *filter.Filter
+	infoGetter  TableInfoGetter
+	perTable    bool
+	maxFileSize int64
+
+	streams map[string]*fileStream
+
+	checkpointTracker
+}
+
+var _ Sink = &fileSink{}
+
+// newFileSink creates a sink from uris like
+// file:///tmp/cdc?partition=table&max-file-size=67108864&protocol=default
+// Every writer of the changefeed has its own directory with its own manifest
+// under the directory of the uri, the DDLs are in the directory of DDLWriter.
+func newFileSink(sinkURI *url.URL, filter *filter.Filter, _ *router.Router, infoGetter TableInfoGetter, opts map[string]string) (Sink, error) {
+	if len(sinkURI.Path) == 0 {
+		return nil, errors.Errorf("no directory found in sink uri: %s", sinkURI)
+	}
+	root := sinkURI.Path
+	if writer := opts[WriterOpt]; writer != "" {
+		root = filepath.Join(root, escapeFileName(writer))
+	}
+	params := sinkURI.Query()
+	s := &fileSink{
+		root:        root,
+		filter:      filter,
+		infoGetter:  infoGetter,
+		perTable:    true,
+		maxFileSize: defaultMaxFileSize,
+		streams:     make(map[string]*fileStream),
+	}
+	switch p := params.Get("partition"); p {
+	case "", filePartitionTable:
+	case filePartitionChangefeed:
+		s.perTable = false
+	default:
+		return nil, errors.Errorf("unknown partition %s", p)
+	}
+	if v := params.Get("max-file-size"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n <= 0 {
+			return nil, errors.Errorf("invalid max-file-size: %s", v)
+		}
+		s.maxFileSize = n
+	}
+	protocol := params.Get("protocol")
+	if protocol == "" {
+		protocol = protocolDefault
+	}
+	var err error
+	if s.encoder, err = newEncoder(protocol, params.Get("schema-registry")); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if err := os.MkdirAll(s.root, 0755); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if err := s.loadManifest(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return s, nil
+}
+
+// loadManifest loads the manifest written before, the last listed file of every
+// stream is truncated to its listed size when it's written again, and the
+// files after it are overwritten.
+func (s *fileSink) loadManifest() error {
+	data, err := ioutil.ReadFile(filepath.Join(s.root, fileManifestName))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Trace(err)
+	}
+	manifest := &fileManifest{}
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return errors.Annotate(err, "invalid manifest")
+	}
+	for _, info := range manifest.Streams {
+		if info.Seq <= 0 || info.Size < 0 {
+			return errors.Errorf("invalid stream %s in manifest", info.Dir)
+		}
+		s.streams[info.Dir] = &fileStream{dir: info.Dir, seq: info.Seq, size: info.Size}
+	}
+	return nil
+}
+
+func (s *fileSink) Emit(ctx context.Context, t model.Txn) error {
+	filterTxn(s.filter, &t)
+	if len(t.DMLs) == 0 && t.DDL == nil {
+		log.Info("Whole txn ignored", zap.Uint64("ts", t.Ts))
+		return nil
+	}
+	if t.IsDDL() {
+		msg, err := s.encoder.EncodeDDL(t.Ts, t.DDL)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		dir := fileDDLStream
+		if !s.perTable {
+			dir = fileChangefeedStream
+		}
+		if err := s.write(dir, msg); err != nil {
+			return errors.Trace(err)
+		}
+		// The owner emits DDLs without resolved ts, so the DDL is listed in
+		// the manifest right away.
+		return errors.Trace(s.EmitResolvedTimestamp(ctx, t.Ts))
+	}
+	for _, dml := range t.DMLs {
+		var tableInfo *timodel.TableInfo
+		if s.infoGetter != nil {
+			tableInfo, _ = getTableDefinition(s.infoGetter, dml.Database, dml.Table)
+		}
+		msg, err := s.encoder.EncodeRow(t.Ts, dml, tableInfo)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		dir := fileChangefeedStream
+		if s.perTable {
+			dir = escapeFileName(dml.Database) + "/" + escapeFileName(dml.Table)
+		}
+		if err := s.write(dir, msg); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
+
+// EmitResolvedTimestamp makes all files durable and writes the manifest.
+func (s *fileSink) EmitResolvedTimestamp(ctx context.Context, resolved uint64) error {
+	if err := s.syncFiles(); err != nil {
+		return errors.Trace(err)
+	}
+	manifest := &fileManifest{ResolvedTs: resolved}
+	for _, stream := range s.streams {
+		if stream.seq == 0 {
+			continue
+		}
+		manifest.Streams = append(manifest.Streams, &fileStreamInfo{
+			Dir:  stream.dir,
+			Seq:  stream.seq,
+			Size: stream.size,
+		})
+	}
+	sort.Slice(manifest.Streams, func(i, j int) bool {
+		return manifest.Streams[i].Dir < manifest.Streams[j].Dir
+	})
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if err := writeFileAtomic(filepath.Join(s.root, fileManifestName), data); err != nil {
+		return errors.Trace(err)
+	}
+	s.setResolvedTs(resolved)
+	return nil
+}
+
+func (s *fileSink) Flush(ctx context.Context) error {
+	if err := s.syncFiles(); err != nil {
+		return errors.Trace(err)
+	}
+	s.flushed()
+	return nil
+}
+
+func (s *fileSink) syncFiles() error {
+	for _, stream := range s.streams {
+		if stream.file == nil {
+			continue
+		}
+		if err := stream.file.Sync(); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
+
+func (s *fileSink) Close() error {
+	var firstErr error
+	for _, stream := range s.streams {
+		if stream.file == nil {
+			continue
+		}
+		if err := stream.file.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		stream.file = nil
+	}
+	return errors.Trace(firstErr)
+}
+
+func (s *fileSink) write(dir string, msg *codec.Message) error {
+	stream, ok := s.streams[dir]
+	if !ok {
+		stream = &fileStream{dir: dir}
+		s.streams[dir] = stream
+	}
+	if stream.file != nil && stream.size >= s.maxFileSize {
+		if err := s.rotate(stream); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	if stream.file == nil {
+		if err := s.open(stream); err != nil {
+			return errors.Trace(err)
+		}
+	}
+
+	buf := make([]byte, 0, 16+len(msg.Key)+len(msg.Value))
+	var lenBuf [8]byte
+	binary.BigEndian.PutUint64(lenBuf[:], uint64(len(msg.Key)))
+	buf = append(buf, lenBuf[:]...)
+	buf = append(buf, msg.Key...)
+	binary.BigEndian.PutUint64(lenBuf[:], uint64(len(msg.Value)))
+	buf = append(buf, lenBuf[:]...)
+	buf = append(buf, msg.Value...)
+	n, err := stream.file.Write(buf)
+	stream.size += int64(n)
+	return errors.Trace(err)
+}
+
+// rotate closes the current file of the stream, the next write opens a new file.
+func (s *fileSink) rotate(stream *fileStream) error {
+	if err := stream.file.Sync(); err != nil {
+		return errors.Trace(err)
+	}
+	if err := stream.file.Close(); err != nil {
+		return errors.Trace(err)
+	}
+	stream.file = nil
+	return nil
+}
+
+// open opens the last file of the stream if it's not full, or creates the next one.
+func (s *fileSink) open(stream *fileStream) error {
+	if err := os.MkdirAll(filepath.Join(s.root, filepath.FromSlash(stream.dir)), 0755); err != nil {
+		return errors.Trace(err)
+	}
+	flag := os.O_CREATE | os.O_WRONLY
+	if stream.seq == 0 || stream.size >= s.maxFileSize {
+		stream.seq++
+		stream.size = 0
+		flag |= os.O_TRUNC
+	}
+	f, err := os.OpenFile(filepath.Join(s.root, filepath.FromSlash(stream.path(stream.seq))), flag, 0644)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	// drop the part of the file written after the manifest
+	if err := f.Truncate(stream.size); err != nil {
+		f.Close()
+		return errors.Trace(err)
+	}
+	if _, err := f.Seek(stream.size, io.SeekStart); err != nil {
+		f.Close()
+		return errors.Trace(err)
+	}
+	stream.file = f
+	return nil
+}
+
+// escapeFileName makes a schema or table name safe to be a directory name.
+func escapeFileName(name string) string {
+	name = url.PathEscape(name)
+	if name == "." || name == ".." {
+		name = strings.Replace(name, ".", "%2E", -1)
+	}
+	return name
+}
+
+// writeFileAtomic writes data to a temporary file and renames it to the file,
+// so the file is either the old one or the new one after a crash.
+func writeFileAtomic(name string, data []byte) error {
+	tmp := name + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return errors.Trace(err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return errors.Trace(err)
+	}
+	if err := f.Close(); err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(os.Rename(tmp, name))
+}
+
+// readFileRecords reads all records written by fileSink from r.
+func readFileRecords(r io.Reader) ([]*codec.Message, error) {
+	var msgs []*codec.Message
+	readPart := func() ([]byte, error) {
+		var lenBuf [8]byte
+		if _, err := io.ReadFull(r, lenBuf[:]); err != nil {
+			return nil, err
+		}
+		data := make([]byte, binary.BigEndian.Uint64(lenBuf[:]))
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		return data, nil
+	}
+	for {
+		key, err := readPart()
+		if err == io.EOF {
+			return msgs, nil
+		}
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		value, err := readPart()
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		msg := &codec.Message{Key: key}
+		if len(value) > 0 {
+			msg.Value = value
+		}
+		msgs = append(msgs, msg)
+	}
+}
@@ -0,0 +1,192 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/pingcap/check"
+	timodel "github.com/pingcap/parser/model"
+	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/ticdc/cdc/sink/codec"
+	"github.com/pingcap/tidb/types"
+)
+
+type fileSuite struct{}
+
+var _ = check.Suite(&fileSuite{})
+
+func (s fileSuite) readManifest(c *check.C, dir string) *fileManifest {
+	data, err := ioutil.ReadFile(filepath.Join(dir, fileManifestName))
+	c.Assert(err, check.IsNil)
+	manifest := &fileManifest{}
+	c.Assert(json.Unmarshal(data, manifest), check.IsNil)
+	return manifest
+}
+
+// readEvents reads the events in the file seq of the stream
+func (s fileSuite) readEvents(c *check.C, dir string, info *fileStreamInfo, seq int) []*codec.Event {
+	path := fmt.Sprintf("%s/%06d%s", info.Dir, seq, fileSuffix)
+	data, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(path)))
+	c.Assert(err, check.IsNil)
+	if seq == info.Seq {
+		c.Assert(int64(len(data)) >= info.Size, check.IsTrue)
+		data = data[:info.Size]
+	}
+	msgs, err := readFileRecords(bytes.NewReader(data))
+	c.Assert(err, check.IsNil)
+	var events []*codec.Event
+	for _, msg := range msgs {
+		event, err := codec.NewJSONDecoder().Decode(msg)
+		c.Assert(err, check.IsNil)
+		events = append(events, event)
+	}
+	return events
+}
+
+func (s fileSuite) insert(ts uint64, table string, id int64) model.Txn {
+	return model.Txn{
+		Ts: ts,
+		DMLs: []*model.DML{{
+			Database: "test",
+			Table:    table,
+			Tp:       model.InsertDMLType,
+			Values:   map[string]types.Datum{"id": types.NewIntDatum(id)},
+		}},
+	}
+}
+
+func (s fileSuite) TestPartitionByTable(c *check.C) {
+	dir := c.MkDir()
+	sink, err := NewSink("file://"+dir+"?max-file-size=1", nil, nil, nil, nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(sink, check.FitsTypeOf, &fileSink{})
+
+	ctx := context.Background()
+	c.Assert(sink.Emit(ctx, s.insert(1, "t1", 1)), check.IsNil)
+	c.Assert(sink.Emit(ctx, s.insert(1, "t2", 2)), check.IsNil)
+	c.Assert(sink.Emit(ctx, model.Txn{
+		Ts: 2,
+		DDL: &model.DDL{
+			Database: "test",
+			Table:    "t1",
+			Job:      &timodel.Job{Query: "drop table t1", Type: timodel.ActionDropTable},
+		},
+	}), check.IsNil)
+	c.Assert(sink.EmitResolvedTimestamp(ctx, 2), check.IsNil)
+
+	manifest := s.readManifest(c, dir)
+	c.Assert(manifest.ResolvedTs, check.Equals, uint64(2))
+	c.Assert(manifest.Streams, check.DeepEquals, []*fileStreamInfo{
+		{Dir: "ddl", Seq: 1, Size: manifest.Streams[0].Size},
+		{Dir: "test/t1", Seq: 1, Size: manifest.Streams[1].Size},
+		{Dir: "test/t2", Seq: 1, Size: manifest.Streams[2].Size},
+	})
+	events := s.readEvents(c, dir, manifest.Streams[0], 1)
+	c.Assert(events, check.HasLen, 1)
+	c.Assert(events[0].DDL.Query, check.Equals, "drop table t1")
+
+	// every file holds one record at most as the max file size is 1 byte
+	c.Assert(sink.Emit(ctx, s.insert(3, "t2", 3)), check.IsNil)
+	manifest = s.readManifest(c, dir)
+	c.Assert(manifest.ResolvedTs, check.Equals, uint64(2))
+	c.Assert(manifest.Streams[2].Seq, check.Equals, 1)
+	c.Assert(sink.EmitResolvedTimestamp(ctx, 3), check.IsNil)
+	c.Assert(sink.Flush(ctx), check.IsNil)
+	c.Assert(sink.CheckpointTs(), check.Equals, uint64(3))
+	manifest = s.readManifest(c, dir)
+	c.Assert(manifest.Streams, check.HasLen, 3)
+	c.Assert(manifest.Streams[2].Seq, check.Equals, 2)
+	events = s.readEvents(c, dir, manifest.Streams[2], 1)
+	c.Assert(events, check.HasLen, 1)
+	c.Assert(events[0].Row.Columns["id"].Value, check.Equals, int64(2))
+	events = s.readEvents(c, dir, manifest.Streams[2], 2)
+	c.Assert(events, check.HasLen, 1)
+	c.Assert(events[0].Ts, check.Equals, uint64(3))
+	c.Assert(events[0].Row.Columns["id"].Value, check.Equals, int64(3))
+
+	// the events after the last resolved ts are dropped after restarting
+	c.Assert(sink.Emit(ctx, s.insert(4, "t2", 4)), check.IsNil)
+	c.Assert(sink.Close(), check.IsNil)
+	sink, err = NewSink("file://"+dir+"?max-file-size=1", nil, nil, nil, nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(sink.Emit(ctx, s.insert(4, "t2", 5)), check.IsNil)
+	c.Assert(sink.EmitResolvedTimestamp(ctx, 4), check.IsNil)
+	c.Assert(sink.Close(), check.IsNil)
+	manifest = s.readManifest(c, dir)
+	c.Assert(manifest.Streams, check.HasLen, 3)
+	c.Assert(manifest.Streams[2].Seq, check.Equals, 3)
+	events = s.readEvents(c, dir, manifest.Streams[2], 3)
+	c.Assert(events, check.HasLen, 1)
+	c.Assert(events[0].Row.Columns["id"].Value, check.Equals, int64(5))
+}
+
+func (s fileSuite) TestPartitionByChangefeed(c *check.C) {
+	dir := c.MkDir()
+	sink, err := NewSink("file://"+dir+"?partition=changefeed", nil, nil, nil, nil)
+	c.Assert(err, check.IsNil)
+
+	ctx := context.Background()
+	c.Assert(sink.Emit(ctx, s.insert(1, "t1", 1)), check.IsNil)
+	c.Assert(sink.Emit(ctx, s.insert(2, "t2", 2)), check.IsNil)
+	c.Assert(sink.EmitResolvedTimestamp(ctx, 2), check.IsNil)
+	c.Assert(sink.Close(), check.IsNil)
+
+	manifest := s.readManifest(c, dir)
+	c.Assert(manifest.Streams, check.HasLen, 1)
+	c.Assert(manifest.Streams[0].Dir, check.Equals, "changefeed")
+	c.Assert(manifest.Streams[0].Seq, check.Equals, 1)
+	events := s.readEvents(c, dir, manifest.Streams[0], 1)
+	c.Assert(events, check.HasLen, 2)
+	c.Assert(events[0].Table, check.Equals, "t1")
+	c.Assert(events[1].Table, check.Equals, "t2")
+
+	// the last file is truncated to the size in the manifest and appended
+	// after restarting
+	sink, err = NewSink("file://"+dir+"?partition=changefeed", nil, nil, nil, nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(sink.Emit(ctx, s.insert(3, "t1", 3)), check.IsNil)
+	c.Assert(sink.Close(), check.IsNil)
+	sink, err = NewSink("file://"+dir+"?partition=changefeed", nil, nil, nil, nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(sink.Emit(ctx, s.insert(3, "t1", 4)), check.IsNil)
+	c.Assert(sink.EmitResolvedTimestamp(ctx, 3), check.IsNil)
+	c.Assert(sink.Close(), check.IsNil)
+	manifest = s.readManifest(c, dir)
+	c.Assert(manifest.Streams[0].Seq, check.Equals, 1)
+	events = s.readEvents(c, dir, manifest.Streams[0], 1)
+	c.Assert(events, check.HasLen, 3)
+	c.Assert(events[2].Row.Columns["id"].Value, check.Equals, int64(4))
+
+	for _, uri := range []string{
+		"file://",
+		"file://" + dir + "?partition=unknown",
+		"file://" + dir + "?max-file-size=0",
+		"file://" + dir + "?protocol=unknown",
+	} {
+		_, err := NewSink(uri, nil, nil, nil, nil)
+		c.Assert(err, check.NotNil, check.Commentf("%s", uri))
+	}
+}
+
+func (s fileSuite) TestEscapeFileName(c *check.C) {
+	c.Assert(escapeFileName("t1"), check.Equals, "t1")
+	c.Assert(escapeFileName("a/b"), check.Equals, "a%2Fb")
+	c.Assert(escapeFileName(".."), check.Equals, "%2E%2E")
+}
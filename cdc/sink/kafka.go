@@ -0,0 +1,342 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sink
+
+import (
+	"context"
+	"hash/fnv"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/Shopify/sarama"
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	timodel "github.com/pingcap/parser/model"
+	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/ticdc/cdc/sink/codec"
+	"github.com/pingcap/ticdc/pkg/filter"
+	"github.com/pingcap/ticdc/pkg/router"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultKafkaVersion    = "2.1.0"
+	defaultKafkaClientID   = "ticdc"
+	defaultMaxMessageBytes = 1024 * 1024
+)
+
+// kafkaConfig stores the configurations parsed from a kafka sink URI
+type kafkaConfig struct {
+	brokers         []string
+	topic           string
+	ddlTopic        string
+	resolvedTopic   string
+	version         string
+	clientID        string
+	maxMessageBytes int
+	protocol        string
+	schemaRegistry  string
+}
+
+// parseKafkaSinkURI parses sink URIs like
+// kafka://127.0.0.1:9092,127.0.0.2:9092/topic?kafka-version=2.1.0&ddl-topic=ddl&resolved-topic=resolved
+// and kafka://127.0.0.1:9092/topic?protocol=avro&schema-registry=http://127.0.0.1:8081
+func parseKafkaSinkURI(sinkURI *url.URL) (*kafkaConfig, error) {
+	cfg := &kafkaConfig{
+		version:         defaultKafkaVersion,
+		clientID:        defaultKafkaClientID,
+		maxMessageBytes: defaultMaxMessageBytes,
+		protocol:        protocolDefault,
+	}
+	if len(sinkURI.Host) == 0 {
+		return nil, errors.Errorf("no broker found in sink uri: %s", sinkURI)
+	}
+	cfg.brokers = strings.Split(sinkURI.Host, ",")
+
+	cfg.topic = strings.TrimFunc(sinkURI.Path, func(r rune) bool {
+		return r == '/'
+	})
+	if len(cfg.topic) == 0 {
+		return nil, errors.Errorf("no topic found in sink uri: %s", sinkURI)
+	}
+
+	params := sinkURI.Query()
+	cfg.ddlTopic = cfg.topic
+	if s := params.Get("ddl-topic"); s != "" {
+		cfg.ddlTopic = s
+	}
+	cfg.resolvedTopic = cfg.topic
+	if s := params.Get("resolved-topic"); s != "" {
+		cfg.resolvedTopic = s
+	}
+	if s := params.Get("kafka-version"); s != "" {
+		cfg.version = s
+	}
+	if s := params.Get("kafka-client-id"); s != "" {
+		cfg.clientID = s
+	}
+	if s := params.Get("max-message-bytes"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, errors.Annotatef(err, "invalid max-message-bytes: %s", s)
+		}
+		cfg.maxMessageBytes = n
+	}
+	if s := params.Get("protocol"); s != "" {
+		cfg.protocol = s
+	}
+	cfg.schemaRegistry = params.Get("schema-registry")
+	return cfg, nil
+}
+
+func newSaramaConfig(cfg *kafkaConfig) (*sarama.Config, error) {
+	config := sarama.NewConfig()
+
+	version, err := sarama.ParseKafkaVersion(cfg.version)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	config.Version = version
+	config.ClientID = cfg.clientID
+	config.Metadata.Retry.Max = 3
+	config.Producer.MaxMessageBytes = cfg.maxMessageBytes
+	config.Producer.Return.Successes = true
+	config.Producer.Return.Errors = true
+	config.Producer.RequiredAcks = sarama.WaitForAll
+	// We choose the partition of every message by ourselves.
+	config.Producer.Partitioner = sarama.NewManualPartitioner
+	return config, config.Validate()
+}
+
+type kafkaSink struct {
+	client     sarama.Client
+	producer   sarama.SyncProducer
+	filter     *filter.Filter
+	infoGetter TableInfoGetter
+	encoder    codec.Encoder
+
+	topic         string
+	ddlTopic      string
+	resolvedTopic string
+	// partitions caches the partition number of every topic we write to
+	partitions map[string]int32
+
+	checkpointTracker
+}
+
+var _ Sink = &kafkaSink{}
+
+// NewKafkaSink creates a new kafka sink
+func NewKafkaSink(
+	sinkURI *url.URL,
+	filter *filter.Filter,
+	_ *router.Router,
+	infoGetter TableInfoGetter,
+	opts map[string]string,
+) (Sink, error) {
+	cfg, err := parseKafkaSinkURI(sinkURI)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	encoder, err := newEncoder(cfg.protocol, cfg.schemaRegistry)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	config, err := newSaramaConfig(cfg)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	client, err := sarama.NewClient(cfg.brokers, config)
+	if err != nil {
+		return nil, errors.Annotatef(err, "connect to kafka brokers %v", cfg.brokers)
+	}
+	producer, err := sarama.NewSyncProducerFromClient(client)
+	if err != nil {
+		client.Close()
+		return nil, errors.Trace(err)
+	}
+	sink := &kafkaSink{
+		client:        client,
+		producer:      producer,
+		filter:        filter,
+		infoGetter:    infoGetter,
+		encoder:       encoder,
+		topic:         cfg.topic,
+		ddlTopic:      cfg.ddlTopic,
+		resolvedTopic: cfg.resolvedTopic,
+		partitions:    make(map[string]int32),
+	}
+	for _, topic := range []string{cfg.topic, cfg.ddlTopic, cfg.resolvedTopic} {
+		if _, err := sink.partitionNum(topic); err != nil {
+			sink.Close()
+			return nil, errors.Trace(err)
+		}
+	}
+	return sink, nil
+}
+
+func (s *kafkaSink) partitionNum(topic string) (int32, error) {
+	if n, ok := s.partitions[topic]; ok {
+		return n, nil
+	}
+	partitions, err := s.client.Partitions(topic)
+	if err != nil {
+		return 0, errors.Annotatef(err, "get partitions of topic %s", topic)
+	}
+	if len(partitions) == 0 {
+		return 0, errors.Errorf("no partition found for topic %s", topic)
+	}
+	s.partitions[topic] = int32(len(partitions))
+	return s.partitions[topic], nil
+}
+
+// Emit sends every DML of the txn to the partition chosen by the table name,
+// and broadcasts a DDL to all partitions of the DDL topic.
+func (s *kafkaSink) Emit(ctx context.Context, t model.Txn) error {
+	filterTxn(s.filter, &t)
+	if len(t.DMLs) == 0 && t.DDL == nil {
+		log.Info("Whole txn ignored", zap.Uint64("ts", t.Ts))
+		return nil
+	}
+
+	var msgs []*sarama.ProducerMessage
+	var err error
+	if t.IsDDL() {
+		msgs, err = s.ddlMessages(t)
+	} else {
+		msgs, err = s.dmlMessages(t)
+	}
+	if err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(s.send(ctx, msgs))
+}
+
+// EmitResolvedTimestamp broadcasts the resolved ts to all partitions of the resolved topic,
+// consumers can assume that all events with commit ts less or equals to the resolved ts
+// in the same partition are received.
+func (s *kafkaSink) EmitResolvedTimestamp(ctx context.Context, resolved uint64) error {
+	msg, err := s.encoder.EncodeResolvedTs(resolved)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	msgs, err := s.broadcast(s.resolvedTopic, msg)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if err := s.send(ctx, msgs); err != nil {
+		return errors.Trace(err)
+	}
+	s.setResolvedTs(resolved)
+	return nil
+}
+
+// Flush implements Sink interface, every message is acknowledged when
+// Emit or EmitResolvedTimestamp returns, so it only advances the checkpoint ts.
+func (s *kafkaSink) Flush(ctx context.Context) error {
+	s.flushed()
+	return nil
+}
+
+func (s *kafkaSink) Close() error {
+	if err := s.producer.Close(); err != nil {
+		return errors.Trace(err)
+	}
+	if s.client == nil {
+		return nil
+	}
+	return errors.Trace(s.client.Close())
+}
+
+func (s *kafkaSink) send(ctx context.Context, msgs []*sarama.ProducerMessage) error {
+	if len(msgs) == 0 {
+		return nil
+	}
+	// SendMessages of the sync producer can block on an unreachable broker,
+	// so we wait for it along with the context. The pending call returns
+	// once the producer is closed.
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- s.producer.SendMessages(msgs)
+	}()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case err := <-errCh:
+		return errors.Trace(err)
+	}
+}
+
+func (s *kafkaSink) dmlMessages(t model.Txn) ([]*sarama.ProducerMessage, error) {
+	partitionNum, err := s.partitionNum(s.topic)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	msgs := make([]*sarama.ProducerMessage, 0, len(t.DMLs))
+	for _, dml := range t.DMLs {
+		var tableInfo *timodel.TableInfo
+		if s.infoGetter != nil {
+			tableInfo, _ = getTableDefinition(s.infoGetter, dml.Database, dml.Table)
+		}
+		msg, err := s.encoder.EncodeRow(t.Ts, dml, tableInfo)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		msgs = append(msgs, newProducerMessage(s.topic, tablePartition(dml.Database, dml.Table, partitionNum), msg))
+	}
+	return msgs, nil
+}
+
+func (s *kafkaSink) ddlMessages(t model.Txn) ([]*sarama.ProducerMessage, error) {
+	msg, err := s.encoder.EncodeDDL(t.Ts, t.DDL)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return s.broadcast(s.ddlTopic, msg)
+}
+
+func (s *kafkaSink) broadcast(topic string, msg *codec.Message) ([]*sarama.ProducerMessage, error) {
+	partitionNum, err := s.partitionNum(topic)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	msgs := make([]*sarama.ProducerMessage, 0, partitionNum)
+	for i := int32(0); i < partitionNum; i++ {
+		msgs = append(msgs, newProducerMessage(topic, i, msg))
+	}
+	return msgs, nil
+}
+
+func newProducerMessage(topic string, partition int32, msg *codec.Message) *sarama.ProducerMessage {
+	pm := &sarama.ProducerMessage{
+		Topic:     topic,
+		Key:       sarama.ByteEncoder(msg.Key),
+		Partition: partition,
+	}
+	if msg.Value != nil {
+		pm.Value = sarama.ByteEncoder(msg.Value)
+	}
+	return pm
+}
+
+// tablePartition dispatches all changes of a table into the same partition,
+// so the changes of a table are consumed in order.
+func tablePartition(schema, table string, partitionNum int32) int32 {
+	h := fnv.New32a()
+	h.Write([]byte(schema))
+	h.Write([]byte{0})
+	h.Write([]byte(table))
+	return int32(h.Sum32() % uint32(partitionNum))
+}
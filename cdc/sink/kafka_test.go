@@ -0,0 +1,231 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sink
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/pingcap/check"
+	timodel "github.com/pingcap/parser/model"
+	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/ticdc/cdc/sink/codec"
+	"github.com/pingcap/tidb/types"
+)
+
+type kafkaSuite struct{}
+
+var _ = check.Suite(&kafkaSuite{})
+
+func (s kafkaSuite) TestParseSinkURI(c *check.C) {
+	uri, err := url.Parse("kafka://127.0.0.1:9092,127.0.0.2:9092/cdc?ddl-topic=ddl&kafka-version=2.3.0&max-message-bytes=4096")
+	c.Assert(err, check.IsNil)
+	cfg, err := parseKafkaSinkURI(uri)
+	c.Assert(err, check.IsNil)
+	c.Assert(cfg.brokers, check.DeepEquals, []string{"127.0.0.1:9092", "127.0.0.2:9092"})
+	c.Assert(cfg.topic, check.Equals, "cdc")
+	c.Assert(cfg.ddlTopic, check.Equals, "ddl")
+	c.Assert(cfg.resolvedTopic, check.Equals, "cdc")
+	c.Assert(cfg.version, check.Equals, "2.3.0")
+	c.Assert(cfg.clientID, check.Equals, defaultKafkaClientID)
+	c.Assert(cfg.maxMessageBytes, check.Equals, 4096)
+	c.Assert(cfg.protocol, check.Equals, protocolDefault)
+
+	uri, err = url.Parse("kafka://127.0.0.1:9092/cdc?protocol=avro&schema-registry=http://127.0.0.1:8081")
+	c.Assert(err, check.IsNil)
+	cfg, err = parseKafkaSinkURI(uri)
+	c.Assert(err, check.IsNil)
+	c.Assert(cfg.protocol, check.Equals, protocolAvro)
+	c.Assert(cfg.schemaRegistry, check.Equals, "http://127.0.0.1:8081")
+
+	for _, bad := range []string{
+		"kafka:///cdc",
+		"kafka://127.0.0.1:9092",
+		"kafka://127.0.0.1:9092/cdc?max-message-bytes=abc",
+	} {
+		uri, err := url.Parse(bad)
+		c.Assert(err, check.IsNil)
+		_, err = parseKafkaSinkURI(uri)
+		c.Assert(err, check.NotNil)
+	}
+}
+
+func (s kafkaSuite) TestNewEncoder(c *check.C) {
+	encoder, err := newEncoder(protocolDefault, "")
+	c.Assert(err, check.IsNil)
+	c.Assert(encoder, check.FitsTypeOf, &codec.JSONEncoder{})
+	encoder, err = newEncoder(protocolAvro, "http://127.0.0.1:8081")
+	c.Assert(err, check.IsNil)
+	c.Assert(encoder, check.FitsTypeOf, &codec.AvroEncoder{})
+	encoder, err = newEncoder(protocolCanal, "")
+	c.Assert(err, check.IsNil)
+	c.Assert(encoder, check.FitsTypeOf, &codec.CanalEncoder{})
+	_, err = newEncoder(protocolAvro, "")
+	c.Assert(err, check.NotNil)
+	_, err = newEncoder("unknown", "")
+	c.Assert(err, check.NotNil)
+}
+
+func (s kafkaSuite) TestTablePartition(c *check.C) {
+	p := tablePartition("test", "t1", 8)
+	c.Assert(p, check.Equals, tablePartition("test", "t1", 8))
+	c.Assert(p >= 0 && p < 8, check.IsTrue)
+	c.Assert(tablePartition("test", "t1", 1), check.Equals, int32(0))
+}
+
+func (s kafkaSuite) TestEmit(c *check.C) {
+	broker := sarama.NewMockBroker(c, 1)
+	defer broker.Close()
+
+	const partitionNum = 3
+	metadata := sarama.NewMockMetadataResponse(c).SetBroker(broker.Addr(), broker.BrokerID())
+	for _, topic := range []string{"cdc", "ddl"} {
+		for p := int32(0); p < partitionNum; p++ {
+			metadata.SetLeader(topic, p, broker.BrokerID())
+		}
+	}
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": metadata,
+		"ProduceRequest":  sarama.NewMockProduceResponse(c).SetVersion(3),
+	})
+
+	uri, err := url.Parse(fmt.Sprintf("kafka://%s/cdc?ddl-topic=ddl", broker.Addr()))
+	c.Assert(err, check.IsNil)
+	sink, err := NewKafkaSink(uri, nil, nil, nil, nil)
+	c.Assert(err, check.IsNil)
+	defer sink.Close()
+
+	ctx := context.Background()
+	err = sink.Emit(ctx, model.Txn{
+		Ts: 1,
+		DMLs: []*model.DML{{
+			Database: "test",
+			Table:    "t1",
+			Tp:       model.InsertDMLType,
+			Values:   map[string]types.Datum{"a": types.NewIntDatum(1)},
+		}},
+	})
+	c.Assert(err, check.IsNil)
+	err = sink.Emit(ctx, model.Txn{
+		Ts: 2,
+		DDL: &model.DDL{
+			Database: "test",
+			Table:    "t1",
+			Job:      &timodel.Job{Query: "drop table t1", Type: timodel.ActionDropTable},
+		},
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(sink.EmitResolvedTimestamp(ctx, 2), check.IsNil)
+	c.Assert(sink.Flush(ctx), check.IsNil)
+}
+
+func (s kafkaSuite) TestMessages(c *check.C) {
+	sink := &kafkaSink{
+		encoder:       codec.NewJSONEncoder(),
+		topic:         "cdc",
+		ddlTopic:      "ddl",
+		resolvedTopic: "cdc",
+		partitions:    map[string]int32{"cdc": 4, "ddl": 2},
+	}
+	decoder := codec.NewJSONDecoder()
+
+	msgs, err := sink.dmlMessages(model.Txn{
+		Ts: 5,
+		DMLs: []*model.DML{{
+			Database: "test",
+			Table:    "t1",
+			Tp:       model.DeleteDMLType,
+			Values:   map[string]types.Datum{"a": types.NewIntDatum(1)},
+		}},
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(msgs, check.HasLen, 1)
+	c.Assert(msgs[0].Topic, check.Equals, "cdc")
+	c.Assert(msgs[0].Partition, check.Equals, tablePartition("test", "t1", 4))
+
+	event, err := decoder.Decode(toCodecMessage(msgs[0]))
+	c.Assert(err, check.IsNil)
+	c.Assert(event.Type, check.Equals, codec.EventTypeRow)
+	c.Assert(event.Ts, check.Equals, uint64(5))
+	c.Assert(event.Row.Tp, check.Equals, model.DeleteDMLType)
+	c.Assert(event.Row.Columns["a"].Value, check.Equals, int64(1))
+
+	msgs, err = sink.ddlMessages(model.Txn{
+		Ts: 6,
+		DDL: &model.DDL{
+			Database: "test",
+			Table:    "t1",
+			Job:      &timodel.Job{Query: "drop table t1", Type: timodel.ActionDropTable},
+		},
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(msgs, check.HasLen, 2)
+	for i, msg := range msgs {
+		c.Assert(msg.Topic, check.Equals, "ddl")
+		c.Assert(msg.Partition, check.Equals, int32(i))
+		event, err := decoder.Decode(toCodecMessage(msg))
+		c.Assert(err, check.IsNil)
+		c.Assert(event.DDL, check.DeepEquals, &codec.DDLEvent{Query: "drop table t1", Type: timodel.ActionDropTable})
+	}
+}
+
+func toCodecMessage(msg *sarama.ProducerMessage) *codec.Message {
+	m := &codec.Message{Key: msg.Key.(sarama.ByteEncoder)}
+	if msg.Value != nil {
+		m.Value = msg.Value.(sarama.ByteEncoder)
+	}
+	return m
+}
+
+// blockedProducer is a sync producer whose SendMessages blocks until it's closed
+type blockedProducer struct {
+	closed chan struct{}
+}
+
+func (p *blockedProducer) SendMessage(*sarama.ProducerMessage) (int32, int64, error) {
+	<-p.closed
+	return 0, 0, sarama.ErrClosedClient
+}
+
+func (p *blockedProducer) SendMessages([]*sarama.ProducerMessage) error {
+	<-p.closed
+	return sarama.ErrClosedClient
+}
+
+func (p *blockedProducer) Close() error {
+	close(p.closed)
+	return nil
+}
+
+func (s kafkaSuite) TestSendCanceled(c *check.C) {
+	producer := &blockedProducer{closed: make(chan struct{})}
+	sink := &kafkaSink{producer: producer}
+	defer sink.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- sink.send(ctx, []*sarama.ProducerMessage{{Topic: "cdc"}})
+	}()
+	cancel()
+	select {
+	case err := <-errCh:
+		c.Assert(err, check.Equals, context.Canceled)
+	case <-time.After(5 * time.Second):
+		c.Fatal("send is not canceled")
+	}
+}
@@ -15,6 +15,7 @@ package sink
 
 import (
 	"context"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	dmysql "github.com/go-sql-driver/mysql"
@@ -23,6 +24,8 @@ import (
 	"github.com/pingcap/parser/mysql"
 	"github.com/pingcap/parser/types"
 	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/ticdc/pkg/filter"
+	"github.com/pingcap/ticdc/pkg/router"
 	"github.com/pingcap/tidb/infoschema"
 	dbtypes "github.com/pingcap/tidb/types"
 )
@@ -247,12 +250,26 @@ func (s *FilterSuite) TestFilterDMLs(c *check.C) {
 		},
 		Ts: 213,
 	}
-	filterBySchemaAndTable(&t)
+	filterTxn(nil, &t)
 	c.Assert(t.Ts, check.Equals, uint64(213))
 	c.Assert(t.DDL, check.IsNil)
 	c.Assert(t.DMLs, check.HasLen, 2)
 	c.Assert(t.DMLs[0].Database, check.Equals, "test")
 	c.Assert(t.DMLs[1].Database, check.Equals, "test_mysql")
+
+	f, err := filter.New(&filter.Rules{IgnoreTables: []*filter.Table{{Schema: "test", Name: "t*"}}}, nil)
+	c.Assert(err, check.IsNil)
+	t = model.Txn{
+		DMLs: []*model.DML{
+			{Database: "test", Table: "t1"},
+			{Database: "test", Table: "a"},
+			{Database: "test_mysql", Table: "t2"},
+		},
+	}
+	filterTxn(f, &t)
+	c.Assert(t.DMLs, check.HasLen, 2)
+	c.Assert(t.DMLs[0].Table, check.Equals, "a")
+	c.Assert(t.DMLs[1].Database, check.Equals, "test_mysql")
 }
 
 func (s *FilterSuite) TestFilterDDL(c *check.C) {
@@ -260,8 +277,241 @@ func (s *FilterSuite) TestFilterDDL(c *check.C) {
 		DDL: &model.DDL{Database: "performance_schema"},
 		Ts:  10234,
 	}
-	filterBySchemaAndTable(&t)
+	filterTxn(nil, &t)
 	c.Assert(t.Ts, check.Equals, uint64((10234)))
 	c.Assert(t.DMLs, check.HasLen, 0)
 	c.Assert(t.DDL, check.IsNil)
 }
+
+func (s EmitSuite) TestCheckpointTs(c *check.C) {
+	db, _, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	c.Assert(err, check.IsNil)
+	defer db.Close()
+
+	sink := mysqlSink{
+		db:           db,
+		tblInspector: dummyInspector{},
+	}
+	ctx := context.Background()
+	c.Assert(sink.EmitResolvedTimestamp(ctx, 10), check.IsNil)
+	c.Assert(sink.CheckpointTs(), check.Equals, uint64(0))
+	c.Assert(sink.Flush(ctx), check.IsNil)
+	c.Assert(sink.CheckpointTs(), check.Equals, uint64(10))
+	c.Assert(sink.EmitResolvedTimestamp(ctx, 20), check.IsNil)
+	c.Assert(sink.CheckpointTs(), check.Equals, uint64(10))
+}
+
+func (s EmitSuite) TestExactSQLAndSafeMode(c *check.C) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	c.Assert(err, check.IsNil)
+	defer db.Close()
+
+	helper := tableHelper{}
+	sink := mysqlSink{
+		db:           db,
+		tblInspector: &helper,
+		infoGetter:   &helper,
+		exactSQL:     true,
+	}
+	txn := func() model.Txn {
+		return model.Txn{
+			DMLs: []*model.DML{
+				{
+					Database: "test",
+					Table:    "user",
+					Tp:       model.InsertDMLType,
+					Values: map[string]dbtypes.Datum{
+						"id":   dbtypes.NewDatum(1),
+						"name": dbtypes.NewDatum("tester1"),
+					},
+				},
+				{
+					Database: "test",
+					Table:    "user",
+					Tp:       model.UpdateDMLType,
+					Values: map[string]dbtypes.Datum{
+						"id":   dbtypes.NewDatum(3),
+						"name": dbtypes.NewDatum("tester2"),
+					},
+					OldValues: map[string]dbtypes.Datum{
+						"id":   dbtypes.NewDatum(2),
+						"name": dbtypes.NewDatum("tester2"),
+					},
+				},
+				{
+					Database: "test",
+					Table:    "user",
+					Tp:       model.UpdateDMLType,
+					Values: map[string]dbtypes.Datum{
+						"id":   dbtypes.NewDatum(4),
+						"name": dbtypes.NewDatum("tester4"),
+					},
+				},
+			},
+		}
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO `test`.`user`(`id`,`name`) VALUES (?,?);").
+		WithArgs(1, "tester1").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("UPDATE `test`.`user` SET `id` = ?,`name` = ? WHERE `id` = ? LIMIT 1;").
+		WithArgs(3, "tester2", 2).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("REPLACE INTO `test`.`user`(`id`,`name`) VALUES (?,?);").
+		WithArgs(4, "tester4").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	c.Assert(sink.Emit(context.Background(), txn()), check.IsNil)
+	c.Assert(mock.ExpectationsWereMet(), check.IsNil)
+
+	// in the safe mode window
+	sink.safeModeUntil = time.Now().Add(time.Hour)
+	mock.ExpectBegin()
+	mock.ExpectExec("REPLACE INTO `test`.`user`(`id`,`name`) VALUES (?,?);").
+		WithArgs(1, "tester1").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("DELETE FROM `test`.`user` WHERE `id` = ? LIMIT 1;").
+		WithArgs(2).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("REPLACE INTO `test`.`user`(`id`,`name`) VALUES (?,?),(?,?);").
+		WithArgs(3, "tester2", 4, "tester4").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	c.Assert(sink.Emit(context.Background(), txn()), check.IsNil)
+	c.Assert(mock.ExpectationsWereMet(), check.IsNil)
+}
+
+func (s EmitSuite) TestRoute(c *check.C) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	c.Assert(err, check.IsNil)
+	defer db.Close()
+
+	r, err := router.New([]*router.Rule{
+		{SchemaPattern: "shard_*", TablePattern: "user_*", TargetSchema: "test", TargetTable: "user"},
+	})
+	c.Assert(err, check.IsNil)
+	helper := tableHelper{}
+	inspector := &refreshRecorder{tableHelper: &helper}
+	sink := mysqlSink{
+		db:           db,
+		router:       r,
+		tblInspector: inspector,
+		infoGetter:   &helper,
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("USE `test`;").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("ALTER TABLE `test`.`user` ADD COLUMN `age` INT").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	err = sink.Emit(context.Background(), model.Txn{
+		DDL: &model.DDL{
+			Database: "shard_1",
+			Table:    "user_1",
+			Job: &timodel.Job{
+				Type:  timodel.ActionAddColumn,
+				Query: "alter table user_1 add column age int",
+			},
+		},
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(inspector.refreshed, check.DeepEquals, []string{"test.user"})
+
+	mock.ExpectBegin()
+	mock.ExpectExec("REPLACE INTO `test`.`user`(`id`,`name`) VALUES (?,?);").
+		WithArgs(1, "tester1").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("DELETE FROM `test`.`user` WHERE `id` = ? LIMIT 1;").
+		WithArgs(2).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	err = sink.Emit(context.Background(), model.Txn{
+		DMLs: []*model.DML{
+			{
+				Database: "shard_1",
+				Table:    "user_1",
+				Tp:       model.InsertDMLType,
+				Values: map[string]dbtypes.Datum{
+					"id":   dbtypes.NewDatum(1),
+					"name": dbtypes.NewDatum("tester1"),
+				},
+			},
+			{
+				Database: "shard_2",
+				Table:    "user_2",
+				Tp:       model.DeleteDMLType,
+				Values: map[string]dbtypes.Datum{
+					"id": dbtypes.NewDatum(2),
+				},
+			},
+		},
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(mock.ExpectationsWereMet(), check.IsNil)
+}
+
+func (s EmitSuite) TestDroppedColumns(c *check.C) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	c.Assert(err, check.IsNil)
+	defer db.Close()
+
+	helper := tableHelper{}
+	sink := mysqlSink{
+		db:           db,
+		tblInspector: &helper,
+		infoGetter:   &helper,
+		exactSQL:     true,
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO `test`.`user`(`id`) VALUES (?);").
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("UPDATE `test`.`user` SET `id` = ? WHERE `id` = ? LIMIT 1;").
+		WithArgs(3, 2).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	err = sink.Emit(context.Background(), model.Txn{
+		DMLs: []*model.DML{
+			{
+				Database: "test",
+				Table:    "user",
+				Tp:       model.InsertDMLType,
+				Values: map[string]dbtypes.Datum{
+					"id": dbtypes.NewDatum(1),
+				},
+			},
+			{
+				Database: "test",
+				Table:    "user",
+				Tp:       model.UpdateDMLType,
+				Values: map[string]dbtypes.Datum{
+					"id": dbtypes.NewDatum(3),
+				},
+				OldValues: map[string]dbtypes.Datum{
+					"id": dbtypes.NewDatum(2),
+				},
+			},
+		},
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(mock.ExpectationsWereMet(), check.IsNil)
+
+	// the rows are located by the remaining columns without any unique key
+	where, args := whereSlice(&tableInfo{columns: []string{"id", "name", "email"}}, map[string]dbtypes.Datum{
+		"id":   dbtypes.NewDatum(1),
+		"name": dbtypes.NewDatum("tester1"),
+	})
+	c.Assert(where, check.DeepEquals, []string{"id", "name"})
+	c.Assert(args, check.DeepEquals, []dbtypes.Datum{dbtypes.NewDatum(1), dbtypes.NewDatum("tester1")})
+}
+
+// refreshRecorder records the tables refreshed
+type refreshRecorder struct {
+	*tableHelper
+	refreshed []string
+}
+
+func (r *refreshRecorder) Refresh(schema, table string) {
+	r.refreshed = append(r.refreshed, schema+"."+table)
+}
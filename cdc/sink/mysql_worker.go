@@ -0,0 +1,230 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sink
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/tidb/types"
+)
+
+const (
+	defaultWorkerCount  = 16
+	defaultBatchSize    = 1
+	defaultBatchLatency = 10 * time.Millisecond
+)
+
+// mysqlJob is a txn to execute, or a flush request if txn is nil
+type mysqlJob struct {
+	ctx context.Context
+	txn *preparedTxn
+}
+
+// mysqlWorker executes the txns dispatched to it one by one, so the txns
+// changing the same rows are executed in order if they're dispatched to
+// the same worker.
+type mysqlWorker struct {
+	jobCh chan mysqlJob
+	// pending counts the jobs dispatched but not executed yet
+	pending sync.WaitGroup
+}
+
+// startWorkers starts the workers executing DMLs concurrently, txns are
+// executed in Emit if no worker is started.
+func (s *mysqlSink) startWorkers(n int) {
+	s.workers = make([]*mysqlWorker, n)
+	for i := range s.workers {
+		w := &mysqlWorker{jobCh: make(chan mysqlJob, 16)}
+		s.workers[i] = w
+		s.workerWg.Add(1)
+		go s.runWorker(w)
+	}
+}
+
+func (s *mysqlSink) runWorker(w *mysqlWorker) {
+	defer s.workerWg.Done()
+	for job := range w.jobCh {
+		jobs := []mysqlJob{job}
+		if job.txn != nil && s.batchSize > 1 {
+			jobs = s.collectBatch(w, jobs)
+		}
+		var ctx context.Context
+		txns := make([]*preparedTxn, 0, len(jobs))
+		for _, job := range jobs {
+			if job.txn != nil {
+				ctx = job.ctx
+				txns = append(txns, job.txn)
+			}
+		}
+		// skip all txns after a failure, the sink is unusable then
+		if len(txns) > 0 && s.getError() == nil {
+			if err := s.execTxns(ctx, txns); err != nil {
+				s.setError(errors.Annotatef(err, "execute txns [%d, %d]", txns[0].ts, txns[len(txns)-1].ts))
+			}
+		}
+		for range jobs {
+			w.pending.Done()
+		}
+	}
+}
+
+// collectBatch receives more txns until there're batchSize txns, batchLatency
+// passed or a flush is requested.
+func (s *mysqlSink) collectBatch(w *mysqlWorker, jobs []mysqlJob) []mysqlJob {
+	timer := time.NewTimer(s.batchLatency)
+	defer timer.Stop()
+	for len(jobs) < s.batchSize {
+		select {
+		case job, ok := <-w.jobCh:
+			if !ok {
+				return jobs
+			}
+			jobs = append(jobs, job)
+			if job.txn == nil {
+				return jobs
+			}
+		case <-timer.C:
+			return jobs
+		}
+	}
+	return jobs
+}
+
+func (s *mysqlSink) stopWorkers() {
+	for _, w := range s.workers {
+		close(w.jobCh)
+	}
+	s.workerWg.Wait()
+	s.workers = nil
+}
+
+// waitWorkers blocks until all dispatched txns are executed.
+func (s *mysqlSink) waitWorkers() error {
+	if s.batchSize > 1 {
+		// stop the workers from waiting for more txns to batch. A batch may span
+		// several resolved ts as Flush is only called on the checkpoint tick of
+		// the processor, but every txn emitted before the Flush has a commit ts
+		// at or before the last resolved ts, and the checkpoint only advances to
+		// it when Flush returns, after all these txns are executed.
+		for _, w := range s.workers {
+			w.pending.Add(1)
+			w.jobCh <- mysqlJob{}
+		}
+	}
+	for _, w := range s.workers {
+		w.pending.Wait()
+	}
+	return s.getError()
+}
+
+// dispatch sends the txn to the worker chosen by the keys of its rows. If the
+// keys are assigned to different workers, the txn may conflict with txns in
+// several workers, so it's executed after all dispatched txns are executed.
+func (s *mysqlSink) dispatch(ctx context.Context, txn *preparedTxn) error {
+	if len(s.workers) == 0 {
+		return errors.Trace(s.execTxns(ctx, []*preparedTxn{txn}))
+	}
+	idx := workerIndex(txn.keys, len(s.workers))
+	if idx < 0 {
+		if err := s.waitWorkers(); err != nil {
+			return errors.Trace(err)
+		}
+		return errors.Trace(s.execTxns(ctx, []*preparedTxn{txn}))
+	}
+	w := s.workers[idx]
+	w.pending.Add(1)
+	select {
+	case w.jobCh <- mysqlJob{ctx: ctx, txn: txn}:
+		return nil
+	case <-ctx.Done():
+		w.pending.Done()
+		return errors.Trace(ctx.Err())
+	}
+}
+
+func (s *mysqlSink) getError() error {
+	s.errMu.Lock()
+	defer s.errMu.Unlock()
+	return s.err
+}
+
+func (s *mysqlSink) setError(err error) {
+	s.errMu.Lock()
+	defer s.errMu.Unlock()
+	if s.err == nil {
+		s.err = err
+	}
+}
+
+// workerIndex returns the worker all keys are hashed to, or -1 if they're
+// hashed to different workers.
+func workerIndex(keys []string, workerCount int) int {
+	idx := -1
+	for _, key := range keys {
+		h := fnv.New32a()
+		h.Write([]byte(key))
+		i := int(h.Sum32() % uint32(workerCount))
+		if idx >= 0 && i != idx {
+			return -1
+		}
+		idx = i
+	}
+	if idx < 0 {
+		return 0
+	}
+	return idx
+}
+
+// dmlKeys returns the values of the unique keys of the rows changed by the DML,
+// the rows can't be identified by any unique key are identified by the table.
+func dmlKeys(info *tableInfo, dml *model.DML) []string {
+	keys := rowKeys(info, dml.TableName(), dml.Values)
+	if dml.OldValues != nil {
+		keys = append(keys, rowKeys(info, dml.TableName(), dml.OldValues)...)
+	}
+	return keys
+}
+
+func rowKeys(info *tableInfo, table string, values map[string]types.Datum) []string {
+	var keys []string
+	for _, index := range info.uniqueKeys {
+		var b strings.Builder
+		b.WriteString(table)
+		b.WriteByte('.')
+		b.WriteString(index.name)
+		notNull := true
+		for _, v := range whereValues(values, index.columns) {
+			if v.IsNull() {
+				notNull = false
+				break
+			}
+			b.WriteByte(0)
+			fmt.Fprintf(&b, "%v", v.GetValue())
+		}
+		if notNull {
+			keys = append(keys, b.String())
+		}
+	}
+	if len(keys) == 0 {
+		keys = append(keys, table)
+	}
+	return keys
+}
@@ -0,0 +1,210 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/pingcap/check"
+	"github.com/pingcap/ticdc/cdc/model"
+	dbtypes "github.com/pingcap/tidb/types"
+)
+
+type workerSuite struct{}
+
+var _ = check.Suite(&workerSuite{})
+
+func (s workerSuite) TestDMLKeys(c *check.C) {
+	info := &tableInfo{
+		columns: []string{"id", "a", "b"},
+		uniqueKeys: []indexInfo{
+			{name: "PRIMARY", columns: []string{"id"}},
+			{name: "uk", columns: []string{"a", "b"}},
+		},
+	}
+	dml := &model.DML{
+		Database: "test",
+		Table:    "t",
+		Tp:       model.InsertDMLType,
+		Values: map[string]dbtypes.Datum{
+			"id": dbtypes.NewIntDatum(1),
+			"a":  dbtypes.NewIntDatum(2),
+			"b":  dbtypes.NewDatum(nil),
+		},
+	}
+	c.Assert(dmlKeys(info, dml), check.DeepEquals, []string{"`test`.`t`.PRIMARY\x001"})
+
+	dml.Tp = model.UpdateDMLType
+	dml.OldValues = map[string]dbtypes.Datum{
+		"id": dbtypes.NewIntDatum(3),
+		"a":  dbtypes.NewIntDatum(2),
+		"b":  dbtypes.NewIntDatum(4),
+	}
+	c.Assert(dmlKeys(info, dml), check.DeepEquals, []string{
+		"`test`.`t`.PRIMARY\x001",
+		"`test`.`t`.PRIMARY\x003",
+		"`test`.`t`.uk\x002\x004",
+	})
+
+	c.Assert(dmlKeys(&tableInfo{columns: []string{"id"}}, dml), check.DeepEquals, []string{"`test`.`t`", "`test`.`t`"})
+}
+
+func (s workerSuite) TestWorkerIndex(c *check.C) {
+	c.Assert(workerIndex(nil, 4), check.Equals, 0)
+	c.Assert(workerIndex([]string{"a"}, 1), check.Equals, 0)
+	c.Assert(workerIndex([]string{"a", "a"}, 4), check.Equals, workerIndex([]string{"a"}, 4))
+
+	// find two keys hashed to different workers
+	first := workerIndex([]string{"k0"}, 4)
+	for i := 1; ; i++ {
+		key := fmt.Sprintf("k%d", i)
+		if workerIndex([]string{key}, 4) != first {
+			c.Assert(workerIndex([]string{"k0", key}, 4), check.Equals, -1)
+			break
+		}
+	}
+}
+
+func (s workerSuite) TestConcurrentEmit(c *check.C) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	c.Assert(err, check.IsNil)
+	defer db.Close()
+	mock.MatchExpectationsInOrder(false)
+
+	helper := tableHelper{}
+	sink := &mysqlSink{
+		db:           db,
+		tblInspector: &helper,
+		infoGetter:   &helper,
+	}
+	sink.startWorkers(4)
+	defer sink.stopWorkers()
+
+	ctx := context.Background()
+	for i := 0; i < 10; i++ {
+		mock.ExpectBegin()
+		mock.ExpectExec("REPLACE INTO `test`.`user`(`id`,`name`) VALUES (?,?);").
+			WithArgs(i, "tester").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+	}
+	for i := 0; i < 10; i++ {
+		err := sink.Emit(ctx, model.Txn{
+			Ts: uint64(i),
+			DMLs: []*model.DML{{
+				Database: "test",
+				Table:    "user",
+				Tp:       model.InsertDMLType,
+				Values: map[string]dbtypes.Datum{
+					"id":   dbtypes.NewDatum(i),
+					"name": dbtypes.NewDatum("tester"),
+				},
+			}},
+		})
+		c.Assert(err, check.IsNil)
+	}
+	c.Assert(sink.EmitResolvedTimestamp(ctx, 10), check.IsNil)
+	c.Assert(sink.Flush(ctx), check.IsNil)
+	c.Assert(sink.CheckpointTs(), check.Equals, uint64(10))
+	c.Assert(mock.ExpectationsWereMet(), check.IsNil)
+
+	// a failed txn fails the following Flush and Emit
+	mock.ExpectBegin().WillReturnError(errors.New("begin failed"))
+	err = sink.Emit(ctx, model.Txn{
+		Ts: 11,
+		DMLs: []*model.DML{{
+			Database: "test",
+			Table:    "user",
+			Tp:       model.DeleteDMLType,
+			Values:   map[string]dbtypes.Datum{"id": dbtypes.NewDatum(1)},
+		}},
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(sink.EmitResolvedTimestamp(ctx, 12), check.IsNil)
+	c.Assert(sink.Flush(ctx), check.ErrorMatches, ".*begin failed.*")
+	c.Assert(sink.CheckpointTs(), check.Equals, uint64(10))
+	c.Assert(sink.Emit(ctx, model.Txn{Ts: 13, DMLs: []*model.DML{{Database: "test"}}}), check.NotNil)
+}
+
+func (s workerSuite) TestMergeStmts(c *check.C) {
+	replace := func(table string, id int) *preparedStmt {
+		return &preparedStmt{
+			sql:          "REPLACE INTO " + table + "(`id`) VALUES (?);",
+			args:         []interface{}{id},
+			valuesPrefix: "REPLACE INTO " + table + "(`id`) VALUES ",
+			valuesRow:    "(?)",
+		}
+	}
+	del := &preparedStmt{sql: "DELETE FROM t1 WHERE `id` = ? LIMIT 1;", args: []interface{}{3}}
+	sqls, values := mergeStmts([]*preparedTxn{
+		{stmts: []*preparedStmt{replace("t1", 1), replace("t1", 2)}},
+		{stmts: []*preparedStmt{del, replace("t1", 4)}},
+		{stmts: []*preparedStmt{replace("t1", 5), replace("t2", 6)}},
+	})
+	c.Assert(sqls, check.DeepEquals, []string{
+		"REPLACE INTO t1(`id`) VALUES (?),(?);",
+		"DELETE FROM t1 WHERE `id` = ? LIMIT 1;",
+		"REPLACE INTO t1(`id`) VALUES (?),(?);",
+		"REPLACE INTO t2(`id`) VALUES (?);",
+	})
+	c.Assert(values, check.DeepEquals, [][]interface{}{{1, 2}, {3}, {4, 5}, {6}})
+}
+
+func (s workerSuite) TestBatchEmit(c *check.C) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	c.Assert(err, check.IsNil)
+	defer db.Close()
+
+	helper := tableHelper{}
+	sink := &mysqlSink{
+		db:           db,
+		tblInspector: &helper,
+		infoGetter:   &helper,
+		batchSize:    4,
+		batchLatency: time.Minute,
+	}
+	sink.startWorkers(1)
+	defer sink.stopWorkers()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("REPLACE INTO `test`.`user`(`id`,`name`) VALUES (?,?),(?,?),(?,?);").
+		WithArgs(0, "tester", 1, "tester", 2, "tester").
+		WillReturnResult(sqlmock.NewResult(3, 3))
+	mock.ExpectCommit()
+
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		err := sink.Emit(ctx, model.Txn{
+			Ts: uint64(i),
+			DMLs: []*model.DML{{
+				Database: "test",
+				Table:    "user",
+				Tp:       model.InsertDMLType,
+				Values: map[string]dbtypes.Datum{
+					"id":   dbtypes.NewDatum(i),
+					"name": dbtypes.NewDatum("tester"),
+				},
+			}},
+		})
+		c.Assert(err, check.IsNil)
+	}
+	// the batch is executed by Flush instead of waiting for the latency
+	c.Assert(sink.EmitResolvedTimestamp(ctx, 3), check.IsNil)
+	c.Assert(sink.Flush(ctx), check.IsNil)
+	c.Assert(mock.ExpectationsWereMet(), check.IsNil)
+}
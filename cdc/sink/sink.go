@@ -17,9 +17,17 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
 
+	dmysql "github.com/go-sql-driver/mysql"
+	"github.com/pingcap/errors"
 	timodel "github.com/pingcap/parser/model"
 	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/ticdc/pkg/filter"
+	"github.com/pingcap/ticdc/pkg/router"
 )
 
 // Sink is an abstraction for anything that a changefeed may emit into.
@@ -29,10 +37,13 @@ type Sink interface {
 		ctx context.Context,
 		resolved uint64,
 	) error
-	// TODO: Add GetLastSuccessTs() uint64
 	// Flush blocks until every message enqueued by EmitRow and
 	// EmitResolvedTimestamp has been acknowledged by the sink.
 	Flush(ctx context.Context) error
+	// CheckpointTs returns the max resolved ts that all events with commit ts
+	// less than or equal to it have been durably written to the downstream.
+	// It's only advanced by Flush.
+	CheckpointTs() uint64
 	// Close does not guarantee delivery of outstanding messages.
 	Close() error
 }
@@ -43,26 +54,118 @@ type TableInfoGetter interface {
 	GetTableIDByName(schema, table string) (int64, bool)
 }
 
+const (
+	// WriterOpt is the sink option that tells the sinks of a changefeed apart,
+	// the processors set it to their capture id.
+	WriterOpt = "writer"
+	// DDLWriter is the writer of the sink that the owner executes DDLs with.
+	DDLWriter = "ddl"
+)
+
+// Factory creates a sink from a parsed sink uri.
+// The sink ignores the changes of the schemas and tables ignored by the filter,
+// and writes the changes to the schemas and tables routed by the router.
+type Factory func(sinkURI *url.URL, filter *filter.Filter, router *router.Router, infoGetter TableInfoGetter, opts map[string]string) (Sink, error)
+
+var (
+	factoriesMu sync.RWMutex
+	factories   = make(map[string]Factory)
+)
+
+func init() {
+	Register("mysql", newMySQLSinkFromURI)
+	Register("tidb", newMySQLSinkFromURI)
+	Register("kafka", NewKafkaSink)
+	Register("file", newFileSink)
+	Register("blackhole", newBlackHoleSink)
+}
+
+// Register makes a sink factory available for the sink uris with the given scheme.
+// It panics if the factory is nil or the scheme is registered twice.
+func Register(scheme string, factory Factory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	if factory == nil {
+		panic("sink: Register factory is nil")
+	}
+	scheme = strings.ToLower(scheme)
+	if _, dup := factories[scheme]; dup {
+		panic("sink: Register called twice for scheme " + scheme)
+	}
+	factories[scheme] = factory
+}
+
+func getFactory(scheme string) (Factory, bool) {
+	factoriesMu.RLock()
+	defer factoriesMu.RUnlock()
+	f, ok := factories[strings.ToLower(scheme)]
+	return f, ok
+}
+
+// NewSink creates a new sink by the factory registered for the scheme of the sink uri.
+// For compatibility, a sink uri which is not a registered uri but a valid MySQL DSN,
+// like `root@tcp(127.0.0.1:3306)/test`, creates a MySQL sink.
+func NewSink(sinkURI string, filter *filter.Filter, router *router.Router, infoGetter TableInfoGetter, opts map[string]string) (Sink, error) {
+	uri, err := url.Parse(sinkURI)
+	if err == nil {
+		if factory, ok := getFactory(uri.Scheme); ok {
+			s, err := factory(uri, filter, router, infoGetter, opts)
+			return s, errors.Trace(err)
+		}
+	}
+	if _, err := dmysql.ParseDSN(sinkURI); err != nil {
+		return nil, errors.Errorf("unsupported sink uri: %s", sinkURI)
+	}
+	return NewMySQLSink(sinkURI, filter, router, infoGetter, opts)
+}
+
+// checkpointTracker implements CheckpointTs for sinks, the sinks call
+// setResolvedTs after a resolved ts is emitted and call flushed after all
+// emitted events are acknowledged.
+type checkpointTracker struct {
+	resolvedTs   uint64
+	checkpointTs uint64
+}
+
+func (t *checkpointTracker) setResolvedTs(ts uint64) {
+	atomic.StoreUint64(&t.resolvedTs, ts)
+}
+
+func (t *checkpointTracker) flushed() {
+	atomic.StoreUint64(&t.checkpointTs, atomic.LoadUint64(&t.resolvedTs))
+}
+
+// CheckpointTs implements Sink interface
+func (t *checkpointTracker) CheckpointTs() uint64 {
+	return atomic.LoadUint64(&t.checkpointTs)
+}
+
 type writerSink struct {
 	io.Writer
+	checkpointTracker
 }
 
 var _ Sink = &writerSink{}
 
 func (s *writerSink) Emit(ctx context.Context, t model.Txn) error {
-	fmt.Fprintf(s, "commit ts: %d", t.Ts)
+	fmt.Fprintf(s, "commit ts: %d\n", t.Ts)
 	return nil
 }
 
 func (s *writerSink) EmitResolvedTimestamp(ctx context.Context, resolved uint64) error {
-	fmt.Fprintf(s, "resolved: %d", resolved)
+	fmt.Fprintf(s, "resolved: %d\n", resolved)
+	s.setResolvedTs(resolved)
 	return nil
 }
 
 func (s *writerSink) Flush(ctx context.Context) error {
+	s.flushed()
 	return nil
 }
 
 func (s *writerSink) Close() error {
+	if c, ok := s.Writer.(io.Closer); ok {
+		return errors.Trace(c.Close())
+	}
 	return nil
 }
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/pingcap/log"
+	pd "github.com/pingcap/pd/client"
 	"go.uber.org/zap"
 
 	"github.com/pingcap/ticdc/cdc"
@@ -19,7 +20,24 @@ func feed() {
 		CreateTime: time.Now(),
 	}
 
-	processor, err := cdc.NewProcessor([]string{"http://localhost:2379"}, detail, "test-changefeed", "test-capture")
+	pdEndpoints := []string{"http://localhost:2379"}
+	schemaStorage, startTs, err := cdc.NewSchemaStorage(pdEndpoints)
+	if err != nil {
+		log.Error("NewSchemaStorage failed", zap.Error(err))
+		return
+	}
+	pdCli, err := pd.NewClient(pdEndpoints, pd.SecurityOption{})
+	if err != nil {
+		log.Error("create pd client failed", zap.Error(err))
+		return
+	}
+	defer pdCli.Close()
+	go func() {
+		err := cdc.PullSchemaStorage(context.Background(), pdCli, schemaStorage, startTs)
+		log.Error("pull schema storage failed", zap.Error(err))
+	}()
+
+	processor, err := cdc.NewProcessor(pdEndpoints, schemaStorage, detail, "test-changefeed", "test-capture")
 	if err != nil {
 		log.Error("NewProcessor failed", zap.Error(err))
 		return
@@ -1,15 +1,13 @@
 package cmd
 
 import (
-	"context"
+	"encoding/json"
 	"fmt"
 	"time"
 
 	"github.com/coreos/etcd/clientv3"
 	_ "github.com/go-sql-driver/mysql" // mysql driver
-	"github.com/google/uuid"
-	"github.com/pingcap/ticdc/cdc/kv"
-	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/errors"
 	"github.com/spf13/cobra"
 	"google.golang.org/grpc"
 )
@@ -17,40 +15,35 @@ import (
 func init() {
 	rootCmd.AddCommand(cliCmd)
 
-	cliCmd.Flags().StringVar(&pdAddress, "pd-addr", "localhost:2379", "address of PD")
-	cliCmd.Flags().Uint64Var(&startTs, "start-ts", 0, "start ts of changefeed")
-	cliCmd.Flags().StringVar(&sinkURI, "sink-uri", "root@tcp(127.0.0.1:3306)/test", "sink uri")
+	cliCmd.PersistentFlags().StringVar(&pdAddress, "pd-addr", "localhost:2379", "address of PD")
+	cliCmd.AddCommand(changefeedCmd, captureCmd, processorCmd, ownerCmd)
 }
 
-var (
-	pdAddress string
-	startTs   uint64
-	sinkURI   string
-)
+var pdAddress string
 
 var cliCmd = &cobra.Command{
 	Use:   "cli",
-	Short: "simulate client to create changefeed",
+	Short: "manage changefeeds, captures and processors of the cluster",
 	Long:  ``,
-	RunE: func(cmd *cobra.Command, args []string) error {
-		cli, err := clientv3.New(clientv3.Config{
-			Endpoints:   []string{pdAddress},
-			DialTimeout: 5 * time.Second,
-			DialOptions: []grpc.DialOption{
-				grpc.WithBackoffMaxDelay(time.Second * 3),
-			},
-		})
-		if err != nil {
-			return err
-		}
-		id := uuid.New().String()
-		detail := &model.ChangeFeedDetail{
-			SinkURI:    sinkURI,
-			Opts:       make(map[string]string),
-			CreateTime: time.Now(),
-			StartTs:    startTs,
-		}
-		fmt.Printf("create changefeed detail %+v\n", detail)
-		return kv.SaveChangeFeedDetail(context.Background(), cli, detail, id)
-	},
+}
+
+func newEtcdClient() (*clientv3.Client, error) {
+	cli, err := clientv3.New(clientv3.Config{
+		Endpoints:   []string{pdAddress},
+		DialTimeout: 5 * time.Second,
+		DialOptions: []grpc.DialOption{
+			grpc.WithBackoffMaxDelay(time.Second * 3),
+		},
+	})
+	return cli, errors.Annotatef(err, "connect to PD %s", pdAddress)
+}
+
+// printJSON prints the indented json encoding of v to stdout
+func printJSON(v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return errors.Trace(err)
+	}
+	fmt.Println(string(data))
+	return nil
 }
@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"context"
+	"sort"
+
+	"github.com/coreos/etcd/clientv3/concurrency"
+	"github.com/pingcap/errors"
+	"github.com/pingcap/ticdc/cdc"
+	"github.com/pingcap/ticdc/cdc/kv"
+	"github.com/pingcap/ticdc/cdc/roles"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	captureCmd.AddCommand(captureListCmd)
+	processorCmd.AddCommand(processorListCmd, processorQueryCmd)
+	ownerCmd.AddCommand(ownerQueryCmd)
+
+	processorQueryCmd.Flags().StringVar(&changefeedID, "changefeed-id", "", "ID of the changefeed")
+	processorQueryCmd.Flags().StringVar(&captureID, "capture-id", "", "ID of the capture running the processor")
+	_ = processorQueryCmd.MarkFlagRequired("changefeed-id")
+	_ = processorQueryCmd.MarkFlagRequired("capture-id")
+}
+
+var captureID string
+
+type capture struct {
+	ID      string `json:"id"`
+	IsOwner bool   `json:"is-owner"`
+}
+
+type processor struct {
+	ChangefeedID string `json:"changefeed-id"`
+	CaptureID    string `json:"capture-id"`
+}
+
+var captureCmd = &cobra.Command{
+	Use:   "capture",
+	Short: "query the captures",
+}
+
+var captureListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "list all captures and the owner of them",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cli, err := newEtcdClient()
+		if err != nil {
+			return err
+		}
+		defer cli.Close()
+		ctx := context.Background()
+		_, infos, err := kv.GetCaptures(ctx, cli)
+		if err != nil {
+			return err
+		}
+		ownerID, err := roles.GetOwnerID(ctx, cli, cdc.CaptureOwnerKey)
+		if err != nil && errors.Cause(err) != concurrency.ErrElectionNoLeader {
+			return err
+		}
+		captures := make([]*capture, 0, len(infos))
+		for _, info := range infos {
+			captures = append(captures, &capture{ID: info.ID, IsOwner: info.ID == ownerID})
+		}
+		return printJSON(captures)
+	},
+}
+
+var processorCmd = &cobra.Command{
+	Use:   "processor",
+	Short: "query the processors, each runs a changefeed on a capture",
+}
+
+var processorListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "list all processors",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cli, err := newEtcdClient()
+		if err != nil {
+			return err
+		}
+		defer cli.Close()
+		ctx := context.Background()
+		_, details, err := kv.GetChangeFeeds(ctx, cli)
+		if err != nil {
+			return err
+		}
+		processors := make([]*processor, 0, len(details))
+		for id := range details {
+			pinfos, err := kv.GetSubChangeFeedInfos(ctx, cli, id)
+			if err != nil {
+				return err
+			}
+			for captureID := range pinfos {
+				processors = append(processors, &processor{ChangefeedID: id, CaptureID: captureID})
+			}
+		}
+		sort.Slice(processors, func(i, j int) bool {
+			if processors[i].ChangefeedID != processors[j].ChangefeedID {
+				return processors[i].ChangefeedID < processors[j].ChangefeedID
+			}
+			return processors[i].CaptureID < processors[j].CaptureID
+		})
+		return printJSON(processors)
+	},
+}
+
+var processorQueryCmd = &cobra.Command{
+	Use:   "query",
+	Short: "query the tables and progress of a processor",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cli, err := newEtcdClient()
+		if err != nil {
+			return err
+		}
+		defer cli.Close()
+		_, info, err := kv.GetSubChangeFeedInfo(context.Background(), cli, changefeedID, captureID)
+		if err != nil {
+			return err
+		}
+		return printJSON(info)
+	},
+}
+
+var ownerCmd = &cobra.Command{
+	Use:   "owner",
+	Short: "query the owner capture",
+}
+
+var ownerQueryCmd = &cobra.Command{
+	Use:   "query",
+	Short: "query the ID of the owner capture",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cli, err := newEtcdClient()
+		if err != nil {
+			return err
+		}
+		defer cli.Close()
+		ownerID, err := roles.GetOwnerID(context.Background(), cli, cdc.CaptureOwnerKey)
+		if err != nil {
+			return err
+		}
+		return printJSON(&capture{ID: ownerID, IsOwner: true})
+	},
+}
@@ -0,0 +1,308 @@
+package cmd
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/google/uuid"
+	"github.com/pingcap/errors"
+	"github.com/pingcap/ticdc/cdc/kv"
+	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/ticdc/pkg/filter"
+	"github.com/pingcap/ticdc/pkg/projection"
+	"github.com/pingcap/ticdc/pkg/router"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	changefeedCmd.AddCommand(
+		changefeedCreateCmd,
+		changefeedListCmd,
+		changefeedQueryCmd,
+		newChangefeedAdminCmd("pause", "pause a changefeed, its processors are stopped", model.AdminStop),
+		newChangefeedAdminCmd("resume", "resume a paused changefeed from its checkpoint", model.AdminResume),
+		newChangefeedAdminCmd("remove", "remove a changefeed and all of its data", model.AdminRemove),
+		changefeedUpdateCmd,
+		changefeedSkipDDLCmd,
+		changefeedReplaceDDLCmd,
+		changefeedDDLAuditCmd,
+	)
+
+	for _, cmd := range []*cobra.Command{changefeedCreateCmd, changefeedUpdateCmd} {
+		cmd.Flags().StringVar(&sinkURI, "sink-uri", "", "sink uri, e.g. mysql://root@127.0.0.1:3306/, kafka://127.0.0.1:9092/topic, file:///tmp/cdc, blackhole://")
+		cmd.Flags().Uint64Var(&targetTs, "target-ts", 0, "target ts of changefeed, the changefeed stops at it, 0 means never stop")
+		cmd.Flags().StringVar(&configPath, "config", "", "path of the toml file of the changefeed config, with do-dbs, ignore-dbs, do-tables, ignore-tables, event-filters, route-rules and column-rules")
+		cmd.Flags().BoolVar(&enableOldValue, "enable-old-value", false, "mount updates with the old values of the rows")
+	}
+	changefeedCreateCmd.Flags().StringVar(&changefeedID, "changefeed-id", "", "ID of the changefeed, a random UUID is used if it's empty")
+	changefeedCreateCmd.Flags().Uint64Var(&startTs, "start-ts", 0, "start ts of changefeed, the current time is used if it's 0")
+	_ = changefeedCreateCmd.MarkFlagRequired("sink-uri")
+
+	for _, cmd := range []*cobra.Command{changefeedSkipDDLCmd, changefeedReplaceDDLCmd} {
+		cmd.Flags().Int64Var(&ddlJobID, "ddl-job-id", 0, "ID of the pending or failed DDL job, it's checked by the owner if it isn't 0")
+	}
+	changefeedReplaceDDLCmd.Flags().StringVar(&ddlQuery, "query", "", "the query executed instead of the DDL job")
+	_ = changefeedReplaceDDLCmd.MarkFlagRequired("query")
+
+	for _, cmd := range changefeedCmd.Commands() {
+		if cmd != changefeedCreateCmd && cmd != changefeedListCmd {
+			cmd.Flags().StringVar(&changefeedID, "changefeed-id", "", "ID of the changefeed")
+			_ = cmd.MarkFlagRequired("changefeed-id")
+		}
+	}
+}
+
+var (
+	changefeedID string
+	startTs      uint64
+	targetTs     uint64
+	sinkURI      string
+
+	configPath     string
+	enableOldValue bool
+
+	ddlJobID int64
+	ddlQuery string
+)
+
+// changefeedConfig is the content of the changefeed config file
+type changefeedConfig struct {
+	filter.Rules
+	EventFilters []*filter.EventRule `toml:"event-filters"`
+	RouteRules   []*router.Rule      `toml:"route-rules"`
+	ColumnRules  []*projection.Rule  `toml:"column-rules"`
+}
+
+type changefeed struct {
+	ID string `json:"id"`
+}
+
+// changefeedStatus is the output of `changefeed query`
+type changefeedStatus struct {
+	Detail     *model.ChangeFeedDetail `json:"detail"`
+	Status     *model.ChangeFeedInfo   `json:"status"`
+	Processors model.ProcessorsInfos   `json:"processors"`
+}
+
+var changefeedCmd = &cobra.Command{
+	Use:   "changefeed",
+	Short: "manage the changefeeds",
+}
+
+var changefeedCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "create a changefeed",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cli, err := newEtcdClient()
+		if err != nil {
+			return err
+		}
+		defer cli.Close()
+		ctx := context.Background()
+		id := changefeedID
+		if id == "" {
+			id = uuid.New().String()
+		} else if _, err := kv.GetChangeFeedDetail(ctx, cli, id); err == nil {
+			return errors.Errorf("changefeed %s already exists", id)
+		} else if errors.Cause(err) != model.ErrChangeFeedNotExists {
+			return err
+		}
+		detail := &model.ChangeFeedDetail{
+			SinkURI:    sinkURI,
+			Opts:       make(map[string]string),
+			CreateTime: time.Now(),
+			StartTs:    startTs,
+			TargetTs:   targetTs,
+		}
+		if err := applyChangefeedFlags(cmd, detail); err != nil {
+			return err
+		}
+		if err := kv.CheckStartTs(ctx, cli, detail.GetStartTs()); err != nil {
+			return err
+		}
+		if err := kv.SaveChangeFeedDetail(ctx, cli, detail, id); err != nil {
+			return err
+		}
+		return printJSON(&changefeed{ID: id})
+	},
+}
+
+var changefeedListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "list all changefeeds",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cli, err := newEtcdClient()
+		if err != nil {
+			return err
+		}
+		defer cli.Close()
+		_, details, err := kv.GetChangeFeeds(context.Background(), cli)
+		if err != nil {
+			return err
+		}
+		changefeeds := make([]*changefeed, 0, len(details))
+		for id := range details {
+			changefeeds = append(changefeeds, &changefeed{ID: id})
+		}
+		sort.Slice(changefeeds, func(i, j int) bool { return changefeeds[i].ID < changefeeds[j].ID })
+		return printJSON(changefeeds)
+	},
+}
+
+var changefeedQueryCmd = &cobra.Command{
+	Use:   "query",
+	Short: "query the detail, status and processors of a changefeed",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cli, err := newEtcdClient()
+		if err != nil {
+			return err
+		}
+		defer cli.Close()
+		ctx := context.Background()
+		detail, err := kv.GetChangeFeedDetail(ctx, cli, changefeedID)
+		if err != nil {
+			return err
+		}
+		// the status is missing until the owner starts the changefeed
+		info, err := kv.GetChangeFeedInfo(ctx, cli, changefeedID)
+		if err != nil && errors.Cause(err) != model.ErrChangeFeedNotExists {
+			return err
+		}
+		pinfos, err := kv.GetSubChangeFeedInfos(ctx, cli, changefeedID)
+		if err != nil {
+			return err
+		}
+		return printJSON(&changefeedStatus{Detail: detail, Status: info, Processors: pinfos})
+	},
+}
+
+// newChangefeedAdminCmd creates a command putting an admin job of the type,
+// which is applied by the owner asynchronously.
+func newChangefeedAdminCmd(use, short string, tp model.AdminJobType) *cobra.Command {
+	return &cobra.Command{
+		Use:   use,
+		Short: short,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cli, err := newEtcdClient()
+			if err != nil {
+				return err
+			}
+			defer cli.Close()
+			ctx := context.Background()
+			if _, err := kv.GetChangeFeedDetail(ctx, cli, changefeedID); err != nil {
+				return err
+			}
+			return kv.PutAdminJob(ctx, cli, &model.AdminJob{CfID: changefeedID, Type: tp})
+		},
+	}
+}
+
+var changefeedSkipDDLCmd = &cobra.Command{
+	Use:   "skip-ddl",
+	Short: "skip the pending or failed DDL job of a changefeed, the changefeed is resumed if it's stopped by the DDL job",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return putDDLOperation(&model.AdminJob{CfID: changefeedID, Type: model.AdminSkipDDL, DDLJobID: ddlJobID})
+	},
+}
+
+var changefeedReplaceDDLCmd = &cobra.Command{
+	Use:   "replace-ddl",
+	Short: "replace the query of the pending or failed DDL job of a changefeed, the changefeed is resumed if it's stopped by the DDL job",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return putDDLOperation(&model.AdminJob{CfID: changefeedID, Type: model.AdminReplaceDDL, DDLJobID: ddlJobID, Query: ddlQuery})
+	},
+}
+
+// putDDLOperation puts the admin job of a DDL operation, the result of the
+// operation is recorded in the DDL audit log by the owner.
+func putDDLOperation(job *model.AdminJob) error {
+	cli, err := newEtcdClient()
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+	ctx := context.Background()
+	if _, err := kv.GetChangeFeedDetail(ctx, cli, job.CfID); err != nil {
+		return err
+	}
+	return kv.PutAdminJob(ctx, cli, job)
+}
+
+var changefeedDDLAuditCmd = &cobra.Command{
+	Use:   "ddl-audit",
+	Short: "list the operations on the DDL jobs of a changefeed",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cli, err := newEtcdClient()
+		if err != nil {
+			return err
+		}
+		defer cli.Close()
+		records, err := kv.GetDDLAuditRecords(context.Background(), cli, changefeedID)
+		if err != nil {
+			return err
+		}
+		return printJSON(records)
+	},
+}
+
+var changefeedUpdateCmd = &cobra.Command{
+	Use:   "update",
+	Short: "update the config of a paused changefeed, it takes effect when the changefeed is resumed",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cli, err := newEtcdClient()
+		if err != nil {
+			return err
+		}
+		defer cli.Close()
+		ctx := context.Background()
+		detail, err := kv.GetChangeFeedDetail(ctx, cli, changefeedID)
+		if err != nil {
+			return err
+		}
+		info, err := kv.GetChangeFeedInfo(ctx, cli, changefeedID)
+		if err != nil && errors.Cause(err) != model.ErrChangeFeedNotExists {
+			return err
+		}
+		if info == nil || !info.IsStopped() {
+			return errors.Errorf("changefeed %s must be paused before updating", changefeedID)
+		}
+		if cmd.Flags().Changed("sink-uri") {
+			detail.SinkURI = sinkURI
+		}
+		if cmd.Flags().Changed("target-ts") {
+			detail.TargetTs = targetTs
+		}
+		if detail.Opts == nil {
+			detail.Opts = make(map[string]string)
+		}
+		if err := applyChangefeedFlags(cmd, detail); err != nil {
+			return err
+		}
+		return kv.SaveChangeFeedDetail(ctx, cli, detail, changefeedID)
+	},
+}
+
+// applyChangefeedFlags sets the rules and options of the detail from the
+// flags given to cmd, and validates the rules.
+func applyChangefeedFlags(cmd *cobra.Command, detail *model.ChangeFeedDetail) error {
+	if configPath != "" {
+		cfg := &changefeedConfig{}
+		if _, err := toml.DecodeFile(configPath, cfg); err != nil {
+			return errors.Annotatef(err, "decode config file %s", configPath)
+		}
+		detail.FilterRules = &cfg.Rules
+		detail.EventFilters = cfg.EventFilters
+		detail.RouteRules = cfg.RouteRules
+		detail.ColumnRules = cfg.ColumnRules
+	}
+	if cmd.Flags().Changed("enable-old-value") {
+		if enableOldValue {
+			detail.Opts["enable-old-value"] = "true"
+		} else {
+			delete(detail.Opts, "enable-old-value")
+		}
+	}
+	return detail.Validate()
+}
@@ -17,8 +17,9 @@ import (
 )
 
 var (
-	pdEndpoints string
-	statusAddr  string
+	pdEndpoints   string
+	statusAddr    string
+	advertiseAddr string
 
 	serverCmd = &cobra.Command{
 		Use:              "server",
@@ -34,6 +35,7 @@ func init() {
 
 	serverCmd.Flags().StringVar(&pdEndpoints, "pd-endpoints", "http://127.0.0.1:2379", "endpoints of PD, separated by comma")
 	serverCmd.Flags().StringVar(&statusAddr, "status-addr", "127.0.0.1:8300", "bind address for http status server")
+	serverCmd.Flags().StringVar(&advertiseAddr, "advertise-addr", "", "status server address advertised to other captures, status-addr is used if it's empty")
 }
 
 func preRunLogInfo(cmd *cobra.Command, args []string) {
@@ -51,7 +53,7 @@ func runEServer(cmd *cobra.Command, args []string) error {
 	}
 
 	var opts []cdc.ServerOption
-	opts = append(opts, cdc.PDEndpoints(pdEndpoints), cdc.StatusHost(addrs[0]), cdc.StatusPort(int(statusPort)))
+	opts = append(opts, cdc.PDEndpoints(pdEndpoints), cdc.StatusHost(addrs[0]), cdc.StatusPort(int(statusPort)), cdc.AdvertiseAddr(advertiseAddr))
 
 	server, err := cdc.NewServer(opts...)
 	if err != nil {
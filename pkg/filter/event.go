@@ -0,0 +1,111 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filter
+
+import (
+	"github.com/pingcap/errors"
+	timodel "github.com/pingcap/parser/model"
+	bf "github.com/pingcap/tidb-tools/pkg/binlog-filter"
+)
+
+// EventRule ignores or only keeps the events of the types in Events, or the
+// DDLs whose queries match SQLPattern, in the schemas and tables matched by
+// SchemaPattern and TablePattern, which support wildcards like `archive_*`.
+type EventRule = bf.BinlogEventRule
+
+// EventType is the type of a DML or DDL event in EventRule
+type EventType = bf.EventType
+
+// The DML event types
+const (
+	InsertEvent = bf.InsertEvent
+	UpdateEvent = bf.UpdateEvent
+	DeleteEvent = bf.DeleteEvent
+)
+
+func newEventFilter(rules []*EventRule) (*bf.BinlogEvent, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+	// the rules are lowercased when they're added, so copy them to keep the config unchanged
+	copied := make([]*EventRule, 0, len(rules))
+	for _, rule := range rules {
+		if rule == nil {
+			continue
+		}
+		for _, event := range rule.Events {
+			switch event {
+			case bf.AllEvent, bf.AllDDL, bf.AllDML, bf.NoneEvent, bf.NoneDDL, bf.NoneDML:
+				continue
+			}
+			if _, err := bf.ClassifyEvent(event); err != nil || event == bf.NullEvent {
+				return nil, errors.Errorf("invalid event type %q in event filter", event)
+			}
+		}
+		r := *rule
+		copied = append(copied, &r)
+	}
+	f, err := bf.NewBinlogEvent(false, copied)
+	return f, errors.Trace(err)
+}
+
+// ShouldIgnoreDMLEvent returns true if the DML of the event type in the table
+// should not be replicated.
+func (f *Filter) ShouldIgnoreDMLEvent(schema, table string, event EventType) bool {
+	if f == nil || f.events == nil {
+		return false
+	}
+	action, err := f.events.Filter(schema, table, event, "")
+	return err == nil && action == bf.Ignore
+}
+
+// ShouldIgnoreDDLEvent returns true if the DDL job on the table should not be
+// executed in the downstream.
+func (f *Filter) ShouldIgnoreDDLEvent(schema, table string, job *timodel.Job) bool {
+	if f == nil || f.events == nil {
+		return false
+	}
+	action, err := f.events.Filter(schema, table, ddlEventType(job.Type), job.Query)
+	return err == nil && action == bf.Ignore
+}
+
+// ddlEventType returns the event type of the DDL action, or NullEvent if the
+// action has no event type, then only the SQL patterns are matched.
+func ddlEventType(tp timodel.ActionType) EventType {
+	switch tp {
+	case timodel.ActionCreateSchema:
+		return bf.CreateDatabase
+	case timodel.ActionDropSchema:
+		return bf.DropDatabase
+	case timodel.ActionCreateTable:
+		return bf.CreateTable
+	case timodel.ActionDropTable:
+		return bf.DropTable
+	case timodel.ActionTruncateTable:
+		return bf.TruncateTable
+	case timodel.ActionRenameTable:
+		return bf.RenameTable
+	case timodel.ActionAddIndex:
+		return bf.CreateIndex
+	case timodel.ActionDropIndex:
+		return bf.DropIndex
+	case timodel.ActionAddColumn, timodel.ActionDropColumn, timodel.ActionModifyColumn,
+		timodel.ActionSetDefaultValue, timodel.ActionAddForeignKey, timodel.ActionDropForeignKey,
+		timodel.ActionRebaseAutoID, timodel.ActionShardRowID, timodel.ActionModifyTableComment,
+		timodel.ActionRenameIndex, timodel.ActionAddTablePartition, timodel.ActionDropTablePartition,
+		timodel.ActionTruncateTablePartition, timodel.ActionModifyTableCharsetAndCollate:
+		return bf.AlertTable
+	}
+	return bf.NullEvent
+}
@@ -0,0 +1,81 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filter
+
+import (
+	"github.com/pingcap/check"
+	timodel "github.com/pingcap/parser/model"
+	bf "github.com/pingcap/tidb-tools/pkg/binlog-filter"
+)
+
+type eventSuite struct{}
+
+var _ = check.Suite(&eventSuite{})
+
+func (s *eventSuite) TestDMLEvents(c *check.C) {
+	rules := []*EventRule{{
+		SchemaPattern: "Archive*",
+		TablePattern:  "*",
+		Events:        []EventType{DeleteEvent},
+		Action:        bf.Ignore,
+	}}
+	f, err := New(nil, rules)
+	c.Assert(err, check.IsNil)
+	// the config isn't changed by the filter
+	c.Assert(rules[0].SchemaPattern, check.Equals, "Archive*")
+
+	c.Assert(f.ShouldIgnoreDMLEvent("archive_2019", "t", DeleteEvent), check.IsTrue)
+	c.Assert(f.ShouldIgnoreDMLEvent("ARCHIVE", "t", DeleteEvent), check.IsTrue)
+	c.Assert(f.ShouldIgnoreDMLEvent("archive", "t", InsertEvent), check.IsFalse)
+	c.Assert(f.ShouldIgnoreDMLEvent("test", "t", DeleteEvent), check.IsFalse)
+
+	var nilFilter *Filter
+	c.Assert(nilFilter.ShouldIgnoreDMLEvent("archive", "t", DeleteEvent), check.IsFalse)
+}
+
+func (s *eventSuite) TestDDLEvents(c *check.C) {
+	f, err := New(nil, []*EventRule{
+		{
+			SchemaPattern: "*",
+			Events:        []EventType{bf.DropDatabase},
+			Action:        bf.Ignore,
+		},
+		{
+			SchemaPattern: "*",
+			TablePattern:  "*",
+			Events:        []EventType{bf.TruncateTable},
+			SQLPattern:    []string{"^ALTER TABLE .* DROP COLUMN"},
+			Action:        bf.Ignore,
+		},
+	})
+	c.Assert(err, check.IsNil)
+
+	job := &timodel.Job{Type: timodel.ActionDropSchema, Query: "DROP DATABASE test"}
+	c.Assert(f.ShouldIgnoreDDLEvent("test", "", job), check.IsTrue)
+	job = &timodel.Job{Type: timodel.ActionCreateSchema, Query: "CREATE DATABASE test"}
+	c.Assert(f.ShouldIgnoreDDLEvent("test", "", job), check.IsFalse)
+	job = &timodel.Job{Type: timodel.ActionTruncateTable, Query: "TRUNCATE TABLE t"}
+	c.Assert(f.ShouldIgnoreDDLEvent("test", "t", job), check.IsTrue)
+	job = &timodel.Job{Type: timodel.ActionDropColumn, Query: "alter table t drop column a"}
+	c.Assert(f.ShouldIgnoreDDLEvent("test", "t", job), check.IsTrue)
+	job = &timodel.Job{Type: timodel.ActionAddColumn, Query: "ALTER TABLE t ADD COLUMN a int"}
+	c.Assert(f.ShouldIgnoreDDLEvent("test", "t", job), check.IsFalse)
+}
+
+func (s *eventSuite) TestInvalidEventRules(c *check.C) {
+	_, err := New(nil, []*EventRule{{SchemaPattern: "*", Events: []EventType{"drop everything"}, Action: bf.Ignore}})
+	c.Assert(err, check.ErrorMatches, "invalid event type.*")
+	_, err = New(nil, []*EventRule{{SchemaPattern: "*", Events: []EventType{DeleteEvent}}})
+	c.Assert(err, check.NotNil)
+}
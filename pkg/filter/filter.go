@@ -0,0 +1,145 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filter
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/pingcap/errors"
+	bf "github.com/pingcap/tidb-tools/pkg/binlog-filter"
+	tfilter "github.com/pingcap/tidb-tools/pkg/filter"
+)
+
+// Rules is the do/ignore rules of schemas and tables. A name is matched as a
+// regular expression if it starts with `~`, otherwise `*` matches any sequence
+// of characters and `?` matches any single character.
+type Rules = tfilter.Rules
+
+// Table is a table or schema pattern in the rules
+type Table = tfilter.Table
+
+var sysSchemas = []string{"INFORMATION_SCHEMA", "PERFORMANCE_SCHEMA", "mysql"}
+
+// Filter decides whether the changes of a schema or table should be replicated.
+// The system schemas are always ignored, a nil *Filter only ignores them.
+type Filter struct {
+	filter *tfilter.Filter
+	events *bf.BinlogEvent
+}
+
+// New creates a Filter from the table rules and the event rules, names are
+// matched case insensitively.
+func New(rules *Rules, eventRules []*EventRule) (*Filter, error) {
+	events, err := newEventFilter(eventRules)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if rules == nil {
+		return &Filter{events: events}, nil
+	}
+	r := &Rules{}
+	if r.DoDBs, err = convertPatterns(rules.DoDBs); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if r.IgnoreDBs, err = convertPatterns(rules.IgnoreDBs); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if r.DoTables, err = convertTables(rules.DoTables); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if r.IgnoreTables, err = convertTables(rules.IgnoreTables); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return &Filter{filter: tfilter.New(false, r), events: events}, nil
+}
+
+// ShouldIgnoreSchema returns true if the changes of the schema itself, like
+// creating or dropping the schema, should not be replicated.
+func (f *Filter) ShouldIgnoreSchema(schema string) bool {
+	return f.ShouldIgnoreTable(schema, "")
+}
+
+// ShouldIgnoreTable returns true if the changes of the table should not be replicated.
+func (f *Filter) ShouldIgnoreTable(schema, table string) bool {
+	for _, sys := range sysSchemas {
+		if strings.EqualFold(sys, schema) {
+			return true
+		}
+	}
+	if f == nil || f.filter == nil {
+		return false
+	}
+	return len(f.filter.ApplyOn([]*Table{{Schema: schema, Name: table}})) == 0
+}
+
+func convertTables(tables []*Table) ([]*Table, error) {
+	converted := make([]*Table, 0, len(tables))
+	for _, t := range tables {
+		if t == nil {
+			continue
+		}
+		schema, err := convertPattern(t.Schema)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		name, err := convertPattern(t.Name)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		converted = append(converted, &Table{Schema: schema, Name: name})
+	}
+	return converted, nil
+}
+
+func convertPatterns(patterns []string) ([]string, error) {
+	converted := make([]string, 0, len(patterns))
+	for _, p := range patterns {
+		c, err := convertPattern(p)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		converted = append(converted, c)
+	}
+	return converted, nil
+}
+
+// convertPattern converts a wildcard pattern to a regular expression pattern
+// of tidb-tools filter, and validates the regular expressions, as tidb-tools
+// filter panics on invalid ones.
+func convertPattern(pattern string) (string, error) {
+	if strings.HasPrefix(pattern, "~") {
+		if _, err := regexp.Compile(pattern[1:]); err != nil {
+			return "", errors.Annotatef(err, "invalid filter pattern %s", pattern)
+		}
+		return pattern, nil
+	}
+	if !strings.ContainsAny(pattern, "*?") {
+		return pattern, nil
+	}
+	var b strings.Builder
+	b.WriteString("~^")
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return b.String(), nil
+}
@@ -0,0 +1,84 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filter
+
+import (
+	"testing"
+
+	"github.com/pingcap/check"
+)
+
+func Test(t *testing.T) { check.TestingT(t) }
+
+type filterSuite struct{}
+
+var _ = check.Suite(&filterSuite{})
+
+func (s *filterSuite) TestSysSchemas(c *check.C) {
+	var nilFilter *Filter
+	f, err := New(nil, nil)
+	c.Assert(err, check.IsNil)
+	for _, f := range []*Filter{nilFilter, f} {
+		c.Assert(f.ShouldIgnoreTable("INFORMATION_SCHEMA", "t"), check.IsTrue)
+		c.Assert(f.ShouldIgnoreSchema("performance_schema"), check.IsTrue)
+		c.Assert(f.ShouldIgnoreTable("MYSQL", "user"), check.IsTrue)
+		c.Assert(f.ShouldIgnoreTable("test_mysql", "t"), check.IsFalse)
+		c.Assert(f.ShouldIgnoreSchema("test"), check.IsFalse)
+	}
+}
+
+func (s *filterSuite) TestDoRules(c *check.C) {
+	f, err := New(&Rules{
+		DoDBs: []string{"db1", "~^db2_[0-9]+$"},
+	}, nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(f.ShouldIgnoreTable("db1", "a"), check.IsFalse)
+	c.Assert(f.ShouldIgnoreTable("DB1", "a"), check.IsFalse)
+	c.Assert(f.ShouldIgnoreTable("db2_10", "a"), check.IsFalse)
+	c.Assert(f.ShouldIgnoreTable("db2_x", "a"), check.IsTrue)
+	c.Assert(f.ShouldIgnoreSchema("db3"), check.IsTrue)
+
+	f, err = New(&Rules{
+		DoTables: []*Table{
+			{Schema: "db3", Name: "t*"},
+			{Schema: "db?", Name: "log"},
+		},
+	}, nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(f.ShouldIgnoreTable("db3", "t1"), check.IsFalse)
+	c.Assert(f.ShouldIgnoreTable("db3", "a"), check.IsTrue)
+	c.Assert(f.ShouldIgnoreTable("db4", "log"), check.IsFalse)
+	c.Assert(f.ShouldIgnoreTable("db10", "log"), check.IsTrue)
+	c.Assert(f.ShouldIgnoreSchema("db10"), check.IsFalse)
+}
+
+func (s *filterSuite) TestIgnoreRules(c *check.C) {
+	f, err := New(&Rules{
+		IgnoreDBs:    []string{"tmp*"},
+		IgnoreTables: []*Table{{Schema: "~.*", Name: "~^_.*_gho$"}},
+	}, nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(f.ShouldIgnoreSchema("tmp1"), check.IsTrue)
+	c.Assert(f.ShouldIgnoreTable("tmp1", "t"), check.IsTrue)
+	c.Assert(f.ShouldIgnoreTable("test", "_t_gho"), check.IsTrue)
+	c.Assert(f.ShouldIgnoreTable("test", "t"), check.IsFalse)
+	c.Assert(f.ShouldIgnoreTable("test.1", "t"), check.IsFalse)
+}
+
+func (s *filterSuite) TestInvalidRules(c *check.C) {
+	_, err := New(&Rules{DoDBs: []string{"~("}}, nil)
+	c.Assert(err, check.ErrorMatches, "invalid filter pattern.*")
+	_, err = New(&Rules{IgnoreTables: []*Table{{Schema: "test", Name: "~[a-"}}}, nil)
+	c.Assert(err, check.ErrorMatches, "invalid filter pattern.*")
+}
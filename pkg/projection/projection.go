@@ -0,0 +1,244 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package projection
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"github.com/pingcap/errors"
+	timodel "github.com/pingcap/parser/model"
+	"github.com/pingcap/parser/mysql"
+	selector "github.com/pingcap/tidb-tools/pkg/table-rule-selector"
+	"github.com/pingcap/tidb/types"
+)
+
+// Transform is the way to change the value of a column
+type Transform string
+
+// The supported transforms, the hashed and masked values are strings.
+const (
+	// TransformHash replaces the value with the hex encoded SHA-256 of it
+	TransformHash Transform = "hash"
+	// TransformMask replaces all but the last 4 characters of the value with `*`
+	TransformMask Transform = "mask"
+	// TransformNull replaces the value with NULL
+	TransformNull Transform = "null"
+)
+
+// maskKeep is the number of trailing characters kept by TransformMask
+const maskKeep = 4
+
+// hashLength is the length of the values transformed by TransformHash
+const hashLength = sha256.Size * 2
+
+// Rule projects and transforms the columns of the tables matched by
+// SchemaPattern and TablePattern, which support wildcards like `user_*`.
+// Column names are matched case insensitively. The rules are checked against
+// the tables by CheckTable.
+type Rule struct {
+	SchemaPattern string `toml:"schema-pattern" json:"schema-pattern"`
+	TablePattern  string `toml:"table-pattern" json:"table-pattern"`
+	// Include keeps only the listed columns, all columns are kept if it's empty
+	Include []string `toml:"include" json:"include"`
+	// Exclude drops the listed columns
+	Exclude []string `toml:"exclude" json:"exclude"`
+	// Transforms maps the column names to the transforms of their values
+	Transforms map[string]Transform `toml:"transforms" json:"transforms"`
+}
+
+type compiledRule struct {
+	// index is the position of the rule in the config, the first matched rule is used
+	index      int
+	include    map[string]struct{}
+	exclude    map[string]struct{}
+	transforms map[string]Transform
+}
+
+// Projector applies the column rules to the row values, a nil *Projector
+// keeps all values unchanged.
+type Projector struct {
+	selector selector.Selector
+}
+
+// New creates a Projector from the rules
+func New(rules []*Rule) (*Projector, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+	p := &Projector{selector: selector.NewTrieSelector()}
+	for i, rule := range rules {
+		if rule == nil {
+			continue
+		}
+		if rule.SchemaPattern == "" || rule.TablePattern == "" {
+			return nil, errors.Errorf("invalid column rule %+v: schema and table patterns are required", rule)
+		}
+		r := &compiledRule{
+			index:      i,
+			include:    columnSet(rule.Include),
+			exclude:    columnSet(rule.Exclude),
+			transforms: make(map[string]Transform, len(rule.Transforms)),
+		}
+		for col, t := range rule.Transforms {
+			switch t {
+			case TransformHash, TransformMask, TransformNull:
+			default:
+				return nil, errors.Errorf("invalid transform %q of column %s in column rule", t, col)
+			}
+			r.transforms[strings.ToLower(col)] = t
+		}
+		err := p.selector.Insert(strings.ToLower(rule.SchemaPattern), strings.ToLower(rule.TablePattern), r, false)
+		if err != nil {
+			return nil, errors.Annotate(err, "invalid column rules")
+		}
+	}
+	return p, nil
+}
+
+// Project removes the dropped columns from the values of a row in the table,
+// and transforms the values of the other columns in place.
+func (p *Projector) Project(schema, table string, values map[string]types.Datum) error {
+	if p == nil || values == nil {
+		return nil
+	}
+	rule := p.match(schema, table)
+	if rule == nil {
+		return nil
+	}
+	for name, value := range values {
+		col := strings.ToLower(name)
+		if rule.dropped(col) {
+			delete(values, name)
+			continue
+		}
+		t, ok := rule.transforms[col]
+		if !ok {
+			continue
+		}
+		transformed, err := transform(t, value)
+		if err != nil {
+			return errors.Annotatef(err, "transform column %s of %s.%s", name, schema, table)
+		}
+		values[name] = transformed
+	}
+	return nil
+}
+
+// CheckTable checks the rule matching the table against its columns. The hashed
+// and masked columns must be strings, and the primary key and unique key
+// columns, which locate the rows downstream, must not be dropped or nulled out.
+func (p *Projector) CheckTable(schema string, table *timodel.TableInfo) error {
+	if p == nil {
+		return nil
+	}
+	rule := p.match(schema, table.Name.O)
+	if rule == nil {
+		return nil
+	}
+	keys := keyColumns(table)
+	for _, col := range table.Columns {
+		name := col.Name.L
+		if _, ok := keys[name]; ok {
+			if rule.dropped(name) {
+				return errors.Errorf("key column %s of %s.%s can't be dropped by column rules", col.Name, schema, table.Name)
+			}
+			if rule.transforms[name] == TransformNull {
+				return errors.Errorf("key column %s of %s.%s can't be transformed to null", col.Name, schema, table.Name)
+			}
+		}
+		switch t := rule.transforms[name]; t {
+		case TransformHash, TransformMask:
+			if !types.IsString(col.Tp) {
+				return errors.Errorf("column %s of %s.%s can't be transformed by %s, it's not a string", col.Name, schema, table.Name, t)
+			}
+			if t == TransformHash && col.Flen != types.UnspecifiedLength && col.Flen < hashLength {
+				return errors.Errorf("column %s of %s.%s can't be transformed by hash, it's shorter than %d", col.Name, schema, table.Name, hashLength)
+			}
+		}
+	}
+	return nil
+}
+
+// keyColumns returns the lower case names of the primary key and unique key columns
+func keyColumns(table *timodel.TableInfo) map[string]struct{} {
+	keys := make(map[string]struct{})
+	if table.PKIsHandle {
+		for _, col := range table.Columns {
+			if mysql.HasPriKeyFlag(col.Flag) {
+				keys[col.Name.L] = struct{}{}
+			}
+		}
+	}
+	for _, index := range table.Indices {
+		if !index.Primary && !index.Unique {
+			continue
+		}
+		for _, col := range index.Columns {
+			keys[col.Name.L] = struct{}{}
+		}
+	}
+	return keys
+}
+
+func (p *Projector) match(schema, table string) *compiledRule {
+	var matched *compiledRule
+	for _, r := range p.selector.Match(strings.ToLower(schema), strings.ToLower(table)) {
+		rule, ok := r.(*compiledRule)
+		if ok && (matched == nil || rule.index < matched.index) {
+			matched = rule
+		}
+	}
+	return matched
+}
+
+func transform(t Transform, value types.Datum) (types.Datum, error) {
+	if t == TransformNull || value.IsNull() {
+		return types.NewDatum(nil), nil
+	}
+	s, err := value.ToString()
+	if err != nil {
+		return types.Datum{}, errors.Trace(err)
+	}
+	switch t {
+	case TransformHash:
+		sum := sha256.Sum256([]byte(s))
+		return types.NewStringDatum(hex.EncodeToString(sum[:])), nil
+	case TransformMask:
+		chars := []rune(s)
+		for i := 0; i < len(chars)-maskKeep; i++ {
+			chars[i] = '*'
+		}
+		return types.NewStringDatum(string(chars)), nil
+	}
+	return value, nil
+}
+
+// dropped returns whether the column with the lower case name is dropped
+func (r *compiledRule) dropped(col string) bool {
+	if _, ok := r.exclude[col]; ok {
+		return true
+	}
+	_, ok := r.include[col]
+	return !ok && len(r.include) > 0
+}
+
+func columnSet(columns []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(columns))
+	for _, col := range columns {
+		set[strings.ToLower(col)] = struct{}{}
+	}
+	return set
+}
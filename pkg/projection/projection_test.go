@@ -0,0 +1,158 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package projection
+
+import (
+	"testing"
+
+	"github.com/pingcap/check"
+	timodel "github.com/pingcap/parser/model"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/tidb/types"
+)
+
+func Test(t *testing.T) { check.TestingT(t) }
+
+type projectionSuite struct{}
+
+var _ = check.Suite(&projectionSuite{})
+
+func newRow() map[string]types.Datum {
+	return map[string]types.Datum{
+		"id":    types.NewIntDatum(1),
+		"Name":  types.NewStringDatum("alice"),
+		"email": types.NewStringDatum("alice@example.com"),
+		"phone": types.NewStringDatum("13800001234"),
+		"ssn":   types.NewStringDatum("123-45-6789"),
+		"note":  types.NewDatum(nil),
+	}
+}
+
+func (s *projectionSuite) TestProject(c *check.C) {
+	p, err := New([]*Rule{
+		{
+			SchemaPattern: "crm",
+			TablePattern:  "user*",
+			Exclude:       []string{"SSN"},
+			Transforms: map[string]Transform{
+				"email": TransformHash,
+				"phone": TransformMask,
+				"name":  TransformNull,
+				"note":  TransformHash,
+			},
+		},
+		{SchemaPattern: "crm", TablePattern: "users_*", Include: []string{"id", "name"}},
+	})
+	c.Assert(err, check.IsNil)
+
+	row := newRow()
+	c.Assert(p.Project("CRM", "users", row), check.IsNil)
+	c.Assert(row, check.DeepEquals, map[string]types.Datum{
+		"id":    types.NewIntDatum(1),
+		"Name":  types.NewDatum(nil),
+		"email": types.NewStringDatum("ff8d9819fc0e12bf0d24892e45987e249a28dce836a85cad60e28eaaa8c6d976"),
+		"phone": types.NewStringDatum("*******1234"),
+		"note":  types.NewDatum(nil),
+	})
+
+	// the first matched rule is used
+	row = newRow()
+	c.Assert(p.Project("crm", "users_2019", row), check.IsNil)
+	c.Assert(row, check.HasLen, 5)
+	c.Assert(row["phone"], check.DeepEquals, types.NewStringDatum("*******1234"))
+
+	row = newRow()
+	c.Assert(p.Project("test", "users", row), check.IsNil)
+	c.Assert(row, check.DeepEquals, newRow())
+
+	var nilProjector *Projector
+	row = newRow()
+	c.Assert(nilProjector.Project("crm", "users", row), check.IsNil)
+	c.Assert(row, check.DeepEquals, newRow())
+}
+
+func (s *projectionSuite) TestInclude(c *check.C) {
+	p, err := New([]*Rule{{SchemaPattern: "crm", TablePattern: "users", Include: []string{"ID", "name", "phone"}, Exclude: []string{"phone"}}})
+	c.Assert(err, check.IsNil)
+	row := newRow()
+	c.Assert(p.Project("crm", "users", row), check.IsNil)
+	c.Assert(row, check.DeepEquals, map[string]types.Datum{
+		"id":   types.NewIntDatum(1),
+		"Name": types.NewStringDatum("alice"),
+	})
+}
+
+func (s *projectionSuite) TestInvalidRules(c *check.C) {
+	p, err := New(nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(p, check.IsNil)
+	_, err = New([]*Rule{{SchemaPattern: "crm", TablePattern: "users", Transforms: map[string]Transform{"email": "encrypt"}}})
+	c.Assert(err, check.ErrorMatches, "invalid transform.*")
+	_, err = New([]*Rule{{SchemaPattern: "crm"}})
+	c.Assert(err, check.ErrorMatches, "invalid column rule.*")
+	_, err = New([]*Rule{{SchemaPattern: "crm", TablePattern: "users"}, {SchemaPattern: "CRM", TablePattern: "Users"}})
+	c.Assert(err, check.ErrorMatches, "invalid column rules.*")
+}
+
+func (s *projectionSuite) TestCheckTable(c *check.C) {
+	newColumn := func(name string, tp byte, flen int, flag uint) *timodel.ColumnInfo {
+		col := &timodel.ColumnInfo{Name: timodel.NewCIStr(name)}
+		col.Tp, col.Flen, col.Flag = tp, flen, flag
+		return col
+	}
+	table := &timodel.TableInfo{
+		Name:       timodel.NewCIStr("users"),
+		PKIsHandle: true,
+		Columns: []*timodel.ColumnInfo{
+			newColumn("id", mysql.TypeLonglong, 20, mysql.PriKeyFlag),
+			newColumn("email", mysql.TypeVarchar, 255, mysql.UniqueKeyFlag),
+			newColumn("phone", mysql.TypeVarchar, 20, 0),
+			newColumn("status", mysql.TypeEnum, types.UnspecifiedLength, 0),
+			newColumn("score", mysql.TypeLong, 11, 0),
+			newColumn("note", mysql.TypeBlob, types.UnspecifiedLength, 0),
+		},
+		Indices: []*timodel.IndexInfo{{
+			Name:    timodel.NewCIStr("email"),
+			Unique:  true,
+			Columns: []*timodel.IndexColumn{{Name: timodel.NewCIStr("email")}},
+		}},
+	}
+	checkRule := func(rule *Rule) error {
+		rule.SchemaPattern, rule.TablePattern = "crm", "users"
+		p, err := New([]*Rule{rule})
+		c.Assert(err, check.IsNil)
+		return p.CheckTable("crm", table)
+	}
+
+	c.Assert(checkRule(&Rule{
+		Exclude:    []string{"score"},
+		Transforms: map[string]Transform{"email": TransformHash, "phone": TransformMask, "note": TransformHash, "status": TransformNull},
+	}), check.IsNil)
+	// the rules of other tables are not checked
+	p, err := New([]*Rule{{SchemaPattern: "crm", TablePattern: "orders", Exclude: []string{"id"}}})
+	c.Assert(err, check.IsNil)
+	c.Assert(p.CheckTable("crm", table), check.IsNil)
+	var nilProjector *Projector
+	c.Assert(nilProjector.CheckTable("crm", table), check.IsNil)
+
+	// the transformed values don't fit the column types
+	c.Assert(checkRule(&Rule{Transforms: map[string]Transform{"status": TransformMask}}), check.ErrorMatches, ".*status.*not a string")
+	c.Assert(checkRule(&Rule{Transforms: map[string]Transform{"score": TransformHash}}), check.ErrorMatches, ".*score.*not a string")
+	c.Assert(checkRule(&Rule{Transforms: map[string]Transform{"Phone": TransformHash}}), check.ErrorMatches, ".*phone.*shorter than 64")
+
+	// the key columns locate the rows downstream
+	c.Assert(checkRule(&Rule{Exclude: []string{"ID"}}), check.ErrorMatches, "key column id.*dropped.*")
+	c.Assert(checkRule(&Rule{Include: []string{"id", "phone"}}), check.ErrorMatches, "key column email.*dropped.*")
+	c.Assert(checkRule(&Rule{Transforms: map[string]Transform{"email": TransformNull}}), check.ErrorMatches, "key column email.*null")
+}
@@ -0,0 +1,133 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package router
+
+import (
+	"strings"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser"
+	"github.com/pingcap/parser/ast"
+	"github.com/pingcap/parser/format"
+	"github.com/pingcap/parser/model"
+	tr "github.com/pingcap/tidb-tools/pkg/table-router"
+	_ "github.com/pingcap/tidb/types/parser_driver" // parser driver
+)
+
+// Rule routes the schemas and tables matched by SchemaPattern and TablePattern,
+// which support wildcards like `shard_*`, to TargetSchema and TargetTable.
+// A rule without TablePattern routes a schema, and keeps the table names.
+type Rule = tr.TableRule
+
+// Router maps the upstream schema and table names to the downstream ones,
+// a nil *Router keeps all names.
+type Router struct {
+	router *tr.Table
+}
+
+// New creates a Router from the rules, names are matched case insensitively.
+func New(rules []*Rule) (*Router, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+	// the rules are lowercased when they're added, so copy them to keep the config unchanged
+	copied := make([]*Rule, 0, len(rules))
+	for _, rule := range rules {
+		if rule != nil {
+			r := *rule
+			copied = append(copied, &r)
+		}
+	}
+	router, err := tr.NewTableRouter(false, copied)
+	if err != nil {
+		return nil, errors.Annotate(err, "invalid route rules")
+	}
+	return &Router{router: router}, nil
+}
+
+// Route returns the downstream schema and table of the upstream table, the
+// table is empty when routing a schema.
+func (r *Router) Route(schema, table string) (string, string, error) {
+	if r == nil {
+		return schema, table, nil
+	}
+	targetSchema, targetTable, err := r.router.Route(schema, table)
+	return targetSchema, targetTable, errors.Trace(err)
+}
+
+// RouteDDL rewrites the schema and table names in the DDL query to the
+// downstream ones, the tables without a schema in the query are in defaultSchema.
+func (r *Router) RouteDDL(query, defaultSchema string) (string, error) {
+	if r == nil {
+		return query, nil
+	}
+	stmt, err := parser.New().ParseOneStmt(query, "", "")
+	if err != nil {
+		return "", errors.Annotatef(err, "parse DDL %s", query)
+	}
+	switch s := stmt.(type) {
+	case *ast.CreateDatabaseStmt:
+		s.Name, _, err = r.Route(s.Name, "")
+	case *ast.AlterDatabaseStmt:
+		if s.Name == "" {
+			s.Name = defaultSchema
+		}
+		s.Name, _, err = r.Route(s.Name, "")
+	case *ast.DropDatabaseStmt:
+		s.Name, _, err = r.Route(s.Name, "")
+	default:
+		v := &tableNameRouter{router: r, defaultSchema: defaultSchema}
+		stmt.Accept(v)
+		err = v.err
+	}
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+
+	var b strings.Builder
+	if err := stmt.Restore(format.NewRestoreCtx(format.DefaultRestoreFlags, &b)); err != nil {
+		return "", errors.Annotatef(err, "restore DDL %s", query)
+	}
+	return b.String(), nil
+}
+
+// tableNameRouter routes all table names in the visited statement
+type tableNameRouter struct {
+	router        *Router
+	defaultSchema string
+	err           error
+}
+
+func (v *tableNameRouter) Enter(in ast.Node) (ast.Node, bool) {
+	t, ok := in.(*ast.TableName)
+	if !ok || v.err != nil {
+		return in, v.err != nil
+	}
+	schema := t.Schema.O
+	if schema == "" {
+		schema = v.defaultSchema
+	}
+	targetSchema, targetTable, err := v.router.Route(schema, t.Name.O)
+	if err != nil {
+		v.err = err
+		return in, true
+	}
+	t.Schema = model.NewCIStr(targetSchema)
+	t.Name = model.NewCIStr(targetTable)
+	return in, true
+}
+
+func (v *tableNameRouter) Leave(in ast.Node) (ast.Node, bool) {
+	return in, true
+}
@@ -0,0 +1,88 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package router
+
+import (
+	"testing"
+
+	"github.com/pingcap/check"
+)
+
+func Test(t *testing.T) { check.TestingT(t) }
+
+type routerSuite struct{}
+
+var _ = check.Suite(&routerSuite{})
+
+func newTestRouter(c *check.C) *Router {
+	r, err := New([]*Rule{
+		{SchemaPattern: "shard_*", TargetSchema: "merged"},
+		{SchemaPattern: "shard_*", TablePattern: "order_*", TargetSchema: "merged", TargetTable: "orders"},
+	})
+	c.Assert(err, check.IsNil)
+	return r
+}
+
+func (s *routerSuite) TestRoute(c *check.C) {
+	r := newTestRouter(c)
+	for _, tc := range []struct {
+		schema, table             string
+		targetSchema, targetTable string
+	}{
+		{"shard_1", "order_1", "merged", "orders"},
+		{"SHARD_2", "ORDER_2", "merged", "orders"},
+		{"shard_1", "user", "merged", "user"},
+		{"shard_1", "", "merged", ""},
+		{"test", "order_1", "test", "order_1"},
+	} {
+		schema, table, err := r.Route(tc.schema, tc.table)
+		c.Assert(err, check.IsNil)
+		c.Assert(schema, check.Equals, tc.targetSchema)
+		c.Assert(table, check.Equals, tc.targetTable)
+	}
+
+	var nilRouter *Router
+	schema, table, err := nilRouter.Route("shard_1", "order_1")
+	c.Assert(err, check.IsNil)
+	c.Assert(schema, check.Equals, "shard_1")
+	c.Assert(table, check.Equals, "order_1")
+
+	_, err = New([]*Rule{{TablePattern: "t"}})
+	c.Assert(err, check.ErrorMatches, "invalid route rules.*")
+}
+
+func (s *routerSuite) TestRouteDDL(c *check.C) {
+	r := newTestRouter(c)
+	for _, tc := range [][]string{
+		{"create database shard_1", "CREATE DATABASE `merged`"},
+		{"drop database if exists shard_1", "DROP DATABASE IF EXISTS `merged`"},
+		{"create table order_1 (id int primary key)", "CREATE TABLE `merged`.`orders` (`id` INT PRIMARY KEY)"},
+		{"alter table shard_1.order_1 add column a int", "ALTER TABLE `merged`.`orders` ADD COLUMN `a` INT"},
+		{"rename table order_1 to user", "RENAME TABLE `merged`.`orders` TO `merged`.`user`"},
+		{"truncate table test.order_1", "TRUNCATE TABLE `test`.`order_1`"},
+		{"create index idx on order_1 (id)", "CREATE INDEX `idx` ON `merged`.`orders` (`id`)"},
+	} {
+		query, err := r.RouteDDL(tc[0], "shard_1")
+		c.Assert(err, check.IsNil)
+		c.Assert(query, check.Equals, tc[1])
+	}
+
+	var nilRouter *Router
+	query, err := nilRouter.RouteDDL("create table t (id int)", "test")
+	c.Assert(err, check.IsNil)
+	c.Assert(query, check.Equals, "create table t (id int)")
+
+	_, err = r.RouteDDL("create tabl t", "test")
+	c.Assert(err, check.ErrorMatches, "parse DDL.*")
+}
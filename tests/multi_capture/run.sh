@@ -32,7 +32,7 @@ function run() {
     for i in $(seq $CDC_COUNT); do
         cdc server --log-file $WORK_DIR/cdc${i}.log --log-level info > $WORK_DIR/stdout${i}.log 2>&1 &
     done
-    cdc cli --start-ts=$start_ts
+    cdc cli changefeed create --start-ts=$start_ts --sink-uri="root@tcp(127.0.0.1:3306)/test"
 
     # check tables are created and data is synchronized
     for i in $(seq $DB_COUNT); do
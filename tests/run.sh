@@ -21,7 +21,7 @@ if [ "${1-}" = '--debug' ]; then
 
     cdc server --log-file $WORK_DIR/cdc.log --log-level debug > $WORK_DIR/cdc.log 2>&1 &
     sleep 1
-    cdc cli
+    cdc cli changefeed create --sink-uri="root@tcp(127.0.0.1:3306)/test"
 
     echo 'You may now debug from another terminal. Press [ENTER] to continue.'
     read line
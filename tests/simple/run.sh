@@ -22,7 +22,7 @@ function prepare() {
     run_sql "CREATE table test.simple2(id int primary key, val int);"
 
     cdc server --log-file $WORK_DIR/cdc.log --log-level info > $WORK_DIR/stdout.log 2>&1 &
-    cdc cli --start-ts=$start_ts
+    cdc cli changefeed create --start-ts=$start_ts --sink-uri="root@tcp(127.0.0.1:3306)/test"
 }
 
 function sql_check() {